@@ -1,79 +0,0 @@
-package main
-
-import (
-	"context"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"os"
-	"time"
-
-	"github.com/griggsjared/getsit/internal/url"
-	"github.com/griggsjared/getsit/internal/url/repository"
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
-)
-
-type app struct {
-	urlService *url.Service
-	logger     *slog.Logger
-}
-
-func main() {
-
-	godotenv.Load()
-
-	ctx := context.Background()
-
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		fmt.Println("DATABASE_URL is not set")
-		os.Exit(1)
-	}
-
-	db, err := pgxpool.New(ctx, dbUrl)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-	defer db.Close()
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	host := os.Getenv("HOST")
-	serverAddr := ":" + port
-	if host != "" {
-		serverAddr = host + serverAddr
-	}
-
-	app := &app{
-		urlService: url.NewService(repository.NewPGXUrlEntryRepository(db)),
-		logger:     slog.Default().With(slog.String("service", "getsit-api")),
-	}
-
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("POST /url-entries", app.createUrlEntryHandler)
-	mux.HandleFunc("GET /url-entries/{token}", app.getUrlEntryHandler)
-	mux.HandleFunc("GET /healthz", app.healthzHandler)
-
-	fmt.Printf("Starting server on %s\n", serverAddr)
-
-	server := &http.Server{
-		Addr:              serverAddr,
-		Handler:           app.middlewareStack(mux, app.loggerMiddleware),
-		ReadHeaderTimeout: 10 * time.Second,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       120 * time.Second,
-		MaxHeaderBytes:    1 << 20,
-	}
-
-	if err := server.ListenAndServe(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
-	}
-}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/griggsjared/getsit/internal/config"
+)
+
+const usage = `getsit is the url shortener web app, json api and tooling in one binary.
+
+Usage:
+  getsit serve web|api|all [flags]                      run the web app, the json api or both
+  getsit migrate up|up-by-one|down|reset|status [flags]  manage the database schema
+  getsit seed [flags]                                   seed the database with random url entries
+  getsit takedown [flags] <token>                       take a link down for a legal reason, or restore it
+
+Run getsit <command> -h to see the flags for a command.
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "serve":
+		err = runServe(os.Args[2:])
+	case "migrate":
+		err = runMigrate(os.Args[2:])
+	case "seed":
+		err = runSeed(os.Args[2:])
+	case "takedown":
+		err = runTakedown(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig will load the configuration of a command.
+// With --print-config the redacted configuration is written to stdout, any validation problems
+// to stderr, and the process exits before the command starts anything.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+
+	cfg, err := config.Load(fs, args)
+	if !errors.Is(err, config.ErrPrintConfig) {
+		return cfg, err
+	}
+
+	if err := cfg.Print(os.Stdout); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+	return cfg, nil
+}
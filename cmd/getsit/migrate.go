@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/migrate"
+)
+
+// runMigrate will apply, roll back or report the embedded database migrations
+func runMigrate(args []string) error {
+
+	if len(args) < 1 {
+		return fmt.Errorf("migrate needs one of up, up-by-one, down, reset or status")
+	}
+
+	action := args[0]
+	switch action {
+	case "up", "up-by-one", "down", "reset", "status":
+	default:
+		return fmt.Errorf("unknown migrate command %q, must be one of up, up-by-one, down, reset or status", action)
+	}
+
+	cfg, err := loadConfig(flag.NewFlagSet("getsit migrate "+action, flag.ExitOnError), args[1:])
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	db, err := cfg.NewPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	var run func(context.Context) ([]migrate.Result, error)
+	switch action {
+	case "up":
+		run = migrator.Up
+	case "up-by-one":
+		run = migrator.UpByOne
+	case "down":
+		run = migrator.Down
+	case "reset":
+		run = migrator.Reset
+	case "status":
+		return printMigrationStatus(ctx, migrator)
+	}
+
+	results, err := run(ctx)
+	for _, r := range results {
+		fmt.Printf("%-4s %s (%s)\n", r.Direction, r.Name, r.Duration.Round(time.Millisecond))
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No migrations to run")
+	}
+
+	return nil
+}
+
+// printMigrationStatus will print a table of every embedded migration and when it was applied
+func printMigrationStatus(ctx context.Context, migrator *migrate.Migrator) error {
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tMIGRATION\tAPPLIED AT")
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+
+	return tw.Flush()
+}
@@ -6,55 +6,51 @@ import (
 	"flag"
 	"fmt"
 	"math/big"
-	"os"
 	"sync"
 	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/joho/godotenv"
-
 	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
 	"github.com/griggsjared/getsit/internal/url/repository"
 )
 
-func main() {
+// runSeed will fill the url_entries table with random urls
+func runSeed(args []string) error {
 
 	var tCount int
 	var wCount int
 	var fresh bool
 
-	flag.IntVar(&tCount, "n", 1000, "number of url entries to seed")
-	flag.IntVar(&wCount, "w", 4, "number of workers")
-	flag.BoolVar(&fresh, "f", false, "truncate url_entries table before seeding")
-	flag.Parse()
-
-	ctx := context.Background()
-
-	godotenv.Load()
+	fs := flag.NewFlagSet("getsit seed", flag.ExitOnError)
+	fs.IntVar(&tCount, "n", 1000, "number of url entries to seed")
+	fs.IntVar(&wCount, "w", 4, "number of workers")
+	fs.BoolVar(&fresh, "f", false, "truncate url_entries table before seeding")
 
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		fmt.Println("DATABASE_URL is not set")
-		os.Exit(1)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
 	}
 
-	db, err := pgxpool.New(ctx, dbUrl)
+	ctx := context.Background()
+
+	db, err := cfg.NewPool(ctx)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return err
 	}
 	defer db.Close()
 
-	r := repository.NewPGXUrlEntryRepository(db)
+	r := repository.NewPGXUrlEntryRepository(db).WithTokenStrategy(entity.TokenStrategy(cfg.TokenStrategy))
 
 	service := url.NewService(r)
 
 	if fresh {
-		db.Exec(ctx, "TRUNCATE url_entries")
+		db.Exec(ctx, "TRUNCATE url_entries CASCADE")
 		fmt.Println("Truncated url_entries table")
 	}
 
 	SeedUrlEntries(ctx, tCount, wCount, service)
+
+	return nil
 }
 
 // seed will generate a number of tokens and check for duplicates
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/apiapp"
+	"github.com/griggsjared/getsit/internal/baseurl"
+	"github.com/griggsjared/getsit/internal/config"
+	"github.com/griggsjared/getsit/internal/geoip"
+	"github.com/griggsjared/getsit/internal/health"
+	"github.com/griggsjared/getsit/internal/mailer"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/migrate"
+	"github.com/griggsjared/getsit/internal/pow"
+	"github.com/griggsjared/getsit/internal/qrcode"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/requestid"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/user"
+	userrepository "github.com/griggsjared/getsit/internal/user/repository"
+	"github.com/griggsjared/getsit/internal/webapp"
+	"github.com/griggsjared/getsit/internal/workspace"
+	workspacerepository "github.com/griggsjared/getsit/internal/workspace/repository"
+)
+
+// runServe will run the web app, the api or both until the process is interrupted.
+// With serve all the api is mounted under /api on the main listener, or on its own listener when server.api_addr is set.
+func runServe(args []string) error {
+
+	if len(args) < 1 {
+		return fmt.Errorf("serve needs one of web, api or all")
+	}
+
+	target := args[0]
+	serviceName := "getsit-" + target
+	switch target {
+	case "web", "api":
+	case "all":
+		serviceName = "getsit"
+	default:
+		return fmt.Errorf("unknown serve target %q, must be one of web, api or all", target)
+	}
+
+	cfg, err := loadConfig(flag.NewFlagSet("getsit serve "+target, flag.ExitOnError), args[1:])
+	if err != nil {
+		return err
+	}
+
+	if target != "api" && cfg.SessionSecret == "" {
+		return fmt.Errorf("session_secret is required to serve the web app")
+	}
+
+	// every log line written with a request context carries the request id, the repository logs through the default logger
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewTextHandler(os.Stderr, nil))))
+
+	ctx := context.Background()
+
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  serviceName,
+		Exporter:     tracing.Exporter(cfg.Tracing.Exporter),
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+	})
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.Background())
+
+	db, err := cfg.NewPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if cfg.Migrate {
+		results, err := migrator.Up(ctx)
+		for _, r := range results {
+			fmt.Println("Applied migration", r.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to apply migrations: %w", err)
+		}
+	}
+
+	if err := migrator.Check(ctx); err != nil {
+		if errors.Is(err, migrate.ErrPending) {
+			return fmt.Errorf("%w, run getsit migrate up or start with --migrate", err)
+		}
+		return fmt.Errorf("failed to check migrations: %w", err)
+	}
+
+	trustedProxies, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		return err
+	}
+
+	adminUserIDs, err := cfg.AdminUserIDs()
+	if err != nil {
+		return err
+	}
+
+	baseURL, err := baseurl.New(cfg.BaseURL)
+	if err != nil {
+		return err
+	}
+
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimit.Enabled {
+		limiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	}
+
+	checker := health.NewChecker(db, migrator)
+	urlService := url.NewService(repository.NewPGXUrlEntryRepository(db).WithTokenStrategy(entity.TokenStrategy(cfg.TokenStrategy)).WithLogger(slog.Default())).
+		WithReportThreshold(cfg.Moderation.ReportThreshold).
+		WithReporterKey([]byte(cfg.SessionSecret))
+
+	// the short domains of the config are saved so their links keep their domain id when the config changes
+	shortDomains, err := cfg.ShortDomains()
+	if err != nil {
+		return err
+	}
+	var domainHosts []string
+	for _, d := range shortDomains {
+		domain, err := urlService.SaveDomain(ctx, &url.SaveDomainInput{Host: d.Host, FallbackUrl: d.FallbackURL})
+		if err != nil {
+			return fmt.Errorf("failed to save the short domain %s: %w", d.Host, err)
+		}
+		domainHosts = append(domainHosts, domain.Host)
+	}
+
+	var m mailer.Mailer = mailer.NewLogMailer(slog.Default().With(slog.String("service", "getsit-mail")))
+	if cfg.Mail.SMTPHost != "" {
+		m = mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		})
+	}
+	userService := user.NewService(userrepository.NewPGXUserRepository(db).WithLogger(slog.Default()), m)
+	workspaceService := workspace.NewService(workspacerepository.NewPGXWorkspaceRepository(db).WithLogger(slog.Default()), userService)
+
+	var webHandler, shortHandler, apiHandler http.Handler
+
+	if target != "api" {
+		geoipService, err := geoip.NewService(cfg.GeoIP.DatabasePath)
+		if err != nil {
+			return fmt.Errorf("failed to open the geoip database: %w", err)
+		}
+		defer geoipService.Close()
+
+		webApp := webapp.New(webapp.Options{
+			UrlService:     urlService,
+			QRCodeService:  qrcode.NewService(),
+			GeoIPService:   geoipService,
+			Health:         checker,
+			Logger:         slog.Default().With(slog.String("service", "getsit-web")),
+			SessionSecret:  cfg.SessionSecret,
+			TrustedProxies: trustedProxies,
+			BaseURL:        baseURL,
+			RateLimiter:    limiter,
+			RateLimits:     cfg.RateLimitPolicies(),
+			PoWService: pow.NewService(pow.Config{
+				Secret:        cfg.SessionSecret,
+				Difficulty:    cfg.PoW.Difficulty,
+				MaxDifficulty: cfg.PoW.MaxDifficulty,
+				Threshold:     cfg.PoW.Threshold,
+				Window:        cfg.PoW.Window,
+				TTL:           cfg.PoW.TTL,
+			}),
+			Security:    cfg.SecurityHeaders(),
+			UserService: userService,
+			OIDC:        cfg.OIDCProvider(),
+			OIDCName:    cfg.OIDC.Name,
+
+			WorkspaceService: workspaceService,
+			Domains:          domainHosts,
+			AdminUserIDs:     adminUserIDs,
+		})
+		webHandler = webApp.Handler()
+		shortHandler = webApp.ShortDomainHandler()
+	}
+
+	if target != "web" {
+		apiHandler = apiapp.New(apiapp.Options{
+			UrlService:     urlService,
+			Health:         checker,
+			Logger:         slog.Default().With(slog.String("service", "getsit-api")),
+			TrustedProxies: trustedProxies,
+			BaseURL:        baseURL,
+			RateLimiter:    limiter,
+			RateLimits:     cfg.RateLimitPolicies(),
+
+			WorkspaceService: workspaceService,
+		}).Handler()
+	}
+
+	// the web app is only served on the base url host, and every listener serves under the base url path prefix.
+	// The short domains only serve their short urls and are not under the path prefix.
+	resolver := realip.NewResolver(trustedProxies)
+	publicHandler := func(h http.Handler) http.Handler {
+		return resolver.Middleware(baseurl.ShortDomains(domainHosts, shortHandler, baseURL.CanonicalHost(baseURL.StripPrefix(h))))
+	}
+
+	var servers []*http.Server
+	switch {
+	case target == "web":
+		servers = append(servers, newServer(cfg, cfg.Addr(), publicHandler(webHandler)))
+	case target == "api":
+		servers = append(servers, newServer(cfg, cfg.Addr(), baseURL.StripPrefix(apiHandler)))
+	case cfg.Server.APIAddr != "":
+		servers = append(servers,
+			newServer(cfg, cfg.Addr(), publicHandler(webHandler)),
+			newServer(cfg, cfg.Server.APIAddr, baseURL.StripPrefix(apiHandler)),
+		)
+	default:
+		mux := http.NewServeMux()
+		mux.Handle("/api/", http.StripPrefix("/api", apiHandler))
+		mux.Handle("/", webHandler)
+		servers = append(servers, newServer(cfg, cfg.Addr(), publicHandler(mux)))
+	}
+
+	var metricsServer *http.Server
+	if cfg.Metrics.Addr != "" {
+		if err := metrics.RegisterPgxPool(db); err != nil {
+			return err
+		}
+		metricsServer = metrics.NewServer(cfg.Metrics.Addr)
+	}
+
+	serverErr := make(chan error, len(servers)+1)
+	for _, server := range servers {
+		go func() {
+			fmt.Println("Starting server on", server.Addr)
+			serverErr <- server.ListenAndServe()
+		}()
+	}
+	if metricsServer != nil {
+		go func() {
+			fmt.Println("Starting metrics server on", metricsServer.Addr)
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+	}
+
+	var runErr error
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			runErr = err
+		}
+	case <-sigCtx.Done():
+	}
+
+	// stop reporting ready so load balancers move traffic away, then let the in-flight requests finish
+	fmt.Println("Shutting down, draining for up to", cfg.Server.ShutdownTimeout)
+	checker.Drain()
+	if runErr == nil {
+		time.Sleep(cfg.Server.ShutdownDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Println("Failed to drain server:", err)
+		}
+	}
+	if metricsServer != nil {
+		metricsServer.Shutdown(shutdownCtx)
+	}
+
+	return runErr
+}
+
+// newServer will create an http server with the timeouts from the config
+func newServer(cfg *config.Config, addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    1 << 20,
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/repository"
+)
+
+// runTakedown will take a link down for a legal reason, or restore a link that was taken down or held
+func runTakedown(args []string) error {
+
+	var domain string
+	var reason string
+	var restore bool
+
+	fs := flag.NewFlagSet("getsit takedown", flag.ExitOnError)
+	fs.StringVar(&domain, "domain", "", "host of the short domain the token is on, empty for the base url")
+	fs.StringVar(&reason, "reason", "", "why the link is taken down, shown to its visitors")
+	fs.BoolVar(&restore, "restore", false, "make a link that was taken down or held redirect again")
+
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("takedown needs the token of the link")
+	}
+	token := fs.Arg(0)
+
+	// the command is run by whoever runs the service so it acts as an admin
+	ctx := authz.WithPrincipal(context.Background(), authz.Principal{Admin: true})
+
+	db, err := cfg.NewPool(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	service := url.NewService(repository.NewPGXUrlEntryRepository(db))
+
+	if restore {
+		input := &url.RestoreUrlInput{Token: token, Domain: domain}
+		if err := service.RestoreUrl(ctx, input); err != nil {
+			return takedownError(err, input.ValidationErrors)
+		}
+		fmt.Println("Restored", token)
+		return nil
+	}
+
+	input := &url.TakeDownUrlInput{Token: token, Domain: domain, Reason: reason}
+	if err := service.TakeDownUrl(ctx, input); err != nil {
+		return takedownError(err, input.ValidationErrors)
+	}
+	fmt.Println("Took down", token)
+	return nil
+}
+
+// takedownError will add the validation errors of the input to the error
+func takedownError(err error, validationErrors map[string]string) error {
+	for field, msg := range validationErrors {
+		return fmt.Errorf("%w: %s %s", err, field, msg)
+	}
+	return err
+}
@@ -0,0 +1,18 @@
+package database
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// MigrationsFS returns the embedded filesystem for the sql migrations and strips the migrations prefix from the path.
+func MigrationsFS() fs.FS {
+	fileS, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return fileS
+}
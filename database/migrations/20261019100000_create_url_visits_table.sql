@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_entries ADD COLUMN bot_visit_count BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE url_visits (
+    id BIGSERIAL PRIMARY KEY,
+    url_entry_id INTEGER NOT NULL REFERENCES url_entries (id) ON DELETE CASCADE,
+    is_bot BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX url_visits_url_entry_id_idx ON url_visits (url_entry_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE url_visits;
+
+ALTER TABLE url_entries DROP COLUMN bot_visit_count;
+-- +goose StatementEnd
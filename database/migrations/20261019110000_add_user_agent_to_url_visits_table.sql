@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_visits
+    ADD COLUMN browser_family TEXT NOT NULL DEFAULT '',
+    ADD COLUMN browser_version TEXT NOT NULL DEFAULT '',
+    ADD COLUMN os TEXT NOT NULL DEFAULT '',
+    ADD COLUMN device_class TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE url_visits
+    DROP COLUMN browser_family,
+    DROP COLUMN browser_version,
+    DROP COLUMN os,
+    DROP COLUMN device_class;
+-- +goose StatementEnd
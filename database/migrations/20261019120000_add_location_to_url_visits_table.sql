@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_visits
+    ADD COLUMN country TEXT NOT NULL DEFAULT '',
+    ADD COLUMN region TEXT NOT NULL DEFAULT '',
+    ADD COLUMN city TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE url_visits
+    DROP COLUMN country,
+    DROP COLUMN region,
+    DROP COLUMN city;
+-- +goose StatementEnd
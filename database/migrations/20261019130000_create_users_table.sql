@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE password_resets (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- links are unique per owner, anonymous links stay unique across everyone without an owner
+ALTER TABLE url_entries
+    ADD COLUMN owner_id BIGINT DEFAULT NULL REFERENCES users (id),
+    DROP CONSTRAINT url_entries_url_key;
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (url) WHERE owner_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (owner_id, url) WHERE owner_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+DELETE FROM url_entries WHERE owner_id IS NOT NULL;
+
+ALTER TABLE url_entries
+    DROP COLUMN owner_id,
+    ADD CONSTRAINT url_entries_url_key UNIQUE (url);
+
+DROP TABLE password_resets;
+DROP TABLE users;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_entries
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active',
+    ADD CONSTRAINT url_entries_status_check CHECK (status IN ('active', 'disabled'));
+
+CREATE INDEX url_entries_owner_created_at_idx ON url_entries (owner_id, created_at DESC) WHERE owner_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX url_entries_owner_created_at_idx;
+
+ALTER TABLE url_entries
+    DROP CONSTRAINT url_entries_status_check,
+    DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_entries ADD COLUMN manage_token_hash TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE url_entries DROP COLUMN manage_token_hash;
+-- +goose StatementEnd
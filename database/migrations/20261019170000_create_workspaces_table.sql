@@ -0,0 +1,65 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE workspaces (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE workspace_members (
+    workspace_id BIGINT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX workspace_members_user_id_idx ON workspace_members (user_id);
+
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    workspace_id BIGINT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    role TEXT NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_by BIGINT DEFAULT NULL REFERENCES users (id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX api_keys_workspace_id_idx ON api_keys (workspace_id);
+
+-- links of a workspace are unique per workspace whoever created them, the owner id is kept as the creator
+ALTER TABLE url_entries
+    ADD COLUMN workspace_id BIGINT DEFAULT NULL REFERENCES workspaces (id) ON DELETE CASCADE;
+
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (url) WHERE owner_id IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (owner_id, url) WHERE owner_id IS NOT NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_workspace_url_idx ON url_entries (workspace_id, url) WHERE workspace_id IS NOT NULL;
+CREATE INDEX url_entries_workspace_created_at_idx ON url_entries (workspace_id, created_at DESC) WHERE workspace_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX url_entries_workspace_created_at_idx;
+DROP INDEX url_entries_workspace_url_idx;
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+DELETE FROM url_entries WHERE workspace_id IS NOT NULL;
+
+ALTER TABLE url_entries DROP COLUMN workspace_id;
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (url) WHERE owner_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (owner_id, url) WHERE owner_id IS NOT NULL;
+
+DROP TABLE api_keys;
+DROP TABLE workspace_members;
+DROP TABLE workspaces;
+-- +goose StatementEnd
@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE domains (
+    id BIGSERIAL PRIMARY KEY,
+    host TEXT NOT NULL UNIQUE,
+    fallback_url TEXT DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- tokens are unique per domain, the links of the base url have no domain
+ALTER TABLE url_entries
+    ADD COLUMN domain_id BIGINT DEFAULT NULL REFERENCES domains (id),
+    DROP CONSTRAINT url_entries_token_key;
+
+CREATE UNIQUE INDEX url_entries_domain_token_idx ON url_entries (token, COALESCE(domain_id, 0));
+
+DROP INDEX url_entries_workspace_url_idx;
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (COALESCE(domain_id, 0), url) WHERE owner_id IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (COALESCE(domain_id, 0), owner_id, url) WHERE owner_id IS NOT NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_workspace_url_idx ON url_entries (COALESCE(domain_id, 0), workspace_id, url) WHERE workspace_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX url_entries_workspace_url_idx;
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+DROP INDEX url_entries_domain_token_idx;
+
+DELETE FROM url_entries WHERE domain_id IS NOT NULL;
+
+ALTER TABLE url_entries
+    DROP COLUMN domain_id,
+    ADD CONSTRAINT url_entries_token_key UNIQUE (token);
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (url) WHERE owner_id IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (owner_id, url) WHERE owner_id IS NOT NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_workspace_url_idx ON url_entries (workspace_id, url) WHERE workspace_id IS NOT NULL;
+
+DROP TABLE domains;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE url_revisions (
+    id BIGSERIAL PRIMARY KEY,
+    url_entry_id INTEGER NOT NULL REFERENCES url_entries (id) ON DELETE CASCADE,
+    old_url TEXT NOT NULL,
+    new_url TEXT NOT NULL,
+    user_id BIGINT DEFAULT NULL REFERENCES users (id) ON DELETE SET NULL,
+    api_key_id BIGINT DEFAULT NULL REFERENCES api_keys (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX url_revisions_url_entry_id_idx ON url_revisions (url_entry_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE url_revisions;
+-- +goose StatementEnd
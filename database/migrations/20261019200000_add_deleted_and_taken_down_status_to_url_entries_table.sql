@@ -0,0 +1,46 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_entries
+    DROP CONSTRAINT url_entries_status_check,
+    ADD CONSTRAINT url_entries_status_check CHECK (status IN ('active', 'disabled', 'deleted', 'taken-down')),
+    ADD COLUMN takedown_reason TEXT NOT NULL DEFAULT '';
+
+-- only the active entries hold their url, a link that was stopped does not block a new link for the same url
+DROP INDEX url_entries_workspace_url_idx;
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (COALESCE(domain_id, 0), url) WHERE owner_id IS NULL AND workspace_id IS NULL AND status = 'active';
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (COALESCE(domain_id, 0), owner_id, url) WHERE owner_id IS NOT NULL AND workspace_id IS NULL AND status = 'active';
+CREATE UNIQUE INDEX url_entries_workspace_url_idx ON url_entries (COALESCE(domain_id, 0), workspace_id, url) WHERE workspace_id IS NOT NULL AND status = 'active';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX url_entries_workspace_url_idx;
+DROP INDEX url_entries_owner_url_idx;
+DROP INDEX url_entries_anonymous_url_idx;
+
+DELETE FROM url_entries WHERE status IN ('deleted', 'taken-down');
+
+-- keep one entry for each url, the active one when there is one
+DELETE FROM url_entries WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY COALESCE(domain_id, 0), workspace_id, CASE WHEN workspace_id IS NULL THEN owner_id END, url
+            ORDER BY status = 'active' DESC, id
+        ) AS n
+        FROM url_entries
+    ) AS ranked
+    WHERE n > 1
+);
+
+ALTER TABLE url_entries
+    DROP COLUMN takedown_reason,
+    DROP CONSTRAINT url_entries_status_check,
+    ADD CONSTRAINT url_entries_status_check CHECK (status IN ('active', 'disabled'));
+
+CREATE UNIQUE INDEX url_entries_anonymous_url_idx ON url_entries (COALESCE(domain_id, 0), url) WHERE owner_id IS NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_owner_url_idx ON url_entries (COALESCE(domain_id, 0), owner_id, url) WHERE owner_id IS NOT NULL AND workspace_id IS NULL;
+CREATE UNIQUE INDEX url_entries_workspace_url_idx ON url_entries (COALESCE(domain_id, 0), workspace_id, url) WHERE workspace_id IS NOT NULL;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE url_reports (
+    id BIGSERIAL PRIMARY KEY,
+    url_entry_id INTEGER NOT NULL REFERENCES url_entries (id) ON DELETE CASCADE,
+    category TEXT NOT NULL CHECK (category IN ('phishing', 'malware', 'spam', 'illegal', 'other')),
+    description TEXT NOT NULL DEFAULT '',
+    reporter_hash TEXT NOT NULL,
+    user_id BIGINT DEFAULT NULL REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP DEFAULT NULL
+);
+
+-- a reporter has one open report per link so the open reports count distinct reporters
+CREATE UNIQUE INDEX url_reports_open_reporter_idx ON url_reports (url_entry_id, reporter_hash) WHERE resolved_at IS NULL;
+CREATE INDEX url_reports_open_created_at_idx ON url_reports (created_at DESC) WHERE resolved_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE url_reports;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE url_entries
+    DROP CONSTRAINT url_entries_status_check,
+    ADD CONSTRAINT url_entries_status_check CHECK (status IN ('active', 'disabled', 'held', 'deleted', 'taken-down'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE url_entries SET status = 'disabled' WHERE status = 'held';
+
+ALTER TABLE url_entries
+    DROP CONSTRAINT url_entries_status_check,
+    ADD CONSTRAINT url_entries_status_check CHECK (status IN ('active', 'disabled', 'deleted', 'taken-down'));
+-- +goose StatementEnd
@@ -0,0 +1,81 @@
+package apiapp
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/griggsjared/getsit/internal/baseurl"
+	"github.com/griggsjared/getsit/internal/health"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/requestid"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/workspace"
+)
+
+// Options are the dependencies of the json api
+type Options struct {
+	UrlService     *url.Service
+	Health         *health.Checker
+	Logger         *slog.Logger
+	TrustedProxies []netip.Prefix     // The proxies allowed to set the forwarding headers
+	BaseURL        *baseurl.BaseURL   // The public url short links are built from, links follow the request when nil
+	RateLimiter    *ratelimit.Limiter // Requests are not limited when nil
+	RateLimits     ratelimit.Policies
+
+	WorkspaceService *workspace.Service // Api keys are disabled when nil
+}
+
+// App is the json api for creating and reading url entries
+type App struct {
+	urlService *url.Service
+	health     *health.Checker
+	logger     *slog.Logger
+	realip     *realip.Resolver
+	baseURL    *baseurl.BaseURL
+	limiter    *ratelimit.Limiter
+	limits     ratelimit.Policies
+
+	workspaceService *workspace.Service
+}
+
+// New will create a new api application
+func New(opts Options) *App {
+	if opts.BaseURL == nil {
+		opts.BaseURL = &baseurl.BaseURL{}
+	}
+	return &App{
+		urlService: opts.UrlService,
+		health:     opts.Health,
+		logger:     opts.Logger,
+		realip:     realip.NewResolver(opts.TrustedProxies),
+		baseURL:    opts.BaseURL,
+		limiter:    opts.RateLimiter,
+		limits:     opts.RateLimits,
+
+		workspaceService: opts.WorkspaceService,
+	}
+}
+
+// Handler will return the routes of the api wrapped in the request middleware.
+// The routes do not include the base url path prefix, it should be stripped before the handler.
+func (a *App) Handler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	tooManyRequests := http.HandlerFunc(a.tooManyRequestsHandler)
+	createLimit := a.limiter.Middleware(a.limits.Create, tooManyRequests)
+	notFoundLimit := a.limiter.NotFoundMiddleware(a.limits.NotFound, tooManyRequests)
+
+	mux.HandleFunc("POST /url-entries", a.middlewareStackFunc(a.createUrlEntryHandler, createLimit))
+	mux.HandleFunc("GET /url-entries", a.listUrlEntriesHandler)
+	mux.HandleFunc("GET /url-entries/{token}", a.middlewareStackFunc(a.getUrlEntryHandler, notFoundLimit))
+	mux.HandleFunc("DELETE /url-entries/{token}", a.middlewareStackFunc(a.deleteUrlEntryHandler, notFoundLimit))
+	mux.HandleFunc("GET /url-entries/{token}/history", a.middlewareStackFunc(a.urlEntryHistoryHandler, notFoundLimit))
+	mux.HandleFunc("GET /healthz", a.healthzHandler)
+	mux.HandleFunc("GET /readyz", a.readyzHandler)
+
+	return a.middlewareStack(mux, a.apiKeyMiddleware, a.recoverMiddleware, a.metricsMiddleware, tracing.Middleware, a.loggerMiddleware, requestid.Middleware, a.realip.Middleware)
+}
@@ -0,0 +1,301 @@
+package apiapp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/apiapp"
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/health"
+	"github.com/griggsjared/getsit/internal/requestid"
+	urlservice "github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/user"
+	userentity "github.com/griggsjared/getsit/internal/user/entity"
+	userrepository "github.com/griggsjared/getsit/internal/user/repository"
+	"github.com/griggsjared/getsit/internal/workspace"
+	workspacerepository "github.com/griggsjared/getsit/internal/workspace/repository"
+)
+
+type fakePinger struct{}
+
+func (p fakePinger) Ping(ctx context.Context) error {
+	return nil
+}
+
+func newHandler() http.Handler {
+	return apiapp.New(apiapp.Options{
+		UrlService: urlservice.NewService(repository.NewMemUrlEntryRepository()),
+		Health:     health.NewChecker(fakePinger{}, nil),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).Handler()
+}
+
+func TestApp_Handler(t *testing.T) {
+
+	h := newHandler()
+
+	form := url.Values{"url": {"https://example.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/url-entries", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /url-entries status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var created struct {
+		Token       string `json:"token"`
+		ShortUrl    string `json:"short_url"`
+		Url         string `json:"url"`
+		ManageToken string `json:"manage_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode the created entry: %v", err)
+	}
+	if created.Token == "" || created.Url != "https://example.com" {
+		t.Fatalf("created entry = %+v", created)
+	}
+	if want := "http://example.com/" + created.Token; created.ShortUrl != want {
+		t.Errorf("short_url = %v, want %v", created.ShortUrl, want)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "existing token",
+			path:       "/url-entries/" + created.Token,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "human visits",
+			path:       "/url-entries/" + created.Token + "?visits=human",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid visits",
+			path:       "/url-entries/" + created.Token + "?visits=bots",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing token",
+			path:       "/url-entries/missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "history without the manage token",
+			path:       "/url-entries/" + created.Token + "/history",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "history with the manage token",
+			path:       "/url-entries/" + created.Token + "/history?manage_token=" + url.QueryEscape(created.ManageToken),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "readyz",
+			path:       "/readyz",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestApp_HandlerVisitCounts(t *testing.T) {
+
+	s := urlservice.NewService(repository.NewMemUrlEntryRepository())
+	owner := authz.WithPrincipal(context.Background(), authz.Principal{UserID: 1})
+	input := &urlservice.SaveUrlInput{Url: "https://example.com"}
+	entry, err := s.SaveUrl(owner, input)
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	anonymous := &urlservice.SaveUrlInput{Url: "https://anonymous.com"}
+	if _, err := s.SaveUrl(context.Background(), anonymous); err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+
+	h := apiapp.New(apiapp.Options{
+		UrlService: s,
+		Health:     health.NewChecker(fakePinger{}, nil),
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}).Handler()
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantCounts bool
+	}{
+		{name: "get another user's link without a key", method: http.MethodGet, path: "/url-entries/" + entry.Token.String()},
+		{name: "create the url of another user's link", method: http.MethodPost, path: "/url-entries?url=https://anonymous.com"},
+		{name: "get with a wrong manage token", method: http.MethodGet, path: "/url-entries/" + entry.Token.String() + "?manage_token=" + url.QueryEscape(anonymous.ManageToken)},
+		{name: "get with the manage token", method: http.MethodGet, path: "/url-entries/" + entry.Token.String() + "?manage_token=" + url.QueryEscape(input.ManageToken), wantCounts: true},
+		{name: "create with the manage token", method: http.MethodPost, path: "/url-entries?url=https://anonymous.com&manage_token=" + url.QueryEscape(anonymous.ManageToken), wantCounts: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s %s status = %d, want %d", tt.method, tt.path, rec.Code, http.StatusOK)
+			}
+			var res map[string]any
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatalf("failed to decode the entry: %v", err)
+			}
+			_, visits := res["visit_count"]
+			_, bots := res["bot_visit_count"]
+			if visits != tt.wantCounts || bots != tt.wantCounts {
+				t.Errorf("%s %s returned the visit counts = %v %v, want %v", tt.method, tt.path, visits, bots, tt.wantCounts)
+			}
+		})
+	}
+}
+
+// panicRepository is a url entry repository that panics on every lookup
+type panicRepository struct {
+	*repository.MemUrlEntryRepository
+}
+
+func (r panicRepository) GetFromToken(ctx context.Context, domainID int64, token entity.UrlToken) (*entity.UrlEntry, error) {
+	panic("lookup failed")
+}
+
+func TestApp_HandlerRecover(t *testing.T) {
+
+	var logs bytes.Buffer
+	h := apiapp.New(apiapp.Options{
+		UrlService: urlservice.NewService(panicRepository{repository.NewMemUrlEntryRepository()}),
+		Health:     health.NewChecker(fakePinger{}, nil),
+		Logger:     slog.New(requestid.NewLogHandler(slog.NewTextHandler(&logs, nil))),
+	}).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/url-entries/abcd1234", nil)
+	req.Header.Set(requestid.Header, "req-123")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if got := rec.Header().Get(requestid.Header); got != "req-123" {
+		t.Errorf("%s = %q, want %q", requestid.Header, got, "req-123")
+	}
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body.Message == "" {
+		t.Errorf("body is not a json error, message = %q, err = %v", body.Message, err)
+	}
+	for _, want := range []string{"msg=panic", "lookup failed", "stack=", "request_id=req-123"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs do not contain %q:\n%s", want, logs.String())
+		}
+	}
+}
+
+func TestApp_HandlerAPIKeys(t *testing.T) {
+
+	users := userrepository.NewMemUserRepository()
+	owner, _ := users.CreateUser(context.Background(), userentity.Email("owner@example.com"), nil)
+	workspaces := workspace.NewService(workspacerepository.NewMemWorkspaceRepository(), user.NewTestService(users, nil))
+
+	ctx := authz.WithPrincipal(context.Background(), authz.Principal{UserID: owner.ID})
+	w, err := workspaces.CreateWorkspace(ctx, &workspace.CreateWorkspaceInput{Name: "Team"})
+	if err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+	keys := map[string]string{}
+	for _, role := range []string{"editor", "viewer"} {
+		input := &workspace.CreateAPIKeyInput{WorkspaceID: w.ID, Name: role, Role: role}
+		if _, err := workspaces.CreateAPIKey(ctx, input); err != nil {
+			t.Fatalf("CreateAPIKey() error = %v", err)
+		}
+		keys[role] = "Bearer " + input.Key
+	}
+
+	h := apiapp.New(apiapp.Options{
+		UrlService:       urlservice.NewService(repository.NewMemUrlEntryRepository()),
+		Health:           health.NewChecker(fakePinger{}, nil),
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WorkspaceService: workspaces,
+	}).Handler()
+
+	form := url.Values{"url": {"https://example.com"}}
+	req := httptest.NewRequest(http.MethodPost, "/url-entries", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", keys["editor"])
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /url-entries status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var created struct {
+		Token       string `json:"token"`
+		ManageToken string `json:"manage_token"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatalf("failed to decode the created entry: %v", err)
+	}
+	if created.ManageToken != "" {
+		t.Errorf("manage_token = %q, want none for a workspace entry", created.ManageToken)
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "list without a key", method: http.MethodGet, path: "/url-entries", wantStatus: http.StatusUnauthorized},
+		{name: "list with an unknown key", method: http.MethodGet, path: "/url-entries", authorization: "Bearer gsk_unknown", wantStatus: http.StatusUnauthorized},
+		{name: "list with basic auth", method: http.MethodGet, path: "/url-entries", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized},
+		{name: "list as viewer", method: http.MethodGet, path: "/url-entries", authorization: keys["viewer"], wantStatus: http.StatusOK},
+		{name: "get without a key", method: http.MethodGet, path: "/url-entries/" + created.Token, wantStatus: http.StatusNotFound},
+		{name: "get as viewer", method: http.MethodGet, path: "/url-entries/" + created.Token, authorization: keys["viewer"], wantStatus: http.StatusOK},
+		{name: "history without a key", method: http.MethodGet, path: "/url-entries/" + created.Token + "/history", wantStatus: http.StatusNotFound},
+		{name: "history as viewer", method: http.MethodGet, path: "/url-entries/" + created.Token + "/history", authorization: keys["viewer"], wantStatus: http.StatusOK},
+		{name: "create as viewer", method: http.MethodPost, path: "/url-entries?url=https://viewer.com", authorization: keys["viewer"], wantStatus: http.StatusForbidden},
+		{name: "delete as viewer", method: http.MethodDelete, path: "/url-entries/" + created.Token, authorization: keys["viewer"], wantStatus: http.StatusForbidden},
+		{name: "delete without a key", method: http.MethodDelete, path: "/url-entries/" + created.Token, wantStatus: http.StatusNotFound},
+		{name: "delete as editor", method: http.MethodDelete, path: "/url-entries/" + created.Token, authorization: keys["editor"], wantStatus: http.StatusNoContent},
+		{name: "get deleted", method: http.MethodGet, path: "/url-entries/" + created.Token, authorization: keys["editor"], wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("%s %s status = %d, want %d", tt.method, tt.path, rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
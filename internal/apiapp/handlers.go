@@ -0,0 +1,305 @@
+package apiapp
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+)
+
+// urlEntryResponse is the response struct for the url entry.
+// The visit counts are only set for whoever can manage the entry or is in its workspace, see newUrlEntryResponse.
+type urlEntryResponse struct {
+	Token          string `json:"token"`
+	Domain         string `json:"domain,omitempty"` // The host of the short domain of the token, empty for the base url
+	ShortUrl       string `json:"short_url"`
+	Url            string `json:"url"`
+	VisitCount     *int   `json:"visit_count,omitempty"`
+	Visits         string `json:"visits,omitempty"`
+	BotVisitCount  *int   `json:"bot_visit_count,omitempty"`
+	Status         string `json:"status"`                    // active, disabled, held or taken-down
+	TakedownReason string `json:"takedown_reason,omitempty"` // Why an admin took the entry down
+	ManageToken    string `json:"manage_token,omitempty"`    // Only returned when the entry is created
+}
+
+// newUrlEntryResponse will create the response for the entry, with its visit counts when the request can manage the entry
+// with the manage token or is in the workspace of the entry. Everyone else only gets what the preview of the link shows.
+func (a *App) newUrlEntryResponse(r *http.Request, entry *entity.UrlEntry, manageToken string) urlEntryResponse {
+	res := urlEntryResponse{
+		Token:          entry.Token.String(),
+		Domain:         entry.Domain,
+		ShortUrl:       a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String()),
+		Url:            entry.Url.String(),
+		Status:         string(entry.Status),
+		TakedownReason: entry.TakedownReason,
+	}
+	if a.urlService.CanManage(r.Context(), entry, manageToken) || (entry.WorkspaceID != 0 && a.urlService.CanView(r.Context(), entry)) {
+		res.VisitCount = &entry.VisitCount
+		res.BotVisitCount = &entry.BotVisitCount
+	}
+	return res
+}
+
+// createUrlEntryHandler is the handler to create a new url entry.
+// The domain form value picks the short domain the token is on, empty is the base url.
+// With an api key the entry belongs to the workspace of the key, which needs the editor role.
+// An existing entry of the url is returned instead, its visit counts only with its manage_token or the api key of its workspace.
+func (a *App) createUrlEntryHandler(w http.ResponseWriter, r *http.Request) {
+
+	if exists, _ := a.urlService.GetUrlByUrl(r.Context(), &url.GetUrlByUrlInput{
+		Url:    r.FormValue("url"),
+		Domain: r.FormValue("domain"),
+	}); exists != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.newUrlEntryResponse(r, exists, r.FormValue("manage_token")))
+		return
+	}
+
+	input := &url.SaveUrlInput{
+		Url:    r.FormValue("url"),
+		Domain: r.FormValue("domain"),
+	}
+
+	entry, err := a.urlService.SaveUrl(r.Context(), input)
+	if errors.Is(err, url.ErrForbidden) {
+		a.errorHandler(w, r, http.StatusForbidden, "The api key can not create url entries")
+		return
+	}
+	if input.ValidationErrors["domain"] != "" {
+		a.errorHandler(w, r, http.StatusBadRequest, "The domain is not known")
+		return
+	}
+	if err != nil {
+		a.errorHandler(w, r, http.StatusBadRequest, "Failed to save url")
+		return
+	}
+
+	res := a.newUrlEntryResponse(r, entry, input.ManageToken)
+	res.ManageToken = input.ManageToken
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// getUrlEntryHandler is the handler to get a single url entry by token, the domain query parameter is the host of its short domain
+// The visits query parameter can be set to "human" or "total" (default) to pick which count visit_count reports
+// The entries of a workspace are only found with an api key of the workspace.
+// The visit counts of an entry outside a workspace are only returned with its manage_token.
+func (a *App) getUrlEntryHandler(w http.ResponseWriter, r *http.Request) {
+
+	visits := r.URL.Query().Get("visits")
+	if visits == "" {
+		visits = "total"
+	}
+	if visits != "total" && visits != "human" {
+		a.errorHandler(w, r, http.StatusBadRequest, "visits must be one of human or total")
+		return
+	}
+
+	entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{
+		Token:  r.PathValue("token"),
+		Domain: r.URL.Query().Get("domain"),
+	})
+	if err != nil || !a.urlService.CanView(r.Context(), entry) {
+		a.errorHandler(w, r, http.StatusNotFound, "Url entry not found")
+		return
+	}
+
+	res := a.newUrlEntryResponse(r, entry, r.URL.Query().Get("manage_token"))
+	if res.VisitCount != nil {
+		res.Visits = visits
+		if visits == "human" {
+			human := entry.HumanVisitCount()
+			res.VisitCount = &human
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// urlEntryListResponse is the response struct for a page of url entries
+type urlEntryListResponse struct {
+	Entries []urlEntryResponse `json:"entries"`
+	Total   int                `json:"total"`
+	Page    int                `json:"page"`
+	PerPage int                `json:"per_page"`
+	Pages   int                `json:"pages"`
+}
+
+// listUrlEntriesHandler is the handler to list the url entries of the workspace of the api key.
+// The q, sort (created or visits), page and per_page query parameters search, order and page through the entries.
+func (a *App) listUrlEntriesHandler(w http.ResponseWriter, r *http.Request) {
+
+	if authz.FromContext(r.Context()).WorkspaceID == 0 {
+		a.unauthorizedHandler(w, r, "An api key is required to list url entries")
+		return
+	}
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+	perPage, _ := strconv.Atoi(query.Get("per_page"))
+
+	result, err := a.urlService.ListUrls(r.Context(), &url.ListUrlsInput{
+		Search:  query.Get("q"),
+		Sort:    query.Get("sort"),
+		Page:    page,
+		PerPage: perPage,
+	})
+	if errors.Is(err, url.ErrForbidden) {
+		a.errorHandler(w, r, http.StatusForbidden, "The api key can not list url entries")
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the url entries", slog.String("error", err.Error()))
+		a.errorHandler(w, r, http.StatusInternalServerError, "Failed to list url entries")
+		return
+	}
+
+	res := urlEntryListResponse{
+		Entries: []urlEntryResponse{},
+		Total:   result.Total,
+		Page:    result.Page,
+		PerPage: result.PerPage,
+		Pages:   result.Pages(),
+	}
+	for _, entry := range result.Entries {
+		res.Entries = append(res.Entries, a.newUrlEntryResponse(r, entry, ""))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// deleteUrlEntryHandler is the handler to delete a url entry, the domain value is the host of its short domain.
+// A deleted entry is no longer found, its token is kept so it is never given out again.
+// An api key can delete the entries of its workspace with the editor role, an entry outside a workspace can be deleted with its manage_token.
+func (a *App) deleteUrlEntryHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.DeleteUrlInput{
+		Token:       r.PathValue("token"),
+		Domain:      r.FormValue("domain"),
+		ManageToken: r.FormValue("manage_token"),
+	}
+
+	err := a.urlService.DeleteUrl(r.Context(), input)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, url.ErrForbidden):
+		a.errorHandler(w, r, http.StatusForbidden, "The api key can not delete url entries")
+	case errors.Is(err, url.ErrValidation) && input.ValidationErrors["status"] != "":
+		a.errorHandler(w, r, http.StatusConflict, "The url entry was taken down and can not be deleted")
+	case errors.Is(err, url.ErrNotFound), errors.Is(err, url.ErrValidation):
+		a.errorHandler(w, r, http.StatusNotFound, "Url entry not found")
+	default:
+		a.logger.ErrorContext(r.Context(), "failed to delete the url entry", slog.String("error", err.Error()))
+		a.errorHandler(w, r, http.StatusInternalServerError, "Failed to delete url entry")
+	}
+}
+
+// urlRevisionResponse is the response struct for a change of where a url entry redirects to
+type urlRevisionResponse struct {
+	ID         int64     `json:"id"`
+	OldUrl     string    `json:"old_url"`
+	NewUrl     string    `json:"new_url"`
+	UserID     int64     `json:"user_id,omitempty"`     // The user that made the change, only shown to that user and the workspace of the entry
+	UserHidden bool      `json:"user_hidden,omitempty"` // A user made the change but who is hidden
+	APIKeyID   int64     `json:"api_key_id,omitempty"`  // The workspace api key the change was made with
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// urlRevisionListResponse is the response struct for the revisions of a url entry
+type urlRevisionListResponse struct {
+	Revisions []urlRevisionResponse `json:"revisions"`
+}
+
+// urlEntryHistoryHandler is the handler to list every change of where a url entry redirects to, newest first.
+// An api key can see the history of the entries of its workspace, the history of an entry outside a workspace can be seen with its manage_token.
+func (a *App) urlEntryHistoryHandler(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	revisions, err := a.urlService.ListUrlRevisions(r.Context(), &url.ListUrlRevisionsInput{
+		Token:       r.PathValue("token"),
+		Domain:      query.Get("domain"),
+		ManageToken: query.Get("manage_token"),
+	})
+	if errors.Is(err, url.ErrNotFound) || errors.Is(err, url.ErrValidation) {
+		a.errorHandler(w, r, http.StatusNotFound, "Url entry not found")
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the revisions of the url entry", slog.String("error", err.Error()))
+		a.errorHandler(w, r, http.StatusInternalServerError, "Failed to get the url entry history")
+		return
+	}
+
+	res := urlRevisionListResponse{
+		Revisions: []urlRevisionResponse{},
+	}
+	for _, revision := range revisions {
+		res.Revisions = append(res.Revisions, urlRevisionResponse{
+			ID:         revision.ID,
+			OldUrl:     revision.OldUrl.String(),
+			NewUrl:     revision.NewUrl.String(),
+			UserID:     revision.UserID,
+			UserHidden: revision.UserHidden,
+			APIKeyID:   revision.APIKeyID,
+			CreatedAt:  revision.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// errorResponse is the response struct for errors
+type errorResponse struct {
+	Message string `json:"message"`
+}
+
+// errorHandler is the handler for errors
+func (a *App) errorHandler(w http.ResponseWriter, _ *http.Request, status int, message string) {
+	w.WriteHeader(status)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(errorResponse{
+		Message: message,
+	})
+}
+
+// unauthorizedHandler is the handler for requests without a valid api key
+func (a *App) unauthorizedHandler(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	a.errorHandler(w, r, http.StatusUnauthorized, message)
+}
+
+// tooManyRequestsHandler is the handler for requests denied by a rate limit, the Retry-After header is already set
+func (a *App) tooManyRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	a.errorHandler(w, r, http.StatusTooManyRequests, "Too many requests, retry after "+ratelimit.RetryAfter(w).String())
+}
+
+// healthzHandler is the handler for the healthz path of the api.
+func (a *App) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler is the handler for the readyz path of the api.
+// It will return a 503 when the database is unreachable, migrations are pending or the server is draining.
+func (a *App) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.health.Ready(r.Context()); err != nil {
+		a.logger.WarnContext(r.Context(), "not ready", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
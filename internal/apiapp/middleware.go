@@ -0,0 +1,111 @@
+package apiapp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/httpwriter"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/workspace"
+)
+
+// middleware is a type that wraps an http.Handler and returns a new http.Handler
+type middleware func(http.Handler) http.Handler
+
+// middlewareStack will take a handler and a list of middlewares and return a new handler
+func (a *App) middlewareStack(h http.Handler, middlewares ...middleware) http.Handler {
+	for _, middleware := range middlewares {
+		h = middleware(h)
+	}
+	return h
+}
+
+// middlewareStackFunc will take a handler and a list of middlewares and return a new handler.
+// This is a convenience function that wraps middlewareStack to work with http.HandlerFunc
+func (a *App) middlewareStackFunc(h http.HandlerFunc, middleware ...middleware) http.HandlerFunc {
+	return a.middlewareStack(h, middleware...).ServeHTTP
+}
+
+// recoverMiddleware will recover from a panic in a handler, log it with the stack trace and respond with a json error.
+// http.ErrAbortHandler is passed on so the server can abort the response as intended.
+func (a *App) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := httpwriter.NewRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			a.logger.ErrorContext(r.Context(), "panic", slog.Any("panic", v), slog.String("path", r.URL.Path), slog.String("stack", string(debug.Stack())))
+			if !rw.Started {
+				a.errorHandler(w, r, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// loggerMiddleware records the and method of the incoming request and the time taken to process the request
+func (a *App) loggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		a.logger.InfoContext(r.Context(), "request", slog.String("ip", realip.FromRequest(r).IP), slog.String("path", r.URL.Path), slog.String("method", r.Method), slog.Duration("duration", time.Since(start)))
+	})
+}
+
+// metricsMiddleware records the request count and latency by route pattern and status.
+// This should wrap the mux so the matched route pattern is available after the request is served
+func (a *App) metricsMiddleware(next http.Handler) http.Handler {
+	return metrics.InstrumentHandler("getsit-api", next)
+}
+
+// apiKeyMiddleware will authenticate the api key in the Authorization header and make the request act in its workspace.
+// The request is rate limited by the key instead of the client ip, so it has to run before the rate limits.
+// Requests without a key stay anonymous, an unknown key is rejected so a mistyped key does not quietly act anonymously.
+func (a *App) apiKeyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		header := r.Header.Get("Authorization")
+		if header == "" || a.workspaceService == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			a.unauthorizedHandler(w, r, "The Authorization header must be a Bearer api key")
+			return
+		}
+
+		apiKey, err := a.workspaceService.AuthenticateAPIKey(r.Context(), strings.TrimSpace(key))
+		if errors.Is(err, workspace.ErrInvalidAPIKey) {
+			a.unauthorizedHandler(w, r, "Invalid api key")
+			return
+		}
+		if err != nil {
+			a.logger.ErrorContext(r.Context(), "failed to authenticate the api key", slog.String("error", err.Error()))
+			a.errorHandler(w, r, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+
+		ctx := authz.WithPrincipal(r.Context(), authz.Principal{
+			WorkspaceID: apiKey.WorkspaceID,
+			Role:        apiKey.Role,
+			APIKeyID:    apiKey.ID,
+		})
+		ctx = ratelimit.WithSubject(ctx, "key:"+strconv.FormatInt(apiKey.ID, 10))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
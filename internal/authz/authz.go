@@ -0,0 +1,67 @@
+// Package authz carries who a request acts as through the context so the services can check what it is allowed to do
+package authz
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role is what a member can do in a workspace
+type Role string
+
+const (
+	RoleOwner  Role = "owner"  // Can do everything, including managing the members and api keys
+	RoleEditor Role = "editor" // Can create, change and delete the links of the workspace
+	RoleViewer Role = "viewer" // Can only see the links of the workspace
+)
+
+// Validate will check if the role is known
+func (r Role) Validate() error {
+	switch r {
+	case RoleOwner, RoleEditor, RoleViewer:
+		return nil
+	}
+	return fmt.Errorf("role must be one of %s, %s or %s", RoleOwner, RoleEditor, RoleViewer)
+}
+
+// CanView will return true when the role can see the links of the workspace
+func (r Role) CanView() bool {
+	return r.Validate() == nil
+}
+
+// CanEdit will return true when the role can create, change and delete the links of the workspace
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// CanAdmin will return true when the role can manage the members and api keys of the workspace
+func (r Role) CanAdmin() bool {
+	return r == RoleOwner
+}
+
+// Principal is who a request acts as, the zero value is an anonymous request
+type Principal struct {
+	UserID      int64 // The logged in user, 0 for anonymous and api key requests
+	WorkspaceID int64 // The workspace the request acts in, 0 for the personal links of the user
+	Role        Role  // The role in the workspace, empty without a workspace
+	APIKeyID    int64 // The api key the request was authenticated with, 0 when it was not
+	Admin       bool  // The user is an admin of the service, who can take down any link
+}
+
+// InWorkspace will return true when the request acts in the workspace
+func (p Principal) InWorkspace(workspaceID int64) bool {
+	return workspaceID != 0 && p.WorkspaceID == workspaceID
+}
+
+type principalCtxKey struct{}
+
+// WithPrincipal will store who the request acts as in the context
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalCtxKey{}, p)
+}
+
+// FromContext will return who the request acts as, the anonymous principal when nothing was stored
+func FromContext(ctx context.Context) Principal {
+	p, _ := ctx.Value(principalCtxKey{}).(Principal)
+	return p
+}
@@ -0,0 +1,161 @@
+package baseurl
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/realip"
+)
+
+// ErrInvalid is returned when the base url is not an absolute http or https url
+var ErrInvalid = errors.New("base url must be an absolute http or https url without a query or fragment")
+
+// BaseURL is the public url that short links and page links are built from, e.g. https://getsit.to or https://example.com/s.
+// The zero value has no configured url, links are then built from the proto and host of each request.
+type BaseURL struct {
+	url *url.URL
+}
+
+// New will parse the base url, an empty string returns a BaseURL that builds links from the request
+func New(raw string) (*BaseURL, error) {
+
+	if raw == "" {
+		return &BaseURL{}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, ErrInvalid
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return &BaseURL{url: u}, nil
+}
+
+// Configured will return true when a base url was set
+func (b *BaseURL) Configured() bool {
+	return b != nil && b.url != nil
+}
+
+// Prefix will return the path prefix of the base url without a trailing slash, or an empty string
+func (b *BaseURL) Prefix() string {
+	if !b.Configured() {
+		return ""
+	}
+	return b.url.Path
+}
+
+// Path will return the path p under the path prefix, p should start with a slash
+func (b *BaseURL) Path(p string) string {
+	return b.Prefix() + p
+}
+
+// For will return the base url for the request without a trailing slash.
+// The configured url is used when set, otherwise it is built from the client proto and host.
+func (b *BaseURL) For(r *http.Request) string {
+	if b.Configured() {
+		return b.url.String()
+	}
+	client := realip.FromRequest(r)
+	return client.Proto + "://" + client.Host
+}
+
+// ShortURL will return the public short url for the token
+func (b *BaseURL) ShortURL(r *http.Request, token string) string {
+	return b.For(r) + "/" + token
+}
+
+// ShortURLOn will return the public short url for the token on the short domain host, an empty host is the base url.
+// Short domains have no path prefix and use the scheme of the base url.
+func (b *BaseURL) ShortURLOn(r *http.Request, host string, token string) string {
+	if host == "" {
+		return b.ShortURL(r, token)
+	}
+	scheme := realip.FromRequest(r).Proto
+	if b.Configured() {
+		scheme = b.url.Scheme
+	}
+	return scheme + "://" + host + "/" + token
+}
+
+// StripPrefix will serve h with the path prefix removed from the request path.
+// The prefix itself is redirected to the prefix with a trailing slash and paths outside of it are not found.
+func (b *BaseURL) StripPrefix(h http.Handler) http.Handler {
+	prefix := b.Prefix()
+	if prefix == "" {
+		return h
+	}
+	stripped := http.StripPrefix(prefix, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == prefix {
+			target := prefix + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+			http.NotFound(w, r)
+			return
+		}
+		stripped.ServeHTTP(w, r)
+	})
+}
+
+// CanonicalHost will redirect requests that arrive on a host other than the base url host.
+// Reads are redirected to the same path on the base url host, other methods are rejected with a 421.
+// The health probes are always served so they can be reached on internal hostnames.
+func (b *BaseURL) CanonicalHost(next http.Handler) http.Handler {
+	if !b.Configured() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if strings.EqualFold(realip.FromRequest(r).Host, b.url.Host) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		switch strings.TrimPrefix(r.URL.Path, b.Prefix()) {
+		case "/healthz", "/readyz":
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "Misdirected request, use "+b.url.String(), http.StatusMisdirectedRequest)
+			return
+		}
+
+		target := url.URL{
+			Scheme:   b.url.Scheme,
+			Host:     b.url.Host,
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// ShortDomains will serve the requests that arrive on one of the short domain hosts with short, they are not under the
+// path prefix and are not redirected to the base url host. Requests on every other host are served by next.
+func ShortDomains(hosts []string, short http.Handler, next http.Handler) http.Handler {
+	if len(hosts) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := realip.FromRequest(r).Host
+		for _, h := range hosts {
+			if strings.EqualFold(host, h) {
+				short.ServeHTTP(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
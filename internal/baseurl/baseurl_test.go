@@ -0,0 +1,184 @@
+package baseurl_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/baseurl"
+)
+
+func TestNew(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		raw        string
+		wantErr    bool
+		wantPrefix string
+	}{
+		{name: "empty", raw: "", wantPrefix: ""},
+		{name: "root", raw: "https://getsit.to", wantPrefix: ""},
+		{name: "root with slash", raw: "https://getsit.to/", wantPrefix: ""},
+		{name: "path prefix", raw: "https://example.com/s/", wantPrefix: "/s"},
+		{name: "relative", raw: "/s", wantErr: true},
+		{name: "other scheme", raw: "ftp://getsit.to", wantErr: true},
+		{name: "query", raw: "https://getsit.to?a=b", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := baseurl.New(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := b.Prefix(); got != tt.wantPrefix {
+				t.Errorf("Prefix() = %v, want %v", got, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestBaseURL_ShortURL(t *testing.T) {
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "from request", raw: "", want: "http://internal:8080/abc"},
+		{name: "configured", raw: "https://getsit.to", want: "https://getsit.to/abc"},
+		{name: "configured with prefix", raw: "https://example.com/s", want: "https://example.com/s/abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := baseurl.New(tt.raw)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "http://internal:8080/i/abc", nil)
+			if got := b.ShortURL(r, "abc"); got != tt.want {
+				t.Errorf("ShortURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseURL_ShortURLOn(t *testing.T) {
+
+	tests := []struct {
+		name string
+		raw  string
+		host string
+		want string
+	}{
+		{name: "base url from request", raw: "", want: "http://internal:8080/abc"},
+		{name: "base url configured with prefix", raw: "https://example.com/s", want: "https://example.com/s/abc"},
+		{name: "short domain from request", raw: "", host: "acme.link", want: "http://acme.link/abc"},
+		{name: "short domain configured with prefix", raw: "https://example.com/s", host: "acme.link", want: "https://acme.link/abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := baseurl.New(tt.raw)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "http://internal:8080/i/abc", nil)
+			if got := b.ShortURLOn(r, tt.host, "abc"); got != tt.want {
+				t.Errorf("ShortURLOn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseURL_Handlers(t *testing.T) {
+
+	b, err := baseurl.New("https://example.com/s")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	h := b.CanonicalHost(b.StripPrefix(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	})))
+	h = baseurl.ShortDomains([]string{"acme.link"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("short " + r.URL.Path))
+	}), h)
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:       "canonical host is served without the prefix",
+			method:     http.MethodGet,
+			target:     "http://example.com/s/i/abc",
+			wantStatus: http.StatusOK,
+			wantBody:   "/i/abc",
+		},
+		{
+			name:         "prefix without a slash is redirected",
+			method:       http.MethodGet,
+			target:       "http://example.com/s",
+			wantStatus:   http.StatusMovedPermanently,
+			wantLocation: "/s/",
+		},
+		{
+			name:       "outside of the prefix is not found",
+			method:     http.MethodGet,
+			target:     "http://example.com/abc",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:         "other host is redirected",
+			method:       http.MethodGet,
+			target:       "http://internal:8080/s/abc?x=1",
+			wantStatus:   http.StatusPermanentRedirect,
+			wantLocation: "https://example.com/s/abc?x=1",
+		},
+		{
+			name:       "other host post is rejected",
+			method:     http.MethodPost,
+			target:     "http://internal:8080/s/create",
+			wantStatus: http.StatusMisdirectedRequest,
+		},
+		{
+			name:       "short domain is served without the prefix",
+			method:     http.MethodGet,
+			target:     "http://ACME.link/abc",
+			wantStatus: http.StatusOK,
+			wantBody:   "short /abc",
+		},
+		{
+			name:       "probes are served on any host",
+			method:     http.MethodGet,
+			target:     "http://10.0.0.5:8080/s/readyz",
+			wantStatus: http.StatusOK,
+			wantBody:   "/readyz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantLocation != "" && rec.Header().Get("Location") != tt.wantLocation {
+				t.Errorf("Location = %v, want %v", rec.Header().Get("Location"), tt.wantLocation)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %v, want %v", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
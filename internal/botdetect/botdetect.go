@@ -0,0 +1,80 @@
+package botdetect
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Reason describes why a request was classified as a bot
+type Reason string
+
+const (
+	ReasonNone             Reason = ""
+	ReasonUserAgent        Reason = "user-agent"
+	ReasonEmptyUserAgent   Reason = "empty-user-agent"
+	ReasonHeadRequest      Reason = "head-request"
+	ReasonPrefetch         Reason = "prefetch"
+	ReasonNoAcceptLanguage Reason = "no-accept-language"
+)
+
+// Classification is the result of classifying a request
+type Classification struct {
+	IsBot  bool
+	Reason Reason
+}
+
+// IsBotUserAgent will check the user agent against the list of known bot patterns
+func IsBotUserAgent(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, pattern := range userAgentPatterns {
+		if strings.Contains(ua, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classify will decide if the request was made by a bot, crawler, link unfurler or prefetcher.
+// The user agent is checked first, then the request is checked against a set of heuristics
+// that real browsers following a link will not trigger.
+func Classify(r *http.Request) Classification {
+
+	userAgent := strings.TrimSpace(r.UserAgent())
+	if userAgent == "" {
+		return Classification{IsBot: true, Reason: ReasonEmptyUserAgent}
+	}
+
+	if IsBotUserAgent(userAgent) {
+		return Classification{IsBot: true, Reason: ReasonUserAgent}
+	}
+
+	if r.Method == http.MethodHead {
+		return Classification{IsBot: true, Reason: ReasonHeadRequest}
+	}
+
+	if isPrefetch(r) {
+		return Classification{IsBot: true, Reason: ReasonPrefetch}
+	}
+
+	if r.Header.Get("Accept-Language") == "" {
+		return Classification{IsBot: true, Reason: ReasonNoAcceptLanguage}
+	}
+
+	return Classification{IsBot: false, Reason: ReasonNone}
+}
+
+// IsBot is a convenience function that will return true if the request was classified as a bot
+func IsBot(r *http.Request) bool {
+	return Classify(r).IsBot
+}
+
+// isPrefetch will check the headers browsers use to mark speculative prefetch and preview requests
+func isPrefetch(r *http.Request) bool {
+	for _, header := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(r.Header.Get(header))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "preview") || strings.Contains(value, "prerender") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package botdetect_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/botdetect"
+)
+
+const chromeUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+
+func TestIsBotUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      bool
+	}{
+		{
+			name:      "chrome desktop",
+			userAgent: chromeUserAgent,
+			want:      false,
+		},
+		{
+			name:      "safari iphone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			want:      false,
+		},
+		{
+			name:      "slack unfurler",
+			userAgent: "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			want:      true,
+		},
+		{
+			name:      "twitter unfurler",
+			userAgent: "Twitterbot/1.0",
+			want:      true,
+		},
+		{
+			name:      "imessage preview",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_11_1) AppleWebKit/601.2.4 (KHTML, like Gecko) Version/9.0.1 Safari/601.2.4 facebookexternalhit/1.1 Facebot Twitterbot/1.0",
+			want:      true,
+		},
+		{
+			name:      "googlebot",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      true,
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/8.6.0",
+			want:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := botdetect.IsBotUserAgent(tt.userAgent); got != tt.want {
+				t.Errorf("IsBotUserAgent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    botdetect.Classification
+	}{
+		{
+			name:   "browser request",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent":      chromeUserAgent,
+				"Accept-Language": "en-US,en;q=0.9",
+			},
+			want: botdetect.Classification{IsBot: false, Reason: botdetect.ReasonNone},
+		},
+		{
+			name:    "empty user agent",
+			method:  http.MethodGet,
+			headers: map[string]string{},
+			want:    botdetect.Classification{IsBot: true, Reason: botdetect.ReasonEmptyUserAgent},
+		},
+		{
+			name:   "bot user agent",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent":      "Discordbot/2.0",
+				"Accept-Language": "en-US",
+			},
+			want: botdetect.Classification{IsBot: true, Reason: botdetect.ReasonUserAgent},
+		},
+		{
+			name:   "head request",
+			method: http.MethodHead,
+			headers: map[string]string{
+				"User-Agent":      chromeUserAgent,
+				"Accept-Language": "en-US",
+			},
+			want: botdetect.Classification{IsBot: true, Reason: botdetect.ReasonHeadRequest},
+		},
+		{
+			name:   "purpose prefetch",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent":      chromeUserAgent,
+				"Accept-Language": "en-US",
+				"Purpose":         "prefetch",
+			},
+			want: botdetect.Classification{IsBot: true, Reason: botdetect.ReasonPrefetch},
+		},
+		{
+			name:   "sec purpose prefetch",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent":      chromeUserAgent,
+				"Accept-Language": "en-US",
+				"Sec-Purpose":     "prefetch;prerender",
+			},
+			want: botdetect.Classification{IsBot: true, Reason: botdetect.ReasonPrefetch},
+		},
+		{
+			name:   "missing accept language",
+			method: http.MethodGet,
+			headers: map[string]string{
+				"User-Agent": chromeUserAgent,
+			},
+			want: botdetect.Classification{IsBot: true, Reason: botdetect.ReasonNoAcceptLanguage},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/abc12345", nil)
+			r.Header.Del("User-Agent")
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := botdetect.Classify(r); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
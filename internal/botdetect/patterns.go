@@ -0,0 +1,80 @@
+package botdetect
+
+// userAgentPatterns is the list of lower case substrings that identify bots, crawlers, link unfurlers
+// and scripted http clients. Keep this list sorted by category so new entries are easy to place.
+var userAgentPatterns = []string{
+	// generic markers used by most well behaved crawlers
+	"bot",
+	"crawl",
+	"spider",
+	"slurp",
+	"scraper",
+	"preview",
+	"fetcher",
+	"monitor",
+	"headless",
+
+	// chat and social link unfurlers
+	"slackbot",
+	"slack-imgproxy",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebookcatalog",
+	"facebot",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"microsoft teams",
+	"mattermost",
+	"redditbot",
+	"pinterest",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"snapchat",
+	"bitlybot",
+	"google-pagerenderer",
+
+	// search engines
+	"googlebot",
+	"bingbot",
+	"bingpreview",
+	"duckduckbot",
+	"baiduspider",
+	"yandex",
+	"applebot",
+	"petalbot",
+	"seznambot",
+	"sogou",
+
+	// seo and monitoring services
+	"ahrefs",
+	"semrush",
+	"mj12bot",
+	"dotbot",
+	"uptimerobot",
+	"pingdom",
+	"statuscake",
+	"site24x7",
+
+	// scripted http clients
+	"curl/",
+	"wget/",
+	"python-requests",
+	"python-urllib",
+	"aiohttp",
+	"httpx",
+	"go-http-client",
+	"java/",
+	"okhttp",
+	"axios/",
+	"node-fetch",
+	"undici",
+	"libwww-perl",
+	"php/",
+	"ruby",
+	"postmanruntime",
+	"insomnia",
+}
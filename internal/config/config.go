@@ -0,0 +1,466 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/oidc"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/secheaders"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/url/entity"
+)
+
+// ErrValidation is returned when one or more settings are not valid
+var ErrValidation = errors.New("invalid configuration")
+
+// ErrPrintConfig is returned by Load with the configuration when --print-config is set, the caller should print it and exit
+var ErrPrintConfig = errors.New("print config requested")
+
+// Config is the configuration shared by every getsit command.
+//
+// Each setting can be set from, in order of precedence, a command line flag, an environment
+// variable, the config file and finally the default. The flag name is derived from the file key,
+// e.g. server.read_timeout is --server-read-timeout.
+type Config struct {
+	BaseURL       string `toml:"base_url" yaml:"base_url" env:"BASE_URL" desc:"public base url used for short links, e.g. https://getsit.to"`
+	SessionSecret string `toml:"session_secret" yaml:"session_secret" env:"SESSION_SECRET" secret:"true" desc:"secret used to sign the web session cookies and to hash the reporters of links"`
+	TokenStrategy string `toml:"token_strategy" yaml:"token_strategy" env:"TOKEN_STRATEGY" desc:"characters used for new tokens, random or readable"`
+	Migrate       bool   `toml:"migrate" yaml:"migrate" env:"MIGRATE" desc:"apply pending migrations before serving, replicas take turns on a postgres advisory lock"`
+
+	Domains []string `toml:"domains" yaml:"domains" env:"SHORT_DOMAINS" desc:"comma separated short domains links can also be created on, each a host or host=fallback url for unknown tokens"`
+
+	Database   DatabaseConfig   `toml:"database" yaml:"database"`
+	Server     ServerConfig     `toml:"server" yaml:"server"`
+	Metrics    MetricsConfig    `toml:"metrics" yaml:"metrics"`
+	Tracing    TracingConfig    `toml:"tracing" yaml:"tracing"`
+	GeoIP      GeoIPConfig      `toml:"geoip" yaml:"geoip"`
+	RateLimit  RateLimitConfig  `toml:"rate_limit" yaml:"rate_limit"`
+	PoW        PoWConfig        `toml:"proof_of_work" yaml:"proof_of_work"`
+	Security   SecurityConfig   `toml:"security" yaml:"security"`
+	Mail       MailConfig       `toml:"mail" yaml:"mail"`
+	OIDC       OIDCConfig       `toml:"oidc" yaml:"oidc"`
+	Moderation ModerationConfig `toml:"moderation" yaml:"moderation"`
+
+	// PrintConfig is only set by the --print-config flag
+	PrintConfig bool `toml:"-" yaml:"-"`
+}
+
+// DatabaseConfig is the configuration for the postgres connection pool
+type DatabaseConfig struct {
+	URL      string `toml:"url" yaml:"url" env:"DATABASE_URL" secret:"url" desc:"postgres connection url"`
+	MaxConns int    `toml:"max_conns" yaml:"max_conns" env:"DATABASE_MAX_CONNS" desc:"maximum number of connections in the pool"`
+	MinConns int    `toml:"min_conns" yaml:"min_conns" env:"DATABASE_MIN_CONNS" desc:"minimum number of idle connections kept in the pool"`
+}
+
+// ServerConfig is the configuration for the http servers
+type ServerConfig struct {
+	Host              string        `toml:"host" yaml:"host" env:"HOST" desc:"host to listen on, empty listens on every interface"`
+	Port              int           `toml:"port" yaml:"port" env:"PORT" desc:"port to listen on"`
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout" yaml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" desc:"time allowed to read the request headers"`
+	ReadTimeout       time.Duration `toml:"read_timeout" yaml:"read_timeout" env:"SERVER_READ_TIMEOUT" desc:"time allowed to read the whole request"`
+	WriteTimeout      time.Duration `toml:"write_timeout" yaml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" desc:"time allowed to write the response"`
+	IdleTimeout       time.Duration `toml:"idle_timeout" yaml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" desc:"time to keep idle keep-alive connections open"`
+	ShutdownTimeout   time.Duration `toml:"shutdown_timeout" yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" desc:"time allowed for in-flight requests to finish on shutdown"`
+	ShutdownDelay     time.Duration `toml:"shutdown_delay" yaml:"shutdown_delay" env:"SHUTDOWN_DELAY" desc:"time to keep serving with /readyz failing before the listener is closed"`
+	APIAddr           string        `toml:"api_addr" yaml:"api_addr" env:"API_ADDR" desc:"address of a separate api listener for serve all, empty mounts the api under /api"`
+	TrustedProxies    []string      `toml:"trusted_proxies" yaml:"trusted_proxies" env:"TRUSTED_PROXIES" desc:"comma separated ips or cidrs of proxies allowed to set forwarding headers"`
+}
+
+// MetricsConfig is the configuration for the prometheus metrics listener
+type MetricsConfig struct {
+	Addr string `toml:"addr" yaml:"addr" env:"METRICS_ADDR" desc:"address of the metrics listener, empty disables it"`
+}
+
+// TracingConfig is the configuration for the opentelemetry tracer
+type TracingConfig struct {
+	Exporter     string `toml:"exporter" yaml:"exporter" env:"OTEL_TRACES_EXPORTER" desc:"span exporter, none, stdout or otlp"`
+	OTLPEndpoint string `toml:"otlp_endpoint" yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" desc:"otlp/http traces endpoint url"`
+}
+
+// GeoIPConfig is the configuration for the geoip lookups
+type GeoIPConfig struct {
+	DatabasePath string `toml:"database_path" yaml:"database_path" env:"GEOIP_DATABASE_PATH" desc:"path to a GeoIP2 or GeoLite2 City mmdb file, empty disables lookups"`
+}
+
+// RateLimitConfig is the configuration for the per client rate limits.
+// Each limit is the number of requests a client can burst, refilled over the period, a limit of 0 is not limited.
+type RateLimitConfig struct {
+	Enabled        bool          `toml:"enabled" yaml:"enabled" env:"RATE_LIMIT_ENABLED" desc:"limit link creation, redirects and not found requests per client"`
+	CreateLimit    int           `toml:"create_limit" yaml:"create_limit" env:"RATE_LIMIT_CREATE_LIMIT" desc:"links a client can create per create_period"`
+	CreatePeriod   time.Duration `toml:"create_period" yaml:"create_period" env:"RATE_LIMIT_CREATE_PERIOD" desc:"time for the create limit to refill"`
+	RedirectLimit  int           `toml:"redirect_limit" yaml:"redirect_limit" env:"RATE_LIMIT_REDIRECT_LIMIT" desc:"redirects a client can follow per redirect_period"`
+	RedirectPeriod time.Duration `toml:"redirect_period" yaml:"redirect_period" env:"RATE_LIMIT_REDIRECT_PERIOD" desc:"time for the redirect limit to refill"`
+	NotFoundLimit  int           `toml:"not_found_limit" yaml:"not_found_limit" env:"RATE_LIMIT_NOT_FOUND_LIMIT" desc:"unknown tokens a client can request per not_found_period"`
+	NotFoundPeriod time.Duration `toml:"not_found_period" yaml:"not_found_period" env:"RATE_LIMIT_NOT_FOUND_PERIOD" desc:"time for the not found limit to refill"`
+	AuthLimit      int           `toml:"auth_limit" yaml:"auth_limit" env:"RATE_LIMIT_AUTH_LIMIT" desc:"register, login and password reset attempts a client can make per auth_period"`
+	AuthPeriod     time.Duration `toml:"auth_period" yaml:"auth_period" env:"RATE_LIMIT_AUTH_PERIOD" desc:"time for the auth limit to refill"`
+}
+
+// PoWConfig is the configuration for the proof of work check on the web form
+type PoWConfig struct {
+	Difficulty    int           `toml:"difficulty" yaml:"difficulty" env:"POW_DIFFICULTY" desc:"leading zero bits a browser must find before creating a link, 0 disables the check"`
+	MaxDifficulty int           `toml:"max_difficulty" yaml:"max_difficulty" env:"POW_MAX_DIFFICULTY" desc:"highest difficulty the adaptive check will ask for"`
+	Threshold     int           `toml:"threshold" yaml:"threshold" env:"POW_THRESHOLD" desc:"links created per window before the difficulty goes up, 0 keeps it fixed"`
+	Window        time.Duration `toml:"window" yaml:"window" env:"POW_WINDOW" desc:"window recent link creations are counted over"`
+	TTL           time.Duration `toml:"ttl" yaml:"ttl" env:"POW_TTL" desc:"time a challenge can be solved and submitted in"`
+}
+
+// SecurityConfig is the configuration for the security headers of the web app
+type SecurityConfig struct {
+	ContentSecurityPolicy string        `toml:"content_security_policy" yaml:"content_security_policy" env:"CONTENT_SECURITY_POLICY" desc:"content security policy, {nonce} is replaced with the nonce of each request, empty disables it"`
+	CSPReportOnly         bool          `toml:"csp_report_only" yaml:"csp_report_only" env:"CSP_REPORT_ONLY" desc:"send the content security policy as report only"`
+	HSTSMaxAge            time.Duration `toml:"hsts_max_age" yaml:"hsts_max_age" env:"HSTS_MAX_AGE" desc:"strict transport security max age sent over https, 0 disables it"`
+	ReferrerPolicy        string        `toml:"referrer_policy" yaml:"referrer_policy" env:"REFERRER_POLICY" desc:"referrer policy header, empty disables it"`
+}
+
+// MailConfig is the configuration for the emails sent to users
+type MailConfig struct {
+	From         string `toml:"from" yaml:"from" env:"MAIL_FROM" desc:"address emails are sent from"`
+	SMTPHost     string `toml:"smtp_host" yaml:"smtp_host" env:"SMTP_HOST" desc:"smtp server host, empty writes emails to the log instead"`
+	SMTPPort     int    `toml:"smtp_port" yaml:"smtp_port" env:"SMTP_PORT" desc:"smtp server port"`
+	SMTPUsername string `toml:"smtp_username" yaml:"smtp_username" env:"SMTP_USERNAME" desc:"smtp auth username, empty sends without auth"`
+	SMTPPassword string `toml:"smtp_password" yaml:"smtp_password" env:"SMTP_PASSWORD" secret:"true" desc:"smtp auth password"`
+}
+
+// OIDCConfig is the configuration for logging in with an OpenID Connect identity provider
+type OIDCConfig struct {
+	Issuer        string   `toml:"issuer" yaml:"issuer" env:"OIDC_ISSUER" desc:"issuer url of the identity provider, empty disables single sign on"`
+	ClientID      string   `toml:"client_id" yaml:"client_id" env:"OIDC_CLIENT_ID" desc:"client id registered with the identity provider"`
+	ClientSecret  string   `toml:"client_secret" yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true" desc:"client secret, empty for a public client"`
+	Scopes        []string `toml:"scopes" yaml:"scopes" env:"OIDC_SCOPES" desc:"comma separated scopes to request, openid is always requested"`
+	GroupsClaim   string   `toml:"groups_claim" yaml:"groups_claim" env:"OIDC_GROUPS_CLAIM" desc:"id token claim with the groups of the user"`
+	AllowedGroups []string `toml:"allowed_groups" yaml:"allowed_groups" env:"OIDC_ALLOWED_GROUPS" desc:"comma separated groups allowed to log in, empty allows everyone"`
+	Name          string   `toml:"name" yaml:"name" env:"OIDC_NAME" desc:"name of the identity provider shown on the login button"`
+}
+
+// ModerationConfig is the configuration for the reports of abuse and the admins that review them
+type ModerationConfig struct {
+	AdminUserIDs    []string `toml:"admin_user_ids" yaml:"admin_user_ids" env:"ADMIN_USER_IDS" desc:"comma separated ids of the users that can review reported links and take links down"`
+	ReportThreshold int      `toml:"report_threshold" yaml:"report_threshold" env:"REPORT_THRESHOLD" desc:"reports from different reporters that put a link on hold until an admin reviews it, 0 never holds"`
+}
+
+// Default will return the configuration with every default applied
+func Default() *Config {
+	return &Config{
+		TokenStrategy: string(entity.TokenStrategyRandom),
+		Database: DatabaseConfig{
+			MaxConns: 10,
+			MinConns: 0,
+		},
+		Server: ServerConfig{
+			Port:              8080,
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       120 * time.Second,
+			ShutdownTimeout:   15 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter: string(tracing.ExporterNone),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:        true,
+			CreateLimit:    10,
+			CreatePeriod:   time.Minute,
+			RedirectLimit:  120,
+			RedirectPeriod: time.Minute,
+			NotFoundLimit:  20,
+			NotFoundPeriod: time.Minute,
+			AuthLimit:      10,
+			AuthPeriod:     time.Minute,
+		},
+		PoW: PoWConfig{
+			Difficulty:    16,
+			MaxDifficulty: 22,
+			Threshold:     30,
+			Window:        time.Minute,
+			TTL:           10 * time.Minute,
+		},
+		Security: SecurityConfig{
+			ContentSecurityPolicy: secheaders.DefaultContentSecurityPolicy,
+			HSTSMaxAge:            365 * 24 * time.Hour,
+			ReferrerPolicy:        "strict-origin-when-cross-origin",
+		},
+		Mail: MailConfig{
+			From:     "getsit <no-reply@localhost>",
+			SMTPPort: 587,
+		},
+		OIDC: OIDCConfig{
+			Scopes:      []string{"openid", "email", "profile"},
+			GroupsClaim: "groups",
+			Name:        "SSO",
+		},
+		Moderation: ModerationConfig{
+			ReportThreshold: 5,
+		},
+	}
+}
+
+// Addr will return the address the http server should listen on
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Server.Host, fmt.Sprint(c.Server.Port))
+}
+
+// Validate will check every setting and return all of the problems found wrapped in ErrValidation
+func (c *Config) Validate() error {
+
+	var problems []string
+
+	if c.Database.URL == "" {
+		problems = append(problems, "database.url is required")
+	}
+	if c.Database.MaxConns < 1 {
+		problems = append(problems, "database.max_conns must be at least 1")
+	}
+	if c.Database.MinConns < 0 || c.Database.MinConns > c.Database.MaxConns {
+		problems = append(problems, "database.min_conns must be between 0 and database.max_conns")
+	}
+
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "base_url must be an absolute http or https url")
+		} else if u.RawQuery != "" || u.Fragment != "" {
+			problems = append(problems, "base_url must not have a query or fragment")
+		}
+	}
+
+	if _, err := c.ShortDomains(); err != nil {
+		problems = append(problems, "domains: "+err.Error())
+	}
+
+	if err := entity.TokenStrategy(c.TokenStrategy).Validate(); err != nil {
+		problems = append(problems, "token_strategy: "+err.Error())
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		problems = append(problems, "server.port must be between 1 and 65535")
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	} {
+		if d <= 0 {
+			problems = append(problems, name+" must be greater than 0")
+		}
+	}
+	if c.Server.ShutdownDelay < 0 {
+		problems = append(problems, "server.shutdown_delay must not be negative")
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		problems = append(problems, "server.trusted_proxies: "+err.Error())
+	}
+
+	for name, limit := range map[string]int{
+		"rate_limit.create_limit":    c.RateLimit.CreateLimit,
+		"rate_limit.redirect_limit":  c.RateLimit.RedirectLimit,
+		"rate_limit.not_found_limit": c.RateLimit.NotFoundLimit,
+		"rate_limit.auth_limit":      c.RateLimit.AuthLimit,
+	} {
+		if limit < 0 {
+			problems = append(problems, name+" must not be negative")
+		}
+	}
+	for name, d := range map[string]time.Duration{
+		"rate_limit.create_period":    c.RateLimit.CreatePeriod,
+		"rate_limit.redirect_period":  c.RateLimit.RedirectPeriod,
+		"rate_limit.not_found_period": c.RateLimit.NotFoundPeriod,
+		"rate_limit.auth_period":      c.RateLimit.AuthPeriod,
+	} {
+		if d <= 0 {
+			problems = append(problems, name+" must be greater than 0")
+		}
+	}
+
+	if c.PoW.Difficulty < 0 || c.PoW.Difficulty > 32 {
+		problems = append(problems, "proof_of_work.difficulty must be between 0 and 32")
+	}
+	if c.PoW.MaxDifficulty < c.PoW.Difficulty || c.PoW.MaxDifficulty > 32 {
+		problems = append(problems, "proof_of_work.max_difficulty must be between proof_of_work.difficulty and 32")
+	}
+	if c.PoW.Threshold < 0 {
+		problems = append(problems, "proof_of_work.threshold must not be negative")
+	}
+	if c.PoW.Window <= 0 || c.PoW.TTL <= 0 {
+		problems = append(problems, "proof_of_work.window and proof_of_work.ttl must be greater than 0")
+	}
+
+	if c.Security.HSTSMaxAge < 0 {
+		problems = append(problems, "security.hsts_max_age must not be negative")
+	}
+
+	if c.Mail.SMTPHost != "" {
+		if c.Mail.SMTPPort < 1 || c.Mail.SMTPPort > 65535 {
+			problems = append(problems, "mail.smtp_port must be between 1 and 65535")
+		}
+		if _, err := mail.ParseAddress(c.Mail.From); err != nil {
+			problems = append(problems, "mail.from must be an email address")
+		}
+	}
+
+	if c.OIDC.Issuer != "" {
+		u, err := url.Parse(c.OIDC.Issuer)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, "oidc.issuer must be an absolute http or https url")
+		}
+		if c.OIDC.ClientID == "" {
+			problems = append(problems, "oidc.client_id is required with oidc.issuer")
+		}
+		if c.OIDC.GroupsClaim == "" && len(c.OIDC.AllowedGroups) > 0 {
+			problems = append(problems, "oidc.groups_claim is required with oidc.allowed_groups")
+		}
+	}
+
+	if _, err := c.AdminUserIDs(); err != nil {
+		problems = append(problems, "moderation.admin_user_ids: "+err.Error())
+	}
+	if c.Moderation.ReportThreshold < 0 {
+		problems = append(problems, "moderation.report_threshold must not be negative")
+	}
+
+	switch tracing.Exporter(c.Tracing.Exporter) {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		problems = append(problems, "tracing.exporter must be one of none, stdout or otlp")
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return fmt.Errorf("%w:\n  %s", ErrValidation, strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+// OIDCProvider will return the identity provider users can log in with, nil when single sign on is disabled
+func (c *Config) OIDCProvider() *oidc.Provider {
+	if c.OIDC.Issuer == "" {
+		return nil
+	}
+	return oidc.NewProvider(oidc.Config{
+		Issuer:        c.OIDC.Issuer,
+		ClientID:      c.OIDC.ClientID,
+		ClientSecret:  c.OIDC.ClientSecret,
+		Scopes:        c.OIDC.Scopes,
+		GroupsClaim:   c.OIDC.GroupsClaim,
+		AllowedGroups: c.OIDC.AllowedGroups,
+	}, nil)
+}
+
+// RateLimitPolicies will return the rate limit policies for the apps
+func (c *Config) RateLimitPolicies() ratelimit.Policies {
+	return ratelimit.Policies{
+		Create:   ratelimit.Policy{Name: "create", Limit: c.RateLimit.CreateLimit, Period: c.RateLimit.CreatePeriod},
+		Redirect: ratelimit.Policy{Name: "redirect", Limit: c.RateLimit.RedirectLimit, Period: c.RateLimit.RedirectPeriod},
+		NotFound: ratelimit.Policy{Name: "not_found", Limit: c.RateLimit.NotFoundLimit, Period: c.RateLimit.NotFoundPeriod},
+		Auth:     ratelimit.Policy{Name: "auth", Limit: c.RateLimit.AuthLimit, Period: c.RateLimit.AuthPeriod},
+	}
+}
+
+// SecurityHeaders will return the security headers configuration for the web app
+func (c *Config) SecurityHeaders() secheaders.Config {
+	return secheaders.Config{
+		ContentSecurityPolicy: c.Security.ContentSecurityPolicy,
+		ReportOnly:            c.Security.CSPReportOnly,
+		HSTSMaxAge:            c.Security.HSTSMaxAge,
+		ReferrerPolicy:        c.Security.ReferrerPolicy,
+	}
+}
+
+// ShortDomain is a short domain links can be created on besides the base url
+type ShortDomain struct {
+	Host        string
+	FallbackURL string // Where unknown tokens on the domain redirect to, empty shows the not found page
+}
+
+// ShortDomains will parse the short domains, each is a host optionally followed by = and the fallback url.
+// The host of the base url can not be a short domain as its tokens are the links without a domain.
+func (c *Config) ShortDomains() ([]ShortDomain, error) {
+
+	baseHost := ""
+	if u, err := url.Parse(c.BaseURL); err == nil {
+		baseHost = entity.NormalizeHost(u.Host)
+	}
+
+	var domains []ShortDomain
+	seen := make(map[string]bool)
+	for _, d := range c.Domains {
+		host, fallback, _ := strings.Cut(d, "=")
+		host = entity.NormalizeHost(host)
+		fallback = strings.TrimSpace(fallback)
+		if host == "" && fallback == "" {
+			continue
+		}
+		if err := entity.ValidateHost(host); err != nil {
+			return nil, err
+		}
+		if host == baseHost {
+			return nil, fmt.Errorf("%q is the host of the base url", host)
+		}
+		if seen[host] {
+			return nil, fmt.Errorf("%q is listed more than once", host)
+		}
+		seen[host] = true
+		if fallback != "" {
+			u, err := url.Parse(fallback)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("fallback url of %q must be an absolute http or https url", host)
+			}
+		}
+		domains = append(domains, ShortDomain{Host: host, FallbackURL: fallback})
+	}
+	return domains, nil
+}
+
+// AdminUserIDs will parse the ids of the admin users.
+// Admins are pinned by id rather than email, as registering with an email does not prove it is owned.
+func (c *Config) AdminUserIDs() ([]int64, error) {
+	var ids []int64
+	for _, raw := range c.Moderation.AdminUserIDs {
+		id, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil || id < 1 {
+			return nil, fmt.Errorf("%q is not a user id", raw)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// TrustedProxyPrefixes will parse the trusted proxies into network prefixes.
+// A single ip address is treated as a /32 or /128 prefix.
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, p := range c.Server.TrustedProxies {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !strings.Contains(p, "/") {
+			addr, err := netip.ParseAddr(p)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an ip address or cidr", p)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an ip address or cidr", p)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,262 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv is the environment variable that can point at the config file instead of --config
+const ConfigFileEnv = "GETSIT_CONFIG"
+
+// setting is a single leaf of the Config struct that can be set from every source
+type setting struct {
+	key    string        // The dotted file key, e.g. server.read_timeout
+	flag   string        // The flag name, e.g. server-read-timeout
+	env    string        // The environment variable, e.g. SERVER_READ_TIMEOUT
+	desc   string        // The usage shown in --help
+	secret string        // How to redact the value, empty, true or url
+	value  reflect.Value // The addressable field in the Config
+}
+
+// settings will walk the Config struct and return every leaf setting
+func settings(c *Config) []setting {
+	var out []setting
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			key := f.Tag.Get("toml")
+			if key == "" || key == "-" {
+				continue
+			}
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if f.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), key)
+				continue
+			}
+			out = append(out, setting{
+				key:    key,
+				flag:   strings.NewReplacer(".", "-", "_", "-").Replace(key),
+				env:    f.Tag.Get("env"),
+				desc:   f.Tag.Get("desc"),
+				secret: f.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return out
+}
+
+// set will parse the string into the setting value based on its type
+func (s setting) set(raw string) error {
+	switch s.value.Interface().(type) {
+	case string:
+		s.value.SetString(raw)
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be a whole number", s.key)
+		}
+		s.value.SetInt(int64(n))
+	case bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be true or false", s.key)
+		}
+		s.value.SetBool(b)
+	case time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s must be a duration like 30s or 1m", s.key)
+		}
+		s.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		s.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("%s has an unsupported type %s", s.key, s.value.Type())
+	}
+	return nil
+}
+
+// Load will build the configuration from the defaults, the config file, the environment and the flags,
+// in increasing order of precedence, and then validate it.
+//
+// Every setting is registered as a flag on fs, along with --config and --print-config, so commands
+// can register their own flags on fs before calling Load. A .env file in the working directory is
+// loaded into the environment first, without overriding variables that are already set.
+//
+// When --print-config is set the configuration is returned before it is validated along with ErrPrintConfig,
+// so the command can print it and exit.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+
+	godotenv.Load()
+
+	cfg := Default()
+	all := settings(cfg)
+
+	// flags are collected first so --config can be found, then applied after the file and env
+	flagValues := make(map[string]string)
+	var configFile string
+	fs.StringVar(&configFile, "config", os.Getenv(ConfigFileEnv), "path to a toml or yaml config file (env "+ConfigFileEnv+")")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the resolved configuration with secrets redacted and exit")
+	for _, s := range all {
+		name := s.flag
+		usage := s.desc
+		if s.env != "" {
+			usage += " (env " + s.env + ")"
+		}
+		setFlag := func(v string) error {
+			flagValues[name] = v
+			return nil
+		}
+		// bool settings can be passed as a bare --flag
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(name, usage, setFlag)
+			continue
+		}
+		fs.Func(name, usage, setFlag)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if configFile != "" {
+		if err := loadFile(cfg, configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range all {
+		if s.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range all {
+		if v, ok := flagValues[s.flag]; ok {
+			if err := s.set(v); err != nil {
+				return nil, fmt.Errorf("--%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	if cfg.PrintConfig {
+		return cfg, ErrPrintConfig
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile will decode the toml or yaml file over the config, keys that are not in the file keep their value
+func loadFile(cfg *Config, path string) error {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown key %q in config file %s", undecoded[0].String(), path)
+		}
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(strings.NewReader(string(data)))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && err != io.EOF {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config file %s must have a .toml, .yaml or .yml extension", path)
+	}
+
+	return nil
+}
+
+// Redacted will return a copy of the config with every secret replaced.
+// Urls only have their password replaced so the host and database stay visible.
+func (c *Config) Redacted() *Config {
+	cp := *c
+	cp.Server.TrustedProxies = append([]string(nil), c.Server.TrustedProxies...)
+	cp.OIDC.Scopes = append([]string(nil), c.OIDC.Scopes...)
+	cp.OIDC.AllowedGroups = append([]string(nil), c.OIDC.AllowedGroups...)
+	cp.Moderation.AdminUserIDs = append([]string(nil), c.Moderation.AdminUserIDs...)
+	for _, s := range settings(&cp) {
+		if s.secret == "" || s.value.String() == "" {
+			continue
+		}
+		if s.secret == "url" {
+			s.value.SetString(redactURL(s.value.String()))
+			continue
+		}
+		s.value.SetString("REDACTED")
+	}
+	return &cp
+}
+
+// Print will write the redacted configuration to w in the toml config file format
+func (c *Config) Print(w io.Writer) error {
+	return toml.NewEncoder(w).Encode(c.Redacted())
+}
+
+// redactURL will replace the password in a url, or the whole url if it can not be parsed
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" {
+		// keyword/value connection strings can not be partially redacted
+		return "REDACTED"
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "REDACTED")
+	}
+	return u.String()
+}
+
+// NewPool will create a pgx pool from the database settings
+func (c *Config) NewPool(ctx context.Context) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(c.Database.URL)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MaxConns = int32(c.Database.MaxConns)
+	poolCfg.MinConns = int32(c.Database.MinConns)
+	return pgxpool.NewWithConfig(ctx, poolCfg)
+}
@@ -0,0 +1,96 @@
+package geoip
+
+import (
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Location is the location that was resolved for an ip address.
+// Any field can be empty when the database does not have the data.
+type Location struct {
+	Country string // The ISO 3166-1 alpha-2 country code
+	Region  string // The name of the first level subdivision, e.g. a state or province
+	City    string // The name of the city
+}
+
+// record is the subset of the GeoIP2/GeoLite2 City record that we decode
+type record struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		IsoCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+// Service resolves ip addresses to a location using a local MMDB file.
+// A Service without a database is valid and resolves every address to an empty Location.
+type Service struct {
+	db *maxminddb.Reader
+}
+
+// NewService will open the MMDB file at the path. If the path is empty the service is
+// returned without a database so lookups degrade to an empty location.
+func NewService(path string) (*Service, error) {
+	if path == "" {
+		return &Service{}, nil
+	}
+
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db: db,
+	}, nil
+}
+
+// Enabled will return true if the service has a database to resolve locations with
+func (s *Service) Enabled() bool {
+	return s != nil && s.db != nil
+}
+
+// Lookup will resolve the ip address to a location. Invalid, private or unknown addresses,
+// and lookups made without a database, resolve to an empty Location.
+func (s *Service) Lookup(ip string) Location {
+	if !s.Enabled() {
+		return Location{}
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Location{}
+	}
+
+	var rec record
+	if err := s.db.Lookup(addr.Unmap()).Decode(&rec); err != nil {
+		return Location{}
+	}
+
+	loc := Location{
+		Country: rec.Country.IsoCode,
+		City:    rec.City.Names["en"],
+	}
+	if len(rec.Subdivisions) > 0 {
+		loc.Region = rec.Subdivisions[0].Names["en"]
+		if loc.Region == "" {
+			loc.Region = rec.Subdivisions[0].IsoCode
+		}
+	}
+
+	return loc
+}
+
+// Close will close the underlying database if one was opened
+func (s *Service) Close() error {
+	if !s.Enabled() {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,141 @@
+package geoip_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+
+	"github.com/griggsjared/getsit/internal/geoip"
+)
+
+// writeFixture will generate a small City database with a couple of networks and return its path
+func writeFixture(t *testing.T) string {
+	t.Helper()
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "GeoLite2-City",
+		RecordSize:   24,
+	})
+	if err != nil {
+		t.Fatalf("mmdbwriter.New() error = %v", err)
+	}
+
+	networks := map[string]mmdbtype.Map{
+		"81.2.69.0/24": {
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("GB")},
+			"subdivisions": mmdbtype.Slice{
+				mmdbtype.Map{"iso_code": mmdbtype.String("ENG"), "names": mmdbtype.Map{"en": mmdbtype.String("England")}},
+			},
+			"city": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("London")}},
+		},
+		"2001:218::/32": {
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("JP")},
+		},
+	}
+
+	for cidr, data := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("ParseCIDR() error = %v", err)
+		}
+		if err := tree.Insert(network, data); err != nil {
+			t.Fatalf("Insert() error = %v", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "fixture.mmdb")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	defer f.Close()
+
+	if _, err := tree.WriteTo(f); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+
+	return path
+}
+
+func TestService_NewService(t *testing.T) {
+
+	s, err := geoip.NewService("")
+	if err != nil {
+		t.Errorf("NewService() error = %v", err)
+	}
+	if s.Enabled() {
+		t.Errorf("Enabled() = %v, want false without a database", s.Enabled())
+	}
+
+	_, err = geoip.NewService(filepath.Join(t.TempDir(), "missing.mmdb"))
+	if err == nil {
+		t.Errorf("NewService() expected an error for a missing database")
+	}
+}
+
+func TestService_Lookup(t *testing.T) {
+
+	s, err := geoip.NewService(writeFixture(t))
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+	defer s.Close()
+
+	tests := []struct {
+		name string
+		ip   string
+		want geoip.Location
+	}{
+		{
+			name: "ipv4 with city",
+			ip:   "81.2.69.160",
+			want: geoip.Location{Country: "GB", Region: "England", City: "London"},
+		},
+		{
+			name: "ipv4 mapped ipv6",
+			ip:   "::ffff:81.2.69.160",
+			want: geoip.Location{Country: "GB", Region: "England", City: "London"},
+		},
+		{
+			name: "ipv6 with country only",
+			ip:   "2001:218::1",
+			want: geoip.Location{Country: "JP"},
+		},
+		{
+			name: "unknown address",
+			ip:   "8.8.8.8",
+			want: geoip.Location{},
+		},
+		{
+			name: "invalid address",
+			ip:   "not-an-ip",
+			want: geoip.Location{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Lookup(tt.ip); got != tt.want {
+				t.Errorf("Lookup() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_LookupWithoutDatabase(t *testing.T) {
+
+	s, err := geoip.NewService("")
+	if err != nil {
+		t.Fatalf("NewService() error = %v", err)
+	}
+
+	if got := s.Lookup("81.2.69.160"); got != (geoip.Location{}) {
+		t.Errorf("Lookup() = %+v, want an empty location", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrDraining is returned from Ready once the server has started to shut down
+var ErrDraining = errors.New("server is draining")
+
+// Pinger is the interface for a database connection that can be pinged, e.g. a pgxpool.Pool
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// MigrationChecker is the interface that checks the database schema is current
+type MigrationChecker interface {
+	Check(ctx context.Context) error
+}
+
+// Checker reports if the application is ready to serve traffic
+type Checker struct {
+	db         Pinger
+	migrations MigrationChecker
+	draining   atomic.Bool
+	migrated   atomic.Bool // the schema does not go backwards while running, so a current schema is only checked once
+}
+
+// NewChecker will create a new readiness checker. The migration checker is optional.
+func NewChecker(db Pinger, migrations MigrationChecker) *Checker {
+	return &Checker{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Drain will flip the checker to not ready so load balancers stop sending new requests
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Draining will return true once Drain has been called
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Ready will return nil if the server is not draining, the database can be reached and the schema is current
+func (c *Checker) Ready(ctx context.Context) error {
+
+	if c.Draining() {
+		return ErrDraining
+	}
+
+	if err := c.db.Ping(ctx); err != nil {
+		return fmt.Errorf("database is not reachable: %w", err)
+	}
+
+	if c.migrations != nil && !c.migrated.Load() {
+		if err := c.migrations.Check(ctx); err != nil {
+			return err
+		}
+		c.migrated.Store(true)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/health"
+)
+
+type fakePinger struct {
+	err error
+}
+
+func (p *fakePinger) Ping(ctx context.Context) error {
+	return p.err
+}
+
+type fakeMigrations struct {
+	err   error
+	calls int
+}
+
+func (m *fakeMigrations) Check(ctx context.Context) error {
+	m.calls++
+	return m.err
+}
+
+func TestChecker_Ready(t *testing.T) {
+
+	tests := []struct {
+		name       string
+		pingErr    error
+		migrateErr error
+		drain      bool
+		wantErr    bool
+	}{
+		{
+			name:    "ready",
+			wantErr: false,
+		},
+		{
+			name:    "database down",
+			pingErr: errors.New("connection refused"),
+			wantErr: true,
+		},
+		{
+			name:       "pending migrations",
+			migrateErr: errors.New("pending"),
+			wantErr:    true,
+		},
+		{
+			name:    "draining",
+			drain:   true,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := health.NewChecker(&fakePinger{err: tt.pingErr}, &fakeMigrations{err: tt.migrateErr})
+			if tt.drain {
+				c.Drain()
+			}
+			if err := c.Ready(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("Ready() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChecker_ReadyChecksMigrationsOnce(t *testing.T) {
+
+	m := &fakeMigrations{}
+	c := health.NewChecker(&fakePinger{}, m)
+
+	for i := 0; i < 3; i++ {
+		if err := c.Ready(context.Background()); err != nil {
+			t.Fatalf("Ready() error = %v", err)
+		}
+	}
+
+	if m.calls != 1 {
+		t.Errorf("Check() called %d times, want 1", m.calls)
+	}
+}
+
+func TestChecker_ReadyWithoutMigrations(t *testing.T) {
+
+	c := health.NewChecker(&fakePinger{}, nil)
+	if err := c.Ready(context.Background()); err != nil {
+		t.Errorf("Ready() error = %v", err)
+	}
+}
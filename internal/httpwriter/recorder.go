@@ -0,0 +1,33 @@
+package httpwriter
+
+import "net/http"
+
+// Recorder wraps a http.ResponseWriter to record the status code and whether the response has been started
+type Recorder struct {
+	http.ResponseWriter
+	Status  int  // The status code that was written, http.StatusOK until WriteHeader is called
+	Started bool // Whether the header or any of the body has been written
+}
+
+// NewRecorder will wrap the http.ResponseWriter in a Recorder
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+// WriteHeader will record the status code before writing it
+func (r *Recorder) WriteHeader(status int) {
+	r.Status = status
+	r.Started = true
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write will record that the response has been started before writing the body
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.Started = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap will return the underlying http.ResponseWriter for http.ResponseController
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,57 @@
+package httpwriter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/httpwriter"
+)
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     http.HandlerFunc
+		wantStatus  int
+		wantStarted bool
+	}{
+		{
+			name:        "nothing written",
+			handler:     func(w http.ResponseWriter, r *http.Request) {},
+			wantStatus:  http.StatusOK,
+			wantStarted: false,
+		},
+		{
+			name: "status written",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			wantStatus:  http.StatusNotFound,
+			wantStarted: true,
+		},
+		{
+			name: "body written",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			wantStatus:  http.StatusOK,
+			wantStarted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			rec := httpwriter.NewRecorder(w)
+			tt.handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", rec.Status, tt.wantStatus)
+			}
+			if rec.Started != tt.wantStarted {
+				t.Errorf("Started = %v, want %v", rec.Started, tt.wantStarted)
+			}
+			if rec.Unwrap() != w {
+				t.Errorf("Unwrap() did not return the wrapped writer")
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	// Send will deliver the message or return an error when it could not be handed off
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPConfig is the configuration for the smtp mailer
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // The smtp auth username, empty sends without auth
+	Password string
+	From     string
+}
+
+// SMTPMailer sends emails through an smtp server, upgrading to tls with STARTTLS when the server supports it
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+// NewSMTPMailer will create a new smtp mailer
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{config: config}
+}
+
+// Send will deliver the message through the smtp server
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+
+	var auth smtp.Auth
+	if m.config.Username != "" {
+		auth = smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+	}
+
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	if err := smtp.SendMail(addr, auth, m.config.From, []string{msg.To}, m.format(msg)); err != nil {
+		return fmt.Errorf("failed to send the email: %w", err)
+	}
+	return nil
+}
+
+// format will build the message with its headers, newlines are stripped from the header values
+func (m *SMTPMailer) format(msg Message) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", header.Replace(m.config.From))
+	fmt.Fprintf(&b, "To: %s\r\n", header.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// logMailerKeep is how many of the most recent messages the log mailer keeps
+const logMailerKeep = 100
+
+// LogMailer writes emails to the log instead of sending them and keeps the most recent ones in memory.
+// It is used when no smtp server is configured and in tests.
+type LogMailer struct {
+	logger *slog.Logger
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewLogMailer will create a new log only mailer
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send will log the message and keep it
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	m.sent = append(m.sent, msg)
+	if len(m.sent) > logMailerKeep {
+		m.sent = m.sent[len(m.sent)-logMailerKeep:]
+	}
+	m.mu.Unlock()
+	m.logger.InfoContext(ctx, "email", slog.String("to", msg.To), slog.String("subject", msg.Subject), slog.String("body", msg.Body))
+	return nil
+}
+
+// Sent will return the most recent messages sent
+func (m *LogMailer) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/httpwriter"
+)
+
+// InstrumentHandler will wrap the handler to record the request count and latency.
+// The route label is the pattern the http.ServeMux matched, so it should wrap the mux.
+func InstrumentHandler(service string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := httpwriter.NewRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(rec.Status)
+
+		HTTPRequests.WithLabelValues(service, r.Method, route, status).Inc()
+		HTTPRequestDuration.WithLabelValues(service, r.Method, route, status).Observe(time.Since(start).Seconds())
+	})
+}
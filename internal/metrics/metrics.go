@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "getsit"
+
+// Registry is the registry that every getsit metric is registered with
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests counts the http requests by service, method, route pattern and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of http requests by service, method, route pattern and status code.",
+	}, []string{"service", "method", "route", "status"})
+
+	// HTTPRequestDuration observes the http request latency by service, method, route pattern and status code
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of http requests by service, method, route pattern and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method", "route", "status"})
+
+	// Redirects counts the short url redirects by result, human, bot, fallback, not_found, disabled, deleted or taken_down
+	Redirects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redirects_total",
+		Help:      "Number of short url redirects by result.",
+	}, []string{"result"})
+
+	// RateLimited counts the requests denied by a rate limit policy
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Number of requests denied by rate limit policy.",
+	}, []string{"policy"})
+
+	// QRCodeGenerations counts the generated qr codes
+	QRCodeGenerations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "qrcode_generations_total",
+		Help:      "Number of qr codes generated.",
+	})
+
+	// RepositoryQueryDuration observes the repository latency by repository method
+	RepositoryQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "repository",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of repository queries by method.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"method"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		Redirects,
+		RateLimited,
+		QRCodeGenerations,
+		RepositoryQueryDuration,
+	)
+}
+
+// Handler will return the http handler that exposes the registry in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveRepositoryQuery will record the time since start against the repository method.
+// It is meant to be deferred at the top of a repository method, e.g. defer metrics.ObserveRepositoryQuery("SaveUrl", time.Now())
+func ObserveRepositoryQuery(method string, start time.Time) {
+	RepositoryQueryDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// NewServer will create a http server that only serves the metrics handler on /metrics.
+// This is meant to run on its own listener so metrics are not exposed on the public address.
+func NewServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", Handler())
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
@@ -0,0 +1,51 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/metrics"
+)
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return string(body)
+}
+
+func TestInstrumentHandler(t *testing.T) {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /i/{token}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	h := metrics.InstrumentHandler("test", mux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/i/abc12345", nil))
+
+	body := scrape(t)
+	want := `getsit_http_requests_total{method="GET",route="GET /i/{token}",service="test",status="404"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("scrape missing %q", want)
+	}
+}
+
+func TestObserveRepositoryQuery(t *testing.T) {
+
+	metrics.ObserveRepositoryQuery("GetFromToken", time.Now())
+
+	body := scrape(t)
+	want := `getsit_repository_query_duration_seconds_count{method="GetFromToken"} 1`
+	if !strings.Contains(body, want) {
+		t.Errorf("scrape missing %q", want)
+	}
+}
@@ -0,0 +1,156 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+
+	"github.com/griggsjared/getsit/database"
+)
+
+// ErrPending is returned when the database schema is behind the embedded migrations
+var ErrPending = errors.New("database has pending migrations")
+
+// Migrator runs the embedded migrations against a pgx pool.
+// The migrations are tracked in the same goose_db_version table that the goose cli uses, and applying
+// or rolling back holds a postgres advisory lock so replicas starting together do not race.
+type Migrator struct {
+	db       *sql.DB
+	provider *goose.Provider
+}
+
+// New will create a new migrator for the pool
+func New(pool *pgxpool.Pool) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+
+	db := stdlib.OpenDBFromPool(pool)
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, database.MigrationsFS(), goose.WithSessionLocker(locker))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Migrator{
+		db:       db,
+		provider: provider,
+	}, nil
+}
+
+// Versions will return the version the database is at and the latest embedded version
+func (m *Migrator) Versions(ctx context.Context) (current int64, latest int64, err error) {
+	return m.provider.GetVersions(ctx)
+}
+
+// Check will return ErrPending if the database is behind the embedded migrations
+func (m *Migrator) Check(ctx context.Context) error {
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending {
+		current, latest, err := m.Versions(ctx)
+		if err != nil {
+			return ErrPending
+		}
+		return fmt.Errorf("%w: database is at version %d, expected %d", ErrPending, current, latest)
+	}
+	return nil
+}
+
+// Result is a single migration that was applied or rolled back
+type Result struct {
+	Version   int64         // The version from the migration file name
+	Name      string        // The migration file name
+	Direction string        // up or down
+	Duration  time.Duration // The time taken to run the migration
+}
+
+// Status is the state of a single embedded migration
+type Status struct {
+	Version   int64     // The version from the migration file name
+	Name      string    // The migration file name
+	Applied   bool      // If the migration has been applied to the database
+	AppliedAt time.Time // When the migration was applied, zero when pending
+}
+
+// Up will apply every pending migration
+func (m *Migrator) Up(ctx context.Context) ([]Result, error) {
+	results, err := m.provider.Up(ctx)
+	return toResults(results...), err
+}
+
+// UpByOne will apply the next pending migration, nothing is returned when there are none pending
+func (m *Migrator) UpByOne(ctx context.Context) ([]Result, error) {
+	result, err := m.provider.UpByOne(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return nil, nil
+	}
+	return toResults(result), err
+}
+
+// Down will roll back the most recently applied migration, nothing is returned when none are applied
+func (m *Migrator) Down(ctx context.Context) ([]Result, error) {
+	result, err := m.provider.Down(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return nil, nil
+	}
+	return toResults(result), err
+}
+
+// Reset will roll back every applied migration
+func (m *Migrator) Reset(ctx context.Context) ([]Result, error) {
+	results, err := m.provider.DownTo(ctx, 0)
+	return toResults(results...), err
+}
+
+// Status will return the state of every embedded migration, oldest first
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, 0, len(statuses))
+	for _, s := range statuses {
+		out = append(out, Status{
+			Version:   s.Source.Version,
+			Name:      path.Base(s.Source.Path),
+			Applied:   s.State == goose.StateApplied,
+			AppliedAt: s.AppliedAt,
+		})
+	}
+	return out, nil
+}
+
+// toResults will convert the goose results, skipping the nil results goose returns alongside some errors
+func toResults(results ...*goose.MigrationResult) []Result {
+	var out []Result
+	for _, r := range results {
+		if r == nil || r.Source == nil {
+			continue
+		}
+		out = append(out, Result{
+			Version:   r.Source.Version,
+			Name:      path.Base(r.Source.Path),
+			Direction: r.Direction,
+			Duration:  r.Duration,
+		})
+	}
+	return out
+}
+
+// Close will release the sql.DB wrapper, the pool itself is not closed
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
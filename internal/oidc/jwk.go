@@ -0,0 +1,174 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"hash"
+	"math/big"
+)
+
+// jsonWebKey is a public key in the JSON Web Key format, only the fields of rsa and ec keys are read
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jsonWebKeySet is the document at the provider's jwks uri
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// signingKey is a parsed public key of the provider
+type signingKey struct {
+	kid string
+	alg string // The algorithm the key is restricted to, empty when any algorithm of its type can be used
+	key crypto.PublicKey
+}
+
+// keySet is the provider's parsed signing keys
+type keySet struct {
+	keys []signingKey
+}
+
+// parse will parse the rsa and ec signing keys of the set, keys that can not be used are skipped
+func (s jsonWebKeySet) parse() *keySet {
+	set := &keySet{}
+	for _, k := range s.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		set.keys = append(set.keys, signingKey{kid: k.Kid, alg: k.Alg, key: key})
+	}
+	return set
+}
+
+// publicKey will decode the key
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid rsa exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, errors.New("unsupported curve")
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("ec point is not on the curve")
+		}
+		return key, nil
+	}
+	return nil, errors.New("unsupported key type")
+}
+
+// find will return the key with the id that can verify the algorithm.
+// When the token has no key id the only key that can verify the algorithm is used.
+func (s *keySet) find(kid string, alg string) (signingKey, bool) {
+	var matches []signingKey
+	for _, k := range s.keys {
+		if (kid == "" || k.kid == kid) && (k.alg == "" || k.alg == alg) && k.supports(alg) {
+			matches = append(matches, k)
+		}
+	}
+	if len(matches) != 1 {
+		return signingKey{}, false
+	}
+	return matches[0], true
+}
+
+// supports will return true when the key type can verify the algorithm
+func (k signingKey) supports(alg string) bool {
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" || alg == "RS384" || alg == "RS512"
+	case *ecdsa.PublicKey:
+		return (alg == "ES256" && key.Curve == elliptic.P256()) || (alg == "ES384" && key.Curve == elliptic.P384())
+	}
+	return false
+}
+
+// verify will check the signature of the signed bytes
+func (k signingKey) verify(alg string, signed []byte, signature []byte) error {
+
+	var h hash.Hash
+	var ch crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		h, ch = sha256.New(), crypto.SHA256
+	case "RS384", "ES384":
+		h, ch = sha512.New384(), crypto.SHA384
+	case "RS512":
+		h, ch = sha512.New(), crypto.SHA512
+	default:
+		return errors.New("unsupported algorithm")
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch key := k.key.(type) {
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, ch, digest, signature); err != nil {
+			return errors.New("bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("bad signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	return errors.New("unsupported key")
+}
+
+// decodeBigInt will decode a base64url big endian integer
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
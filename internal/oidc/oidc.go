@@ -0,0 +1,415 @@
+// Package oidc logs users in with an OpenID Connect identity provider using the authorization code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned when the id token can not be trusted
+	ErrInvalidToken = errors.New("invalid id token")
+	// ErrNotAllowed is returned when the user is not in any of the allowed groups
+	ErrNotAllowed = errors.New("user is not in an allowed group")
+)
+
+// Config is the configuration of the identity provider
+type Config struct {
+	Issuer        string   // The issuer url, the provider configuration is discovered from it
+	ClientID      string   // The client id registered with the provider
+	ClientSecret  string   // The client secret, empty for public clients that only use PKCE
+	Scopes        []string // The scopes to request, openid is always requested
+	GroupsClaim   string   // The id token claim that holds the groups of the user, defaults to groups
+	AllowedGroups []string // The user has to be in one of the groups to log in, empty allows everyone
+}
+
+// Identity is the user the identity provider logged in
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+}
+
+// metadata is the part of the discovery document the login flow uses
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// discoveryTTL is how long the discovery document and signing keys are cached
+const discoveryTTL = time.Hour
+
+// clockSkew is how far the clocks of the provider and the app are allowed to drift
+const clockSkew = time.Minute
+
+// Provider is an OpenID Connect identity provider.
+// The discovery document and signing keys are fetched the first time they are needed and cached.
+type Provider struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+
+	mu          sync.Mutex
+	metadata    *metadata
+	keys        *keySet
+	refreshedAt time.Time
+}
+
+// NewProvider will create a new identity provider, a nil client uses a client with a 10 second timeout
+func NewProvider(config Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if !slices.Contains(config.Scopes, "openid") {
+		config.Scopes = append([]string{"openid"}, config.Scopes...)
+	}
+	return &Provider{
+		config: config,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+// WithClock will set the clock tokens are checked against
+func (p *Provider) WithClock(now func() time.Time) *Provider {
+	p.now = now
+	return p
+}
+
+// AuthRequest is the secret state of a login that is kept by the browser until the provider redirects back
+type AuthRequest struct {
+	State    string // Returned by the provider to tie the callback to this login
+	Nonce    string // Included in the id token to tie it to this login
+	Verifier string // The PKCE code verifier, only its hash is sent to the provider
+}
+
+// NewAuthRequest will generate the random values of a new login
+func NewAuthRequest() (*AuthRequest, error) {
+	var values [3]string
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return &AuthRequest{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+// AuthCodeURL will return the url of the provider the browser is sent to for the login
+func (p *Provider) AuthCodeURL(ctx context.Context, req *AuthRequest, redirectURL string) (string, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(req.Verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {req.State},
+		"nonce":                 {req.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	sep := "?"
+	if strings.Contains(md.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return md.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange will trade the code the provider redirected back with for an id token and return the identity in it.
+// The id token is checked against the provider's signing keys, the client id and the nonce of the login.
+func (p *Provider) Exchange(ctx context.Context, req *AuthRequest, code string, redirectURL string) (*Identity, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {req.Verifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		r.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(r, &token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange the code: %w", err)
+	}
+	if status != http.StatusOK || token.IDToken == "" {
+		if token.Error != "" {
+			return nil, fmt.Errorf("failed to exchange the code: %s %s", token.Error, token.ErrorDescription)
+		}
+		return nil, fmt.Errorf("failed to exchange the code: status %d without an id token", status)
+	}
+
+	identity, err := p.verify(ctx, md, token.IDToken, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.config.AllowedGroups) > 0 && !slices.ContainsFunc(identity.Groups, func(g string) bool {
+		return slices.Contains(p.config.AllowedGroups, g)
+	}) {
+		return nil, ErrNotAllowed
+	}
+
+	return identity, nil
+}
+
+// verify will check the id token and return the identity in it
+func (p *Provider) verify(ctx context.Context, md *metadata, raw string, nonce string) (*Identity, error) {
+
+	payload, err := p.verifySignature(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	dec := json.NewDecoder(strings.NewReader(string(payload)))
+	dec.UseNumber()
+	if err := dec.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("%w: the claims are not valid json", ErrInvalidToken)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != md.Issuer {
+		return nil, fmt.Errorf("%w: issued by %q", ErrInvalidToken, iss)
+	}
+
+	aud := stringsClaim(claims["aud"])
+	if !slices.Contains(aud, p.config.ClientID) {
+		return nil, fmt.Errorf("%w: not issued for this client", ErrInvalidToken)
+	}
+	if azp, ok := claims["azp"].(string); (len(aud) > 1 || ok) && azp != p.config.ClientID {
+		return nil, fmt.Errorf("%w: not authorized for this client", ErrInvalidToken)
+	}
+
+	now := p.now()
+	exp, ok := timeClaim(claims["exp"])
+	if !ok || !now.Before(exp.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	}
+	if iat, ok := timeClaim(claims["iat"]); ok && iat.After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidToken)
+	}
+
+	if n, _ := claims["nonce"].(string); n == "" || n != nonce {
+		return nil, fmt.Errorf("%w: the nonce does not match the login", ErrInvalidToken)
+	}
+
+	identity := &Identity{Issuer: md.Issuer, Groups: stringsClaim(claims[p.config.GroupsClaim])}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = v
+	case string:
+		identity.EmailVerified = v == "true"
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+
+	return identity, nil
+}
+
+// verifySignature will check the signature of the jwt with the provider's keys and return its payload.
+// The keys are fetched again once when the token is signed with a key that is not known yet.
+func (p *Provider) verifySignature(ctx context.Context, raw string) ([]byte, error) {
+
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a jwt", ErrInvalidToken)
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: the header is not base64", ErrInvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("%w: the header is not valid json", ErrInvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: the signature is not base64", ErrInvalidToken)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	for refresh := range 2 {
+		keys, err := p.signingKeys(ctx, refresh == 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := keys.find(header.Kid, header.Alg)
+		if !ok {
+			continue
+		}
+		if err := key.verify(header.Alg, signed, signature); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: the payload is not base64", ErrInvalidToken)
+		}
+		return payload, nil
+	}
+
+	return nil, fmt.Errorf("%w: signed with an unknown %s key %q", ErrInvalidToken, header.Alg, header.Kid)
+}
+
+// discover will return the provider's discovery document
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && p.now().Sub(p.refreshedAt) < discoveryTTL {
+		return p.metadata, nil
+	}
+
+	u := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	var md metadata
+	status, err := p.doJSON(r, &md)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("failed to discover the provider at %s: status %d %v", u, status, err)
+	}
+	if md.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("the provider at %s says its issuer is %q", u, md.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("the provider at %s is missing an endpoint", u)
+	}
+
+	p.metadata = &md
+	p.keys = nil
+	p.refreshedAt = p.now()
+	return p.metadata, nil
+}
+
+// signingKeys will return the provider's signing keys, refresh fetches them even when they are cached
+func (p *Provider) signingKeys(ctx context.Context, refresh bool) (*keySet, error) {
+	md, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil && !refresh {
+		return p.keys, nil
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, md.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+	var set jsonWebKeySet
+	status, err := p.doJSON(r, &set)
+	if err != nil || status != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch the signing keys from %s: status %d %v", md.JWKSURI, status, err)
+	}
+
+	p.keys = set.parse()
+	return p.keys, nil
+}
+
+// doJSON will send the request and decode the json response body into v, whatever the status
+func (p *Provider) doJSON(r *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(r)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// stringsClaim will return a claim that can be a single string or a list of strings
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []any:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// timeClaim will return a claim that is a number of seconds since the unix epoch
+func timeClaim(v any) (time.Time, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
@@ -0,0 +1,325 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/oidc"
+	"github.com/griggsjared/getsit/internal/oidc/oidctest"
+)
+
+const redirectURL = "https://getsit.test/login/sso/callback"
+
+// login will run the authorization code flow against the fake provider and return the identity
+func login(t *testing.T, p *oidc.Provider, idp *oidctest.Provider) (*oidc.Identity, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		t.Fatalf("NewAuthRequest() error = %v", err)
+	}
+	authURL, err := p.AuthCodeURL(ctx, req, redirectURL)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	code, state, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if state != req.State {
+		t.Fatalf("state = %q, want %q", state, req.State)
+	}
+	return p.Exchange(ctx, req, code, redirectURL)
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+
+	idp := oidctest.NewProvider(t, "getsit", "secret")
+	p := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "getsit", Scopes: []string{"email"}}, nil)
+
+	req := &oidc.AuthRequest{State: "state", Nonce: "nonce", Verifier: "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"}
+	authURL, err := p.AuthCodeURL(context.Background(), req, redirectURL)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() = %q is not a url", authURL)
+	}
+
+	tests := map[string]string{
+		"response_type":         "code",
+		"client_id":             "getsit",
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email",
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", // The example of RFC 7636
+		"code_challenge_method": "S256",
+	}
+	for param, want := range tests {
+		if got := u.Query().Get(param); got != want {
+			t.Errorf("AuthCodeURL() %s = %q, want %q", param, got, want)
+		}
+	}
+	if u.Query().Has("code_verifier") {
+		t.Errorf("AuthCodeURL() sent the code verifier")
+	}
+}
+
+func TestProvider_Exchange(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		config  func(c *oidc.Config)
+		setup   func(t *testing.T, idp *oidctest.Provider)
+		wantErr error // nil when the login should succeed
+	}{
+		{
+			name: "valid",
+		},
+		{
+			name:   "public client",
+			config: func(c *oidc.Config) { c.ClientSecret = "" },
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.ClientSecret = ""
+			},
+		},
+		{
+			name:    "wrong client secret",
+			config:  func(c *oidc.Config) { c.ClientSecret = "nope" },
+			wantErr: errAny,
+		},
+		{
+			name: "wrong issuer",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["iss"] = "https://evil.test" })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "wrong audience",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["aud"] = "someone-else" })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "audience list with authorized party",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["aud"], c["azp"] = []string{"api", "getsit"}, "getsit" })
+			},
+		},
+		{
+			name: "audience list without authorized party",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["aud"] = []string{"api", "getsit"} })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "expired",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["exp"] = time.Now().Add(-2 * time.Minute).Unix() })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "expired within the clock skew",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() })
+			},
+		},
+		{
+			name: "issued in the future",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["iat"] = time.Now().Add(time.Hour).Unix() })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "wrong nonce",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["nonce"] = "replayed" })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "no subject",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { delete(c, "sub") })
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "forged signature",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				if err := idp.ForgeSignatures(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name: "changed subject with a forged signature",
+			setup: func(t *testing.T, idp *oidctest.Provider) {
+				idp.Tamper(func(c map[string]any) { c["sub"] = "someone-else" })
+				if err := idp.ForgeSignatures(); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: oidc.ErrInvalidToken,
+		},
+		{
+			name:    "not in an allowed group",
+			config:  func(c *oidc.Config) { c.AllowedGroups = []string{"admins"} },
+			setup:   func(t *testing.T, idp *oidctest.Provider) { idp.SetClaim("groups", []string{"staff"}) },
+			wantErr: oidc.ErrNotAllowed,
+		},
+		{
+			name:    "no groups with allowed groups",
+			config:  func(c *oidc.Config) { c.AllowedGroups = []string{"admins"} },
+			wantErr: oidc.ErrNotAllowed,
+		},
+		{
+			name:   "in an allowed group",
+			config: func(c *oidc.Config) { c.AllowedGroups = []string{"admins"} },
+			setup:  func(t *testing.T, idp *oidctest.Provider) { idp.SetClaim("groups", []string{"staff", "admins"}) },
+		},
+		{
+			name: "in an allowed group from a custom claim",
+			config: func(c *oidc.Config) {
+				c.AllowedGroups = []string{"admins"}
+				c.GroupsClaim = "roles"
+			},
+			setup: func(t *testing.T, idp *oidctest.Provider) { idp.SetClaim("roles", "admins") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := oidctest.NewProvider(t, "getsit", "s3cret/+")
+			config := oidc.Config{Issuer: idp.Issuer(), ClientID: "getsit", ClientSecret: "s3cret/+"}
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			if tt.setup != nil {
+				tt.setup(t, idp)
+			}
+
+			identity, err := login(t, oidc.NewProvider(config, nil), idp)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Exchange() error = %v", err)
+				}
+				if identity.Issuer != idp.Issuer() || identity.Subject != "user-1" || identity.Email != "user@example.com" || !identity.EmailVerified {
+					t.Errorf("Exchange() = %+v, want user-1 of %s", identity, idp.Issuer())
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Exchange() = %+v, want an error", identity)
+			}
+			if tt.wantErr != errAny && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Exchange() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errAny is a wanted error that matches any error
+var errAny = errors.New("any error")
+
+func TestProvider_ExchangeGroups(t *testing.T) {
+
+	idp := oidctest.NewProvider(t, "getsit", "")
+	p := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "getsit"}, nil)
+
+	tests := []struct {
+		name  string
+		claim any
+		want  []string
+	}{
+		{name: "list", claim: []string{"staff", "admins"}, want: []string{"staff", "admins"}},
+		{name: "single string", claim: "staff", want: []string{"staff"}},
+		{name: "not strings", claim: []any{"staff", 7, true}, want: []string{"staff"}},
+		{name: "missing", claim: nil, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp.SetClaim("groups", tt.claim)
+			identity, err := login(t, p, idp)
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if !slices.Equal(identity.Groups, tt.want) {
+				t.Errorf("Exchange() Groups = %v, want %v", identity.Groups, tt.want)
+			}
+		})
+	}
+}
+
+func TestProvider_ExchangeCodeOnce(t *testing.T) {
+
+	ctx := context.Background()
+	idp := oidctest.NewProvider(t, "getsit", "")
+	p := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "getsit"}, nil)
+
+	req, _ := oidc.NewAuthRequest()
+	authURL, _ := p.AuthCodeURL(ctx, req, redirectURL)
+	code, _, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	stolen := *req
+	stolen.Verifier = "not-the-verifier"
+	if _, err := p.Exchange(ctx, &stolen, code, redirectURL); err == nil {
+		t.Errorf("Exchange() with the wrong PKCE verifier succeeded")
+	}
+
+	code, _, _ = idp.Authorize(authURL)
+	if _, err := p.Exchange(ctx, req, code, redirectURL); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if _, err := p.Exchange(ctx, req, code, redirectURL); err == nil {
+		t.Errorf("Exchange() with a used code succeeded")
+	}
+}
+
+func TestProvider_KeyRotation(t *testing.T) {
+
+	idp := oidctest.NewProvider(t, "getsit", "")
+	p := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer(), ClientID: "getsit"}, nil)
+
+	if _, err := login(t, p, idp); err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if err := idp.RotateKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(t, p, idp); err != nil {
+		t.Errorf("Exchange() after the key was rotated error = %v", err)
+	}
+}
+
+func TestProvider_Discovery(t *testing.T) {
+
+	idp := oidctest.NewProvider(t, "getsit", "")
+
+	p := oidc.NewProvider(oidc.Config{Issuer: idp.Issuer() + "/", ClientID: "getsit"}, nil)
+	if _, err := p.AuthCodeURL(context.Background(), &oidc.AuthRequest{}, redirectURL); err == nil {
+		t.Errorf("AuthCodeURL() with an issuer that does not match the discovery document succeeded")
+	}
+
+	p = oidc.NewProvider(oidc.Config{Issuer: "http://127.0.0.1:1", ClientID: "getsit"}, nil)
+	if _, err := p.AuthCodeURL(context.Background(), &oidc.AuthRequest{}, redirectURL); err == nil {
+		t.Errorf("AuthCodeURL() with an unreachable issuer succeeded")
+	}
+}
@@ -0,0 +1,209 @@
+package pow
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidChallenge is returned when the challenge was not issued by this service
+	ErrInvalidChallenge = errors.New("invalid challenge")
+	// ErrExpiredChallenge is returned when the challenge is older than the challenge ttl
+	ErrExpiredChallenge = errors.New("challenge has expired")
+	// ErrReplayedChallenge is returned when the challenge has already been used
+	ErrReplayedChallenge = errors.New("challenge has already been used")
+	// ErrInvalidSolution is returned when the nonce does not solve the challenge
+	ErrInvalidSolution = errors.New("nonce does not solve the challenge")
+)
+
+// Config is the configuration for the proof of work service
+type Config struct {
+	Secret        string        // The secret the challenges are signed with
+	Difficulty    int           // The leading zero bits a solution needs when traffic is normal
+	MaxDifficulty int           // The most leading zero bits the adaptive difficulty will ask for
+	Threshold     int           // The creations per window before the difficulty goes up, 0 disables adaptive difficulty
+	Window        time.Duration // The window recent creations are counted over
+	TTL           time.Duration // How long a challenge can be solved for
+}
+
+// Challenge is a signed challenge for the browser to solve
+type Challenge struct {
+	Token      string // The signed challenge to send back with the nonce
+	Difficulty int    // The leading zero bits the sha256 of token:nonce needs
+}
+
+// Service issues and verifies proof of work challenges.
+// A solution is a nonce where the sha256 of "token:nonce" starts with Difficulty zero bits.
+type Service struct {
+	key    []byte
+	config Config
+	now    func() time.Time
+
+	mu        sync.Mutex
+	used      map[string]time.Time // The seeds of solved challenges until they expire, to stop replays
+	creations []time.Time          // The recent creations for the adaptive difficulty
+}
+
+// NewService will create a new proof of work service
+func NewService(config Config) *Service {
+	key := sha256.Sum256([]byte("getsit-pow:" + config.Secret))
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.TTL <= 0 {
+		config.TTL = 10 * time.Minute
+	}
+	if config.MaxDifficulty < config.Difficulty {
+		config.MaxDifficulty = config.Difficulty
+	}
+	return &Service{
+		key:    key[:],
+		config: config,
+		now:    time.Now,
+		used:   make(map[string]time.Time),
+	}
+}
+
+// Enabled will return true when a nil service or a zero difficulty is not in use
+func (s *Service) Enabled() bool {
+	return s != nil && s.config.Difficulty > 0
+}
+
+// NewChallenge will issue a new signed challenge at the current difficulty.
+// The token is seed.difficulty.expires.signature so it can be verified without storing it.
+func (s *Service) NewChallenge() (Challenge, error) {
+
+	seed := make([]byte, 16)
+	if _, err := rand.Read(seed); err != nil {
+		return Challenge{}, err
+	}
+
+	difficulty := s.Difficulty()
+	payload := fmt.Sprintf("%s.%d.%d", hex.EncodeToString(seed), difficulty, s.now().Add(s.config.TTL).Unix())
+
+	return Challenge{
+		Token:      payload + "." + s.sign(payload),
+		Difficulty: difficulty,
+	}, nil
+}
+
+// Verify will check the nonce solves the challenge, and mark the challenge as used so it can not be replayed
+func (s *Service) Verify(token string, nonce string) error {
+
+	payload, signature, ok := cutLast(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(payload))) {
+		return ErrInvalidChallenge
+	}
+
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return ErrInvalidChallenge
+	}
+	seed := parts[0]
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+	expires, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return ErrInvalidChallenge
+	}
+
+	now := s.now()
+	if now.Unix() > expires {
+		return ErrExpiredChallenge
+	}
+
+	if nonce == "" || len(nonce) > 32 || leadingZeroBits(sha256.Sum256([]byte(token+":"+nonce))) < difficulty {
+		return ErrInvalidSolution
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for usedSeed, usedExpires := range s.used {
+		if now.After(usedExpires) {
+			delete(s.used, usedSeed)
+		}
+	}
+	if _, ok := s.used[seed]; ok {
+		return ErrReplayedChallenge
+	}
+	s.used[seed] = time.Unix(expires, 0)
+
+	return nil
+}
+
+// RecordCreation will count a creation towards the adaptive difficulty
+func (s *Service) RecordCreation() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.creations = append(s.pruneCreations(now), now)
+}
+
+// Difficulty will return the current difficulty.
+// Every doubling of the recent creations over the threshold adds a bit, up to the max difficulty.
+func (s *Service) Difficulty() int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.creations = s.pruneCreations(s.now())
+
+	difficulty := s.config.Difficulty
+	if s.config.Threshold > 0 {
+		for n := len(s.creations); n >= s.config.Threshold && difficulty < s.config.MaxDifficulty; n /= 2 {
+			difficulty++
+		}
+	}
+	return difficulty
+}
+
+// pruneCreations will drop the creations outside of the window, the lock must be held
+func (s *Service) pruneCreations(now time.Time) []time.Time {
+	cutoff := now.Add(-s.config.Window)
+	i := 0
+	for i < len(s.creations) && s.creations[i].Before(cutoff) {
+		i++
+	}
+	return s.creations[i:]
+}
+
+// sign will return the hmac signature of the payload
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cutLast will slice s around the last instance of sep
+func cutLast(s string, sep string) (before string, after string, found bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// leadingZeroBits will count the zero bits at the start of the hash
+func leadingZeroBits(hash [32]byte) int {
+	n := 0
+	for _, b := range hash {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,175 @@
+package pow_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/pow"
+)
+
+// solve will brute force a nonce the same way the browser script does
+func solve(t *testing.T, c pow.Challenge) string {
+	t.Helper()
+	for i := 0; i < 1<<24; i++ {
+		nonce := strconv.Itoa(i)
+		hash := sha256.Sum256([]byte(c.Token + ":" + nonce))
+		zeros := 0
+		for _, b := range hash {
+			zeros += bits.LeadingZeros8(b)
+			if b != 0 {
+				break
+			}
+		}
+		if zeros >= c.Difficulty {
+			return nonce
+		}
+	}
+	t.Fatalf("failed to solve the challenge")
+	return ""
+}
+
+func TestService_Verify(t *testing.T) {
+
+	s := pow.NewService(pow.Config{Secret: "secret", Difficulty: 8})
+	other := pow.NewService(pow.Config{Secret: "other", Difficulty: 8})
+
+	tests := []struct {
+		name    string
+		token   func(c pow.Challenge) string
+		nonce   func(c pow.Challenge, solved string) string
+		wantErr error
+	}{
+		{
+			name:    "solved",
+			token:   func(c pow.Challenge) string { return c.Token },
+			nonce:   func(c pow.Challenge, solved string) string { return solved },
+			wantErr: nil,
+		},
+		{
+			name:    "wrong nonce",
+			token:   func(c pow.Challenge) string { return c.Token },
+			nonce:   func(c pow.Challenge, solved string) string { return solved + "x" },
+			wantErr: pow.ErrInvalidSolution,
+		},
+		{
+			name:    "empty nonce",
+			token:   func(c pow.Challenge) string { return c.Token },
+			nonce:   func(c pow.Challenge, solved string) string { return "" },
+			wantErr: pow.ErrInvalidSolution,
+		},
+		{
+			name:    "tampered difficulty",
+			token:   func(c pow.Challenge) string { return strings.Replace(c.Token, ".8.", ".0.", 1) },
+			nonce:   func(c pow.Challenge, solved string) string { return "1" },
+			wantErr: pow.ErrInvalidChallenge,
+		},
+		{
+			name: "signed by another secret",
+			token: func(c pow.Challenge) string {
+				oc, _ := other.NewChallenge()
+				return oc.Token
+			},
+			nonce:   func(c pow.Challenge, solved string) string { return solved },
+			wantErr: pow.ErrInvalidChallenge,
+		},
+		{
+			name:    "garbage",
+			token:   func(c pow.Challenge) string { return "garbage" },
+			nonce:   func(c pow.Challenge, solved string) string { return solved },
+			wantErr: pow.ErrInvalidChallenge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := s.NewChallenge()
+			if err != nil {
+				t.Fatalf("NewChallenge() error = %v", err)
+			}
+			solved := solve(t, c)
+			err = s.Verify(tt.token(c), tt.nonce(c, solved))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestService_VerifyReplay(t *testing.T) {
+
+	s := pow.NewService(pow.Config{Secret: "secret", Difficulty: 8})
+
+	c, err := s.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	nonce := solve(t, c)
+
+	if err := s.Verify(c.Token, nonce); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if err := s.Verify(c.Token, nonce); !errors.Is(err, pow.ErrReplayedChallenge) {
+		t.Errorf("Verify() replay error = %v, want %v", err, pow.ErrReplayedChallenge)
+	}
+}
+
+func TestService_VerifyExpired(t *testing.T) {
+
+	s := pow.NewService(pow.Config{Secret: "secret", Difficulty: 1, TTL: time.Nanosecond})
+
+	c, err := s.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	nonce := solve(t, c)
+	time.Sleep(1100 * time.Millisecond)
+
+	if err := s.Verify(c.Token, nonce); !errors.Is(err, pow.ErrExpiredChallenge) {
+		t.Errorf("Verify() error = %v, want %v", err, pow.ErrExpiredChallenge)
+	}
+}
+
+func TestService_Difficulty(t *testing.T) {
+
+	s := pow.NewService(pow.Config{Secret: "secret", Difficulty: 10, MaxDifficulty: 12, Threshold: 2})
+
+	tests := []struct {
+		creations int
+		want      int
+	}{
+		{creations: 0, want: 10},
+		{creations: 1, want: 10},
+		{creations: 2, want: 11},
+		{creations: 4, want: 12},
+		{creations: 64, want: 12},
+	}
+
+	recorded := 0
+	for _, tt := range tests {
+		for ; recorded < tt.creations; recorded++ {
+			s.RecordCreation()
+		}
+		if got := s.Difficulty(); got != tt.want {
+			t.Errorf("Difficulty() after %d creations = %v, want %v", tt.creations, got, tt.want)
+		}
+	}
+}
+
+func TestService_Enabled(t *testing.T) {
+
+	var s *pow.Service
+	if s.Enabled() {
+		t.Errorf("nil Enabled() = true, want false")
+	}
+	if pow.NewService(pow.Config{Difficulty: 0}).Enabled() {
+		t.Errorf("zero difficulty Enabled() = true, want false")
+	}
+	if !pow.NewService(pow.Config{Difficulty: 16}).Enabled() {
+		t.Errorf("Enabled() = false, want true")
+	}
+}
@@ -1,10 +1,14 @@
 package qrcode
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 
 	"github.com/skip2/go-qrcode"
+
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/tracing"
 )
 
 // ErrValidation is a generic validation error that can be returned when input validation fails
@@ -40,7 +44,10 @@ type GenerateInput struct {
 	Size    int
 }
 
-func (s *Service) Generate(input *GenerateInput) (*QRCode, error) {
+func (s *Service) Generate(ctx context.Context, input *GenerateInput) (*QRCode, error) {
+
+	_, span := tracing.Start(ctx, "qrcode.Service.Generate")
+	defer span.End()
 
 	var png []byte
 	png, err := qrcode.Encode(input.Content, qrcode.High, input.Size)
@@ -48,5 +55,7 @@ func (s *Service) Generate(input *GenerateInput) (*QRCode, error) {
 		return nil, err
 	}
 
+	metrics.QRCodeGenerations.Inc()
+
 	return NewQRCode(png), nil
 }
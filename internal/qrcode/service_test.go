@@ -1,6 +1,7 @@
 package qrcode_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/griggsjared/getsit/internal/qrcode"
@@ -81,7 +82,7 @@ func TestService_Generate(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := s.Generate(tt.input)
+			_, err := s.Generate(context.Background(), tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Generate() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/httpwriter"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/realip"
+)
+
+// subjectCtxKey is the context key the authenticated subject is stored under
+type subjectCtxKey struct{}
+
+// WithSubject will store the authenticated subject, e.g. an api key id, in the context.
+// Requests with a subject are limited by the subject instead of the client ip.
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectCtxKey{}, subject)
+}
+
+// Key will return the key a request is limited by, the authenticated subject or the client ip
+func Key(r *http.Request) string {
+	if subject, ok := r.Context().Value(subjectCtxKey{}).(string); ok && subject != "" {
+		return "subject:" + subject
+	}
+	return "ip:" + realip.FromRequest(r).IP
+}
+
+// Limiter applies rate limit policies to requests.
+// A nil Limiter does not limit anything so the apps can run without one.
+type Limiter struct {
+	store Store
+}
+
+// NewLimiter will create a new limiter that keeps its buckets in the store
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		store: store,
+	}
+}
+
+// Middleware will limit every request with the policy.
+// The RateLimit-* headers are set on every response and denied requests are passed to deny with a Retry-After header.
+func (l *Limiter) Middleware(policy Policy, deny http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || policy.Unlimited() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			result, err := l.store.Take(r.Context(), policy.Name+":"+Key(r), policy, 1)
+			if err != nil {
+				// a store outage should not take the app down with it
+				next.ServeHTTP(w, r)
+				return
+			}
+			setHeaders(w, policy, result)
+			if !result.Allowed {
+				denied(w, r, policy, result, deny)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NotFoundMiddleware will only count the requests that respond with a 404, to slow down token enumeration.
+// Once a client has used up its not found requests every request through the middleware is denied until the bucket refills,
+// so the client can not keep telling tokens that exist apart from those that do not.
+func (l *Limiter) NotFoundMiddleware(policy Policy, deny http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || policy.Unlimited() {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := policy.Name + ":" + Key(r)
+			result, err := l.store.Take(r.Context(), key, policy, 0)
+			if err == nil && !result.Allowed {
+				setHeaders(w, policy, result)
+				denied(w, r, policy, result, deny)
+				return
+			}
+			rec := httpwriter.NewRecorder(w)
+			next.ServeHTTP(rec, r)
+			if rec.Status == http.StatusNotFound {
+				l.store.Take(r.Context(), key, policy, 1)
+			}
+		})
+	}
+}
+
+// denied will set the Retry-After header, count the denied request and call the deny handler
+func denied(w http.ResponseWriter, r *http.Request, policy Policy, result Result, deny http.Handler) {
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	metrics.RateLimited.WithLabelValues(policy.Name).Inc()
+	deny.ServeHTTP(w, r)
+}
+
+// setHeaders will set the RateLimit-* headers from the IETF RateLimit header fields draft
+func setHeaders(w http.ResponseWriter, policy Policy, result Result) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", policy.Limit, ceilSeconds(policy.Period)))
+}
+
+// RetryAfter will return the Retry-After header of a denied response as a duration
+func RetryAfter(w http.ResponseWriter) time.Duration {
+	s, _ := strconv.Atoi(w.Header().Get("Retry-After"))
+	return time.Duration(s) * time.Second
+}
+
+// ceilSeconds will round the duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
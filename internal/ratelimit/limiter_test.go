@@ -0,0 +1,178 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/ratelimit"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+
+	store := ratelimit.NewMemoryStore()
+	policy := ratelimit.Policy{Name: "test", Limit: 3, Period: time.Hour}
+	ctx := context.Background()
+
+	for i := range 3 {
+		result, err := store.Take(ctx, "a", policy, 1)
+		if err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Fatalf("Take() %d was not allowed", i+1)
+		}
+		if result.Remaining != 2-i {
+			t.Errorf("Take() %d Remaining = %v, want %v", i+1, result.Remaining, 2-i)
+		}
+	}
+
+	result, _ := store.Take(ctx, "a", policy, 1)
+	if result.Allowed {
+		t.Errorf("Take() over the limit was allowed")
+	}
+	if result.RetryAfter <= 0 || result.RetryAfter > 20*time.Minute {
+		t.Errorf("RetryAfter = %v, want about %v", result.RetryAfter, 20*time.Minute)
+	}
+
+	if result, _ := store.Take(ctx, "b", policy, 1); !result.Allowed {
+		t.Errorf("Take() for another key was not allowed")
+	}
+
+	if result, _ := store.Take(ctx, "a", policy, 0); result.Allowed {
+		t.Errorf("Take() with no cost on an empty bucket was allowed")
+	}
+}
+
+func TestMemoryStore_Refill(t *testing.T) {
+
+	store := ratelimit.NewMemoryStore()
+	policy := ratelimit.Policy{Name: "test", Limit: 1, Period: 50 * time.Millisecond}
+	ctx := context.Background()
+
+	store.Take(ctx, "a", policy, 1)
+	if result, _ := store.Take(ctx, "a", policy, 1); result.Allowed {
+		t.Fatalf("Take() over the limit was allowed")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+
+	if result, _ := store.Take(ctx, "a", policy, 1); !result.Allowed {
+		t.Errorf("Take() after the period was not allowed")
+	}
+}
+
+func TestLimiter_Middleware(t *testing.T) {
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	policy := ratelimit.Policy{Name: "create", Limit: 2, Period: time.Minute}
+	deny := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := limiter.Middleware(policy, deny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{name: "first", remoteAddr: "198.51.100.1:1000", wantStatus: http.StatusOK, wantRemaining: "1"},
+		{name: "second", remoteAddr: "198.51.100.1:1001", wantStatus: http.StatusOK, wantRemaining: "0"},
+		{name: "over the limit", remoteAddr: "198.51.100.1:1002", wantStatus: http.StatusTooManyRequests, wantRemaining: "0"},
+		{name: "another client", remoteAddr: "198.51.100.2:1000", wantStatus: http.StatusOK, wantRemaining: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/create", nil)
+			r.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("RateLimit-Remaining"); got != tt.wantRemaining {
+				t.Errorf("RateLimit-Remaining = %v, want %v", got, tt.wantRemaining)
+			}
+			if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+				t.Errorf("RateLimit-Policy = %v, want %v", got, "2;w=60")
+			}
+			if tt.wantStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "30" {
+				t.Errorf("Retry-After = %v, want %v", rec.Header().Get("Retry-After"), "30")
+			}
+		})
+	}
+}
+
+func TestLimiter_MiddlewareBySubject(t *testing.T) {
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	policy := ratelimit.Policy{Name: "create", Limit: 1, Period: time.Minute}
+	deny := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := limiter.Middleware(policy, deny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, subject := range []string{"key-1", "key-2"} {
+		r := httptest.NewRequest(http.MethodPost, "/url-entries", nil)
+		r = r.WithContext(ratelimit.WithSubject(r.Context(), subject))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Errorf("subject %s status = %d, want %d", subject, rec.Code, http.StatusOK)
+		}
+	}
+}
+
+func TestLimiter_NotFoundMiddleware(t *testing.T) {
+
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	policy := ratelimit.Policy{Name: "not_found", Limit: 2, Period: time.Minute}
+	deny := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	h := limiter.NotFoundMiddleware(policy, deny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/found" {
+			http.NotFound(w, r)
+		}
+	}))
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/found", wantStatus: http.StatusOK},
+		{path: "/missing-1", wantStatus: http.StatusNotFound},
+		{path: "/found", wantStatus: http.StatusOK},
+		{path: "/missing-2", wantStatus: http.StatusNotFound},
+		{path: "/missing-3", wantStatus: http.StatusTooManyRequests},
+		{path: "/found", wantStatus: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.wantStatus {
+			t.Errorf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+
+	var limiter *ratelimit.Limiter
+	policy := ratelimit.Policy{Name: "create", Limit: 1, Period: time.Minute}
+	h := limiter.Middleware(policy, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/create", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Policy is a token bucket that holds Limit tokens and refills completely over Period.
+// A zero Limit or Period is not limited.
+type Policy struct {
+	Name   string        // The name the buckets are keyed under, e.g. create
+	Limit  int           // The number of requests allowed in a burst
+	Period time.Duration // The time for an empty bucket to refill
+}
+
+// Policies are the policies the web app and api apply
+type Policies struct {
+	Create   Policy // Creating a short url
+	Redirect Policy // Following a short url
+	NotFound Policy // Requests for tokens that do not exist
+	Auth     Policy // Registering, logging in and asking for a password reset
+}
+
+// Unlimited will return true when the policy does not limit requests
+func (p Policy) Unlimited() bool {
+	return p.Limit <= 0 || p.Period <= 0
+}
+
+// Result is the state of a bucket after taking from it
+type Result struct {
+	Allowed    bool          // If the tokens were taken
+	Limit      int           // The bucket size
+	Remaining  int           // The whole tokens left in the bucket
+	Reset      time.Duration // The time until the bucket is full again
+	RetryAfter time.Duration // The time until the request would be allowed, zero when allowed
+}
+
+// Store holds the token buckets.
+// The memory store only limits a single process, a store shared between replicas can implement this interface.
+type Store interface {
+	// Take will remove cost tokens from the bucket for key if there are enough.
+	// A cost of 0 takes nothing and only reports if a single token is available.
+	Take(ctx context.Context, key string, policy Policy, cost int) (Result, error)
+}
+
+// bucket is the state of a single token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryStore is a Store that keeps the buckets in memory
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore will create a new in memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// sweepInterval is how often full buckets are removed so idle clients do not hold memory
+const sweepInterval = time.Minute
+
+// Take will remove cost tokens from the bucket for key if there are enough
+func (s *MemoryStore) Take(_ context.Context, key string, policy Policy, cost int) (Result, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	limit := float64(policy.Limit)
+	rate := limit / policy.Period.Seconds() // tokens per second
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit, updated: now, period: policy.Period}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(limit, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	need := math.Max(float64(cost), 1)
+	result := Result{
+		Limit:   policy.Limit,
+		Allowed: b.tokens >= need,
+	}
+	if result.Allowed {
+		b.tokens -= float64(cost)
+	} else {
+		result.RetryAfter = seconds((need - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((limit - b.tokens) / rate)
+
+	return result, nil
+}
+
+// sweep will remove the buckets that have refilled, they are the same as a new bucket
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// seconds will convert a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
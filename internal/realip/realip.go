@@ -0,0 +1,238 @@
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ctxKey is the context key the resolved client is stored under
+type ctxKey struct{}
+
+// Client is the original client of a request, as reported by the trusted proxies in front of the server
+type Client struct {
+	IP    string // The client ip address
+	Proto string // The scheme the client used, http or https
+	Host  string // The host the client requested
+}
+
+// Resolver resolves the original client of a request.
+// Forwarding headers are only believed when the connection comes from one of the trusted proxies.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// NewResolver will create a new resolver that trusts the forwarding headers set by the given proxies
+func NewResolver(trusted []netip.Prefix) *Resolver {
+	return &Resolver{
+		trusted: trusted,
+	}
+}
+
+// hop is a single proxy hop from a forwarding header
+type hop struct {
+	addr  netip.Addr // The address the hop received the request from, invalid when unknown or obfuscated
+	proto string     // The scheme the hop received the request with, if reported
+	host  string     // The host header the hop received, if reported
+}
+
+// Resolve will return the client of the request.
+// The Forwarded header is used when set, otherwise X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host.
+// The hops are walked from the right, skipping trusted proxies, so only the first untrusted address is used
+// and addresses a client prepends to the header are ignored.
+func (rs *Resolver) Resolve(r *http.Request) Client {
+
+	client := Client{
+		IP:    remoteIP(r),
+		Proto: "http",
+		Host:  r.Host,
+	}
+	if r.TLS != nil {
+		client.Proto = "https"
+	}
+
+	peer, err := netip.ParseAddr(client.IP)
+	if err != nil || !rs.isTrusted(peer) {
+		return client
+	}
+
+	var hops []hop
+	if forwarded := r.Header.Values("Forwarded"); len(forwarded) > 0 {
+		hops = parseForwarded(strings.Join(forwarded, ","))
+	} else if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops = parseForwardedFor(strings.Join(forwardedFor, ","))
+		// the X-Forwarded-* headers are not per hop, a proxy that appends adds its value after the ones the client sent,
+		// so only the last value, the one of the nearest trusted proxy, is believed
+		for i := range hops {
+			hops[i].proto = lastValue(r.Header.Values("X-Forwarded-Proto"))
+			hops[i].host = lastValue(r.Header.Values("X-Forwarded-Host"))
+		}
+	}
+	if len(hops) == 0 {
+		return client
+	}
+
+	selected := hops[0]
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !hops[i].addr.IsValid() {
+			// an unknown or obfuscated hop can not be checked, so the walk stops at the last known address
+			if i < len(hops)-1 {
+				selected = hops[i+1]
+			} else {
+				selected = hop{addr: peer}
+			}
+			break
+		}
+		if !rs.isTrusted(hops[i].addr) {
+			selected = hops[i]
+			break
+		}
+	}
+
+	client.IP = selected.addr.String()
+	if selected.proto == "http" || selected.proto == "https" {
+		client.Proto = selected.proto
+	}
+	if selected.host != "" {
+		client.Host = selected.host
+	}
+
+	return client
+}
+
+// Middleware will resolve the client and store it in the request context for the handlers that follow.
+// This should wrap every other middleware that logs or uses the client address.
+func (rs *Resolver) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := FromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxKey{}, rs.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// FromContext will return the client stored by the middleware
+func FromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(ctxKey{}).(Client)
+	return client, ok
+}
+
+// FromRequest will return the client stored by the middleware.
+// When the middleware has not run no proxies are trusted and the connection itself is used.
+func FromRequest(r *http.Request) Client {
+	if client, ok := FromContext(r.Context()); ok {
+		return client
+	}
+	return NewResolver(nil).Resolve(r)
+}
+
+// isTrusted will check if the address is in one of the trusted proxy prefixes
+func (rs *Resolver) isTrusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range rs.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP will return the ip address of the connection
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// parseForwardedFor will parse the comma separated addresses of an X-Forwarded-For header
+func parseForwardedFor(header string) []hop {
+	var hops []hop
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		hops = append(hops, hop{addr: parseNode(part)})
+	}
+	return hops
+}
+
+// parseForwarded will parse the elements of an RFC 7239 Forwarded header, e.g.
+// for=192.0.2.60;proto=https;host=getsit.to, for="[2001:db8::1]:4711"
+func parseForwarded(header string) []hop {
+	var hops []hop
+	for _, element := range splitOutsideQuotes(header, ',') {
+		if strings.TrimSpace(element) == "" {
+			continue
+		}
+		var h hop
+		for _, pair := range splitOutsideQuotes(element, ';') {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "for":
+				h.addr = parseNode(value)
+			case "proto":
+				h.proto = strings.ToLower(value)
+			case "host":
+				h.host = value
+			}
+		}
+		hops = append(hops, h)
+	}
+	return hops
+}
+
+// parseNode will parse a node from a forwarding header, with an optional port and ipv6 brackets.
+// Unknown and obfuscated nodes return an invalid address.
+func parseNode(node string) netip.Addr {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if strings.HasPrefix(node, "[") {
+		end := strings.Index(node, "]")
+		if end < 0 {
+			return netip.Addr{}
+		}
+		node = node[1:end]
+	} else if strings.Count(node, ":") == 1 {
+		node, _, _ = strings.Cut(node, ":")
+	}
+	addr, err := netip.ParseAddr(node)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+// splitOutsideQuotes will split s on sep, ignoring separators inside quoted strings
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuotes:
+			i++
+		case s[i] == '"':
+			inQuotes = !inQuotes
+		case s[i] == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// lastValue will return the last value of a comma separated header that can be sent more than once
+func lastValue(values []string) string {
+	header := strings.Join(values, ",")
+	return strings.ToLower(strings.TrimSpace(header[strings.LastIndex(header, ",")+1:]))
+}
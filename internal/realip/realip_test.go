@@ -0,0 +1,165 @@
+package realip_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/realip"
+)
+
+func TestResolver_Resolve(t *testing.T) {
+
+	resolver := realip.NewResolver([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		headers    map[string]string
+		want       realip.Client
+	}{
+		{
+			name:       "direct connection",
+			remoteAddr: "203.0.113.7:5000",
+			want:       realip.Client{IP: "203.0.113.7", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "direct tls connection",
+			remoteAddr: "203.0.113.7:5000",
+			tls:        true,
+			want:       realip.Client{IP: "203.0.113.7", Proto: "https", Host: "getsit.to"},
+		},
+		{
+			name:       "untrusted peer headers are ignored",
+			remoteAddr: "203.0.113.7:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example",
+			},
+			want: realip.Client{IP: "203.0.113.7", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "trusted proxy x-forwarded-for",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1",
+				"X-Forwarded-Proto": "https",
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "https", Host: "getsit.to"},
+		},
+		{
+			name:       "spoofed x-forwarded-proto and host values are skipped",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.1",
+				"X-Forwarded-Proto": "https, http",
+				"X-Forwarded-Host":  "evil.example, sho.rt",
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "http", Host: "sho.rt"},
+		},
+		{
+			name:       "spoofed x-forwarded-for entries are skipped",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.3",
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "every hop trusted uses the leftmost",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"X-Forwarded-For": "10.0.0.9, 10.0.0.3",
+			},
+			want: realip.Client{IP: "10.0.0.9", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "forwarded header",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded": `for=198.51.100.1;proto=https;host=sho.rt, for="[2001:db8::1]:4711"`,
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "https", Host: "sho.rt"},
+		},
+		{
+			name:       "forwarded header beats x-forwarded-for",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded":       "for=198.51.100.1:80",
+				"X-Forwarded-For": "198.51.100.2",
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "forwarded ipv6 client",
+			remoteAddr: "[2001:db8::5]:5000",
+			headers: map[string]string{
+				"Forwarded": `for="[2001:db9::1]:4711";proto=https`,
+			},
+			want: realip.Client{IP: "2001:db9::1", Proto: "https", Host: "getsit.to"},
+		},
+		{
+			name:       "obfuscated hop stops the walk",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded": "for=198.51.100.1, for=_hidden, for=10.0.0.3",
+			},
+			want: realip.Client{IP: "10.0.0.3", Proto: "http", Host: "getsit.to"},
+		},
+		{
+			name:       "invalid proto is ignored",
+			remoteAddr: "10.0.0.2:5000",
+			headers: map[string]string{
+				"Forwarded": "for=198.51.100.1;proto=gopher",
+			},
+			want: realip.Client{IP: "198.51.100.1", Proto: "http", Host: "getsit.to"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://getsit.to/abc", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := resolver.Resolve(r); got != tt.want {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolver_Middleware(t *testing.T) {
+
+	resolver := realip.NewResolver([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+
+	var got realip.Client
+	h := resolver.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = realip.FromRequest(r)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "http://getsit.to/abc", nil)
+	r.RemoteAddr = "10.0.0.2:5000"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+
+	if got.IP != "198.51.100.1" {
+		t.Errorf("FromRequest().IP = %v, want %v", got.IP, "198.51.100.1")
+	}
+
+	// without the middleware no proxy is trusted
+	if ip := realip.FromRequest(r).IP; ip != "10.0.0.2" {
+		t.Errorf("FromRequest().IP without middleware = %v, want %v", ip, "10.0.0.2")
+	}
+}
@@ -0,0 +1,104 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// Header is the header the request id is read from and written to
+const Header = "X-Request-ID"
+
+// maxLength is the longest request id accepted from a client or proxy
+const maxLength = 128
+
+type ctxKey struct{}
+
+// NewContext will return a copy of the context with the request id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext will return the request id from the context, or an empty string when there is none
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Middleware will store the request id in the request context and echo it in the response header.
+// The X-Request-ID sent with the request is kept when it is valid so ids propagate from the proxies in front,
+// otherwise a new id is created. A request that already has an id in its context keeps it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if id := FromContext(r.Context()); id != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id := r.Header.Get(Header)
+		if !valid(id) {
+			id = newID()
+		}
+
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+// valid will return true when the id is not empty, not too long and only printable ascii
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newID will return 16 random bytes encoded as hex
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LogHandler is a slog.Handler that adds the request id from the context to each record.
+// Only the Context variants of the logger methods, e.g. InfoContext, can carry the request id.
+type LogHandler struct {
+	next slog.Handler
+}
+
+// NewLogHandler will wrap the handler so records logged with a request context include the request_id
+func NewLogHandler(next slog.Handler) *LogHandler {
+	return &LogHandler{next: next}
+}
+
+// Enabled will report whether the wrapped handler handles records at the level
+func (h *LogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle will add the request id to the record before passing it to the wrapped handler
+func (h *LogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := FromContext(ctx); id != "" {
+		record = record.Clone()
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.next.Handle(ctx, record)
+}
+
+// WithAttrs will return a handler with the attributes added to the wrapped handler
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{next: h.next.WithAttrs(attrs)}
+}
+
+// WithGroup will return a handler with the group added to the wrapped handler
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{next: h.next.WithGroup(name)}
+}
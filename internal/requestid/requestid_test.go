@@ -0,0 +1,109 @@
+package requestid_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/requestid"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "no header", header: "", wantKept: false},
+		{name: "valid header", header: "abc-123", wantKept: true},
+		{name: "too long", header: strings.Repeat("a", 129), wantKept: false},
+		{name: "control characters", header: "abc\x00123", wantKept: false},
+		{name: "spaces", header: "abc 123", wantKept: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var got string
+			h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = requestid.FromContext(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(requestid.Header, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			if got == "" {
+				t.Fatalf("request id was not set on the context")
+			}
+			if (got == tt.header) != tt.wantKept {
+				t.Errorf("request id = %q, kept header %q = %v, want %v", got, tt.header, got == tt.header, tt.wantKept)
+			}
+			if rec.Header().Get(requestid.Header) != got {
+				t.Errorf("response header = %q, want %q", rec.Header().Get(requestid.Header), got)
+			}
+		})
+	}
+}
+
+func TestMiddleware_Nested(t *testing.T) {
+
+	var ids []string
+	inner := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, requestid.FromContext(r.Context()))
+	}))
+	h := requestid.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, requestid.FromContext(r.Context()))
+		inner.ServeHTTP(w, r)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(ids) != 2 || ids[0] != ids[1] {
+		t.Errorf("nested ids = %v, want the same id twice", ids)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := slog.New(requestid.NewLogHandler(slog.NewJSONHandler(&buf, nil))).With(slog.String("service", "test"))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want string
+	}{
+		{name: "with request id", ctx: requestid.NewContext(context.Background(), "abc"), want: "abc"},
+		{name: "without request id", ctx: context.Background(), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			buf.Reset()
+			logger.InfoContext(tt.ctx, "hello")
+
+			var line map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+				t.Fatalf("failed to decode the log line %q: %v", buf.String(), err)
+			}
+			got, _ := line["request_id"].(string)
+			if got != tt.want {
+				t.Errorf("request_id = %q, want %q", got, tt.want)
+			}
+			if line["service"] != "test" {
+				t.Errorf("service = %v, want test", line["service"])
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package secheaders
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a-h/templ"
+
+	"github.com/griggsjared/getsit/internal/realip"
+)
+
+// NoncePlaceholder is replaced with the nonce of each request in the content security policy
+const NoncePlaceholder = "{nonce}"
+
+// DefaultContentSecurityPolicy only allows scripts that carry the request nonce, the app's own styles and images,
+// the google fonts stylesheet and inline qr code images
+const DefaultContentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'nonce-" + NoncePlaceholder + "' 'strict-dynamic'; " +
+	"style-src 'self' https://fonts.googleapis.com; " +
+	"font-src 'self' https://fonts.gstatic.com; " +
+	"img-src 'self' data:; " +
+	"object-src 'none'; " +
+	"base-uri 'none'; " +
+	"form-action 'self'; " +
+	"frame-ancestors 'none'"
+
+// Config is the configuration for the security headers
+type Config struct {
+	ContentSecurityPolicy string        // The policy with an optional {nonce} placeholder, empty does not send the header
+	ReportOnly            bool          // Send the policy as Content-Security-Policy-Report-Only to try it out
+	HSTSMaxAge            time.Duration // The Strict-Transport-Security max age, only sent over https, 0 does not send the header
+	ReferrerPolicy        string        // The Referrer-Policy, empty does not send the header
+}
+
+// Middleware will set the security headers on every response.
+// A new nonce is created for each request and stored in the context with templ.WithNonce, so templates can add it
+// to their inline scripts with nonce={ templ.GetNonce(ctx) }.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+
+	cspHeader := "Content-Security-Policy"
+	if cfg.ReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Cross-Origin-Opener-Policy", "same-origin")
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.HSTSMaxAge > 0 && realip.FromRequest(r).Proto == "https" {
+				h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(cfg.HSTSMaxAge.Seconds())))
+			}
+
+			if cfg.ContentSecurityPolicy != "" {
+				nonce, err := newNonce()
+				if err != nil {
+					http.Error(w, "Failed to create the script nonce", http.StatusInternalServerError)
+					return
+				}
+				h.Set(cspHeader, strings.ReplaceAll(cfg.ContentSecurityPolicy, NoncePlaceholder, nonce))
+				r = r.WithContext(templ.WithNonce(r.Context(), nonce))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newNonce will return 16 random bytes encoded as base64
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
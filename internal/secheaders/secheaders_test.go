@@ -0,0 +1,103 @@
+package secheaders_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+
+	"github.com/griggsjared/getsit/internal/secheaders"
+)
+
+func TestMiddleware(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		cfg         secheaders.Config
+		tls         bool
+		wantHeaders map[string]string // An empty value means the header should not be set
+		wantNonce   bool
+	}{
+		{
+			name: "default policy",
+			cfg: secheaders.Config{
+				ContentSecurityPolicy: secheaders.DefaultContentSecurityPolicy,
+				ReferrerPolicy:        "strict-origin-when-cross-origin",
+				HSTSMaxAge:            time.Hour,
+			},
+			wantHeaders: map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "DENY",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Strict-Transport-Security": "",
+			},
+			wantNonce: true,
+		},
+		{
+			name: "hsts over https",
+			cfg:  secheaders.Config{HSTSMaxAge: time.Hour},
+			tls:  true,
+			wantHeaders: map[string]string{
+				"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+				"Content-Security-Policy":   "",
+				"Referrer-Policy":           "",
+			},
+		},
+		{
+			name: "report only",
+			cfg: secheaders.Config{
+				ContentSecurityPolicy: "script-src 'nonce-{nonce}'",
+				ReportOnly:            true,
+			},
+			wantHeaders: map[string]string{
+				"Content-Security-Policy": "",
+			},
+			wantNonce: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			var nonce string
+			h := secheaders.Middleware(tt.cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				nonce = templ.GetNonce(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+
+			for name, want := range tt.wantHeaders {
+				if got := rec.Header().Get(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+
+			if !tt.wantNonce {
+				if nonce != "" {
+					t.Errorf("nonce = %q, want none", nonce)
+				}
+				return
+			}
+
+			if nonce == "" {
+				t.Fatalf("nonce was not set on the context")
+			}
+			csp := rec.Header().Get("Content-Security-Policy")
+			if tt.cfg.ReportOnly {
+				csp = rec.Header().Get("Content-Security-Policy-Report-Only")
+			}
+			if !strings.Contains(csp, "'nonce-"+nonce+"'") {
+				t.Errorf("policy %q does not contain the nonce %q", csp, nonce)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/griggsjared/getsit/internal/tracing"
+)
+
+func TestSetup(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		exporter tracing.Exporter
+		wantErr  bool
+	}{
+		{
+			name:     "none exporter",
+			exporter: tracing.ExporterNone,
+			wantErr:  false,
+		},
+		{
+			name:     "empty exporter",
+			exporter: "",
+			wantErr:  false,
+		},
+		{
+			name:     "stdout exporter",
+			exporter: tracing.ExporterStdout,
+			wantErr:  false,
+		},
+		{
+			name:     "unknown exporter",
+			exporter: "zipkin",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+				ServiceName: "getsit-test",
+				Exporter:    tt.exporter,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if shutdown != nil {
+				shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func TestSetup_OTLPCollector(t *testing.T) {
+
+	// a local collector that only counts the export requests it receives
+	var exports atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			exports.Add(1)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	ctx := context.Background()
+	shutdown, err := tracing.Setup(ctx, tracing.Config{
+		ServiceName:  "getsit-test",
+		Exporter:     tracing.ExporterOTLP,
+		OTLPEndpoint: collector.URL + "/v1/traces",
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	_, span := tracing.Start(ctx, "test-span")
+	span.End()
+
+	if err := shutdown(ctx); err != nil {
+		t.Fatalf("shutdown() error = %v", err)
+	}
+
+	if exports.Load() == 0 {
+		t.Errorf("collector received no spans")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+
+	ctx := context.Background()
+	shutdown, err := tracing.Setup(ctx, tracing.Config{ServiceName: "getsit-test"})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	defer shutdown(ctx)
+
+	var got trace.SpanContext
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{token}", func(w http.ResponseWriter, r *http.Request) {
+		got = trace.SpanContextFromContext(r.Context())
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/abc12345", nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	tracing.Middleware(mux).ServeHTTP(httptest.NewRecorder(), r)
+
+	if got.TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID() = %v, want the inbound trace id", got.TraceID())
+	}
+	if got.SpanID().String() == "00f067aa0ba902b7" {
+		t.Errorf("SpanID() = %v, want a new child span", got.SpanID())
+	}
+}
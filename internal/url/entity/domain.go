@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Domain is a short domain links can be created on besides the base url.
+// The base url is the default domain, it has an id of 0 and an empty host.
+type Domain struct {
+	ID          int64
+	Host        string // The host the links of the domain are served on, with the port when it is not the default
+	FallbackUrl Url    // Where unknown tokens on the domain redirect to, empty shows the not found page
+	CreatedAt   time.Time
+}
+
+// Default will return true when the domain is the base url
+func (d *Domain) Default() bool {
+	return d.ID == 0
+}
+
+// NormalizeHost will lower case the host and remove the surrounding whitespace and a trailing dot
+func NormalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		return net.JoinHostPort(strings.TrimSuffix(h, "."), port)
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// ValidateHost will check that the host is a host name or ip address with an optional port and nothing else
+func ValidateHost(host string) error {
+	if host == "" {
+		return fmt.Errorf("host is required")
+	}
+	u, err := url.Parse("http://" + host)
+	if err != nil || u.Host != host || u.Hostname() == "" || u.User != nil {
+		return fmt.Errorf("host %q is not valid", host)
+	}
+	return nil
+}
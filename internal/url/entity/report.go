@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// MaxReportDescriptionLength is the longest description a report can have
+const MaxReportDescriptionLength = 1000
+
+// ReportCategory is the kind of abuse a url entry is reported for
+type ReportCategory string
+
+const (
+	ReportCategoryPhishing ReportCategory = "phishing" // Pretends to be another site to steal logins or payment details
+	ReportCategoryMalware  ReportCategory = "malware"  // Installs or runs harmful software
+	ReportCategorySpam     ReportCategory = "spam"     // Unwanted advertising
+	ReportCategoryIllegal  ReportCategory = "illegal"  // Content that is against the law
+	ReportCategoryOther    ReportCategory = "other"    // Anything else, the description should say what
+)
+
+// ReportCategories are the categories in the order they are offered to reporters
+var ReportCategories = []ReportCategory{
+	ReportCategoryPhishing,
+	ReportCategoryMalware,
+	ReportCategorySpam,
+	ReportCategoryIllegal,
+	ReportCategoryOther,
+}
+
+// Validate will check if the category is known
+func (c ReportCategory) Validate() error {
+	for _, known := range ReportCategories {
+		if c == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("category must be one of %s, %s, %s, %s or %s", ReportCategoryPhishing, ReportCategoryMalware, ReportCategorySpam, ReportCategoryIllegal, ReportCategoryOther)
+}
+
+// UrlReport is a report of abuse of a url entry, it is open until an admin resolves it
+type UrlReport struct {
+	ID           int64
+	Category     ReportCategory
+	Description  string // Optional details from the reporter
+	ReporterHash string // The hash of who made the report, see HashReporter
+	UserID       int64  // The logged in user that made the report, 0 for an anonymous report
+	CreatedAt    time.Time
+}
+
+// HashReporter will return the hash of who made a report that is stored, a reporter has one open report per entry.
+// The hash is keyed with a server secret, without it every ip address could be hashed to find the reporter.
+func HashReporter(key []byte, reporter string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(reporter))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ReportedUrl is a url entry with open reports waiting in the moderation queue
+type ReportedUrl struct {
+	Entry        *UrlEntry
+	CreatorEmail string       // The email of the user that created the entry, empty when it is anonymous or not known
+	OpenReports  int          // The number of open reports, each from a different reporter
+	Reports      []*UrlReport // The open reports, newest first
+	Visits       []VisitDay   // The human visits of the last days, oldest first
+}
+
+// VisitDay is the number of visits of a url entry on a day
+type VisitDay struct {
+	Day   time.Time // The start of the day in UTC
+	Count int
+}
@@ -0,0 +1,59 @@
+package entity_test
+
+import (
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/url/entity"
+)
+
+func TestReportCategory_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		category entity.ReportCategory
+		wantErr  bool
+	}{
+		{
+			name:     "phishing",
+			category: entity.ReportCategoryPhishing,
+			wantErr:  false,
+		},
+		{
+			name:     "other",
+			category: entity.ReportCategoryOther,
+			wantErr:  false,
+		},
+		{
+			name:     "unknown category",
+			category: entity.ReportCategory("rude"),
+			wantErr:  true,
+		},
+		{
+			name:     "empty category",
+			category: entity.ReportCategory(""),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.category.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHashReporter(t *testing.T) {
+	key := []byte("secret")
+	if entity.HashReporter(key, "1.1.1.1") != entity.HashReporter(key, "1.1.1.1") {
+		t.Errorf("HashReporter() is not stable")
+	}
+	if entity.HashReporter(key, "1.1.1.1") == entity.HashReporter(key, "2.2.2.2") {
+		t.Errorf("HashReporter() is the same for different reporters")
+	}
+	if entity.HashReporter(key, "1.1.1.1") == entity.HashReporter([]byte("other"), "1.1.1.1") {
+		t.Errorf("HashReporter() is the same for different keys")
+	}
+	if entity.HashReporter(key, "1.1.1.1") == "1.1.1.1" {
+		t.Errorf("HashReporter() kept the reporter")
+	}
+}
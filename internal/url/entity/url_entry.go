@@ -2,31 +2,69 @@ package entity
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"net/url"
 	"regexp"
+	"time"
 )
 
 const (
-	tokenBytes        = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890_"
-	tokenLength uint8 = 8
+	tokenBytes               = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890_"
+	readableTokenBytes       = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	tokenLength        uint8 = 8
 )
 
+// TokenStrategy decides which characters are used when generating a new url token.
+// Every strategy generates tokens that pass UrlToken.Validate.
+type TokenStrategy string
+
+const (
+	TokenStrategyRandom   TokenStrategy = "random"   // Upper and lower case letters, numbers and underscores
+	TokenStrategyReadable TokenStrategy = "readable" // Excludes characters that are easily confused, like 0, O, 1, l and I
+)
+
+// Validate will check if the token strategy is known
+func (s TokenStrategy) Validate() error {
+	switch s {
+	case TokenStrategyRandom, TokenStrategyReadable:
+		return nil
+	}
+	return fmt.Errorf("token strategy must be one of %s or %s", TokenStrategyRandom, TokenStrategyReadable)
+}
+
+// NewUrlToken will generate a new url token using the characters of the strategy.
+// An empty strategy is treated as TokenStrategyRandom.
+func (s TokenStrategy) NewUrlToken() (UrlToken, error) {
+	if s == TokenStrategyReadable {
+		return newUrlTokenFrom(readableTokenBytes)
+	}
+	return newUrlTokenFrom(tokenBytes)
+}
+
 // UrlToken is a short string that will be used to access the long url.
 // This token is 8 characters long and can be and upper or lower case letter or a number
 type UrlToken string
 
 // NewUrlToken will generate a new url token that is 8 characters long.
 func NewUrlToken() (UrlToken, error) {
+	return newUrlTokenFrom(tokenBytes)
+}
+
+// newUrlTokenFrom will generate a new url token that is 8 characters long from the given characters.
+func newUrlTokenFrom(chars string) (UrlToken, error) {
 	var token UrlToken
-	tokenBytesLen := big.NewInt(int64(len(tokenBytes)))
+	tokenBytesLen := big.NewInt(int64(len(chars)))
 	for i := 0; i < int(tokenLength); i++ {
 		randomIdx, err := rand.Int(rand.Reader, tokenBytesLen)
 		if err != nil {
 			return "", err
 		}
-		token += UrlToken(tokenBytes[randomIdx.Int64()])
+		token += UrlToken(chars[randomIdx.Int64()])
 	}
 
 	return token, nil
@@ -71,11 +109,80 @@ func (u Url) String() string {
 	return string(u)
 }
 
+// UrlStatus is whether a url entry redirects
+type UrlStatus string
+
+const (
+	UrlStatusActive    UrlStatus = "active"     // The token redirects to the long url
+	UrlStatusDisabled  UrlStatus = "disabled"   // The owner has stopped the token from redirecting
+	UrlStatusHeld      UrlStatus = "held"       // Reports or an admin have stopped the token from redirecting until an admin lifts the hold
+	UrlStatusDeleted   UrlStatus = "deleted"    // The owner has deleted the entry, the token is kept so it is never reused
+	UrlStatusTakenDown UrlStatus = "taken-down" // An admin has removed the entry for a legal reason, see TakedownReason
+)
+
+// Validate will check if the status is known
+func (s UrlStatus) Validate() error {
+	switch s {
+	case UrlStatusActive, UrlStatusDisabled, UrlStatusHeld, UrlStatusDeleted, UrlStatusTakenDown:
+		return nil
+	}
+	return fmt.Errorf("status must be one of %s, %s, %s, %s or %s", UrlStatusActive, UrlStatusDisabled, UrlStatusHeld, UrlStatusDeleted, UrlStatusTakenDown)
+}
+
 // UrlEntry is the domain entity that will store the long url, token, and the number of times the url has been visited
 type UrlEntry struct {
-	Url        Url      // The long url
-	Token      UrlToken // The token is a short string that will be used to access the long url
-	VisitCount int      // The number of times the url has been visited
+	Url           Url       // The long url
+	Token         UrlToken  // The token is a short string that will be used to access the long url, unique per domain
+	DomainID      int64     // The id of the short domain the token is on, 0 for the base url
+	Domain        string    // The host of the short domain the token is on, empty for the base url
+	VisitCount    int       // The number of times the url has been visited, including bots
+	BotVisitCount int       // The number of visits that were classified as bots or link unfurlers
+	OwnerID       int64     // The id of the user that created the entry, 0 when it was created anonymously or with an api key
+	WorkspaceID   int64     // The id of the workspace the entry belongs to, 0 for personal and anonymous entries
+	Status        UrlStatus // Whether the token redirects
+	CreatedAt     time.Time
+
+	// TakedownReason is why an admin took the entry down, empty unless the status is UrlStatusTakenDown
+	TakedownReason string
+
+	// ManageTokenHash is the hash of the secret that was given to the creator to manage the entry,
+	// empty for entries created before management tokens existed
+	ManageTokenHash string
+}
+
+// CanManage will return true when the user owns the entry or the manage token is the entry's secret.
+// The entries of a workspace are neither owned by their creator nor managed with a token, only through the roles of the workspace.
+func (e *UrlEntry) CanManage(ownerID int64, manageToken string) bool {
+	if e.WorkspaceID != 0 {
+		return false
+	}
+	if e.OwnerID != 0 && e.OwnerID == ownerID {
+		return true
+	}
+	if manageToken == "" || e.ManageTokenHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashManageToken(manageToken)), []byte(e.ManageTokenHash)) == 1
+}
+
+// Active will return true when the token should redirect to the long url
+func (e *UrlEntry) Active() bool {
+	return e.Status == UrlStatusActive || e.Status == ""
+}
+
+// Held will return true when the entry is on hold for moderation, its managers can not enable it
+func (e *UrlEntry) Held() bool {
+	return e.Status == UrlStatusHeld
+}
+
+// TakenDown will return true when an admin has removed the entry, its managers can no longer change it
+func (e *UrlEntry) TakenDown() bool {
+	return e.Status == UrlStatusTakenDown
+}
+
+// HumanVisitCount will return the number of visits that were not classified as bots
+func (e *UrlEntry) HumanVisitCount() int {
+	return e.VisitCount - e.BotVisitCount
 }
 
 // NewUrlEntry will create a new url entry from primitive types
@@ -84,5 +191,55 @@ func NewUrlEntry(url string, token string, visitCount int) *UrlEntry {
 		Url:        Url(url),
 		Token:      UrlToken(token),
 		VisitCount: visitCount,
+		Status:     UrlStatusActive,
 	}
 }
+
+// manageTokenBytes is the number of random bytes in a manage token
+const manageTokenBytes = 24
+
+// NewManageToken will generate a new secret manage token and its hash, only the hash should be stored
+func NewManageToken() (token string, hash string, err error) {
+	b := make([]byte, manageTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashManageToken(token), nil
+}
+
+// HashManageToken will return the hash of the manage token that is stored
+func HashManageToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// UrlVisit is a single visit to a url entry
+type UrlVisit struct {
+	Token          UrlToken // The token of the url entry that was visited
+	DomainID       int64    // The id of the short domain the token is on, 0 for the base url
+	IsBot          bool     // Whether the visit was classified as a bot, crawler or link unfurler
+	BrowserFamily  string   // The browser family parsed from the user agent
+	BrowserVersion string   // The major version of the browser parsed from the user agent
+	OS             string   // The operating system parsed from the user agent
+	DeviceClass    string   // The device class parsed from the user agent, mobile, tablet, desktop or bot
+	Country        string   // The ISO country code resolved from the client ip
+	Region         string   // The region resolved from the client ip
+	City           string   // The city resolved from the client ip
+
+	CreatedAt time.Time // When the visit was made, set by the repository when it is saved
+}
+
+// UrlRevision is a change of where a url entry redirects to
+type UrlRevision struct {
+	ID        int64
+	OldUrl    Url    // Where the token redirected to before the change
+	NewUrl    Url    // Where the token redirects to after the change
+	UserID    int64  // The user that made the change, 0 when it was made with the manage token or an api key
+	UserEmail string // The email of the user that made the change, empty when it is not known
+	APIKeyID  int64  // The workspace api key the change was made with, 0 when it was not made with one
+	CreatedAt time.Time
+
+	// UserHidden is set when a user made the change but who they are is hidden from the viewer, UserID and UserEmail are then empty
+	UserHidden bool
+}
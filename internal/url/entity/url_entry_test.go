@@ -1,6 +1,7 @@
 package entity_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/griggsjared/getsit/internal/url/entity"
@@ -174,3 +175,82 @@ func TestNewUrlEntry(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenStrategy_NewUrlToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy entity.TokenStrategy
+		wantErr  bool
+	}{
+		{
+			name:     "random strategy",
+			strategy: entity.TokenStrategyRandom,
+			wantErr:  false,
+		},
+		{
+			name:     "readable strategy",
+			strategy: entity.TokenStrategyReadable,
+			wantErr:  false,
+		},
+		{
+			name:     "unknown strategy",
+			strategy: entity.TokenStrategy("sequential"),
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.strategy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("TokenStrategy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			token, err := tt.strategy.NewUrlToken()
+			if err != nil {
+				t.Errorf("TokenStrategy.NewUrlToken() error = %v", err)
+			}
+			if err := token.Validate(); err != nil {
+				t.Errorf("TokenStrategy.NewUrlToken() = %v, %v", token, err)
+			}
+			if tt.strategy == entity.TokenStrategyReadable && strings.ContainsAny(token.String(), "0O1lI_") {
+				t.Errorf("TokenStrategy.NewUrlToken() = %v, contains an ambiguous character", token)
+			}
+		})
+	}
+}
+
+func TestUrlEntry_CanManage(t *testing.T) {
+
+	token, hash, err := entity.NewManageToken()
+	if err != nil {
+		t.Fatalf("NewManageToken() error = %v", err)
+	}
+	other, _, _ := entity.NewManageToken()
+	if token == other {
+		t.Fatalf("NewManageToken() returned the same token twice")
+	}
+
+	tests := []struct {
+		name        string
+		entry       entity.UrlEntry
+		ownerID     int64
+		manageToken string
+		want        bool
+	}{
+		{name: "owner", entry: entity.UrlEntry{OwnerID: 1}, ownerID: 1, want: true},
+		{name: "another user", entry: entity.UrlEntry{OwnerID: 1}, ownerID: 2, want: false},
+		{name: "creator of a workspace entry", entry: entity.UrlEntry{OwnerID: 1, WorkspaceID: 3}, ownerID: 1, want: false},
+		{name: "manage token of a workspace entry", entry: entity.UrlEntry{OwnerID: 1, WorkspaceID: 3, ManageTokenHash: hash}, manageToken: token, want: false},
+		{name: "anonymous entry without a token", entry: entity.UrlEntry{ManageTokenHash: hash}, ownerID: 0, want: false},
+		{name: "manage token", entry: entity.UrlEntry{ManageTokenHash: hash}, manageToken: token, want: true},
+		{name: "manage token of an owned entry", entry: entity.UrlEntry{OwnerID: 1, ManageTokenHash: hash}, ownerID: 2, manageToken: token, want: true},
+		{name: "wrong manage token", entry: entity.UrlEntry{ManageTokenHash: hash}, manageToken: other, want: false},
+		{name: "entry without a manage token", entry: entity.UrlEntry{}, manageToken: token, want: false},
+		{name: "hash as the token", entry: entity.UrlEntry{ManageTokenHash: hash}, manageToken: hash, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.CanManage(tt.ownerID, tt.manageToken); got != tt.want {
+				t.Errorf("CanManage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
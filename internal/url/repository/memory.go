@@ -3,20 +3,55 @@ package repository
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/griggsjared/getsit/internal/url"
 	"github.com/griggsjared/getsit/internal/url/entity"
 )
 
-// memEntriesTokenMap is a map that will repository the url entry with the token as the key
-type memEntriesTokenMap map[entity.UrlToken]*entity.UrlEntry
+// memEntriesTokenKey is the token and its domain, tokens are unique per domain
+type memEntriesTokenKey struct {
+	domainID int64
+	token    entity.UrlToken
+}
+
+// memEntriesTokenMap is a map that will repository the url entry with the token and domain as the key
+type memEntriesTokenMap map[memEntriesTokenKey]*entity.UrlEntry
 
-// memEntriesUrlMap is a map that will repository the url entry with the url as the key
-type memEntriesUrlMap map[entity.Url]*entity.UrlEntry
+// memEntriesUrlKey is the url, the domain and the owner, urls are unique per owner on each domain.
+// The entries of a workspace are keyed by the workspace only, whoever created them.
+type memEntriesUrlKey struct {
+	url         entity.Url
+	domainID    int64
+	ownerID     int64
+	workspaceID int64
+}
+
+// urlKey will return the key of the url on the domain for the owner or workspace
+func urlKey(url entity.Url, domainID int64, ownerID int64, workspaceID int64) memEntriesUrlKey {
+	if workspaceID != 0 {
+		ownerID = 0
+	}
+	return memEntriesUrlKey{url, domainID, ownerID, workspaceID}
+}
+
+// memEntriesUrlMap is a map that will repository the url entry with the url and owner as the key
+type memEntriesUrlMap map[memEntriesUrlKey]*entity.UrlEntry
 
 // MemUrlEntryRepository is a in memory repository that will repository the url entries
 type MemUrlEntryRepository struct {
-	entriesToken memEntriesTokenMap //key is the token and value is the url entry for a fast lookup ( O(1) )
-	entriesUrl   memEntriesUrlMap   //key is the url and value is the url entry for a fast lookup ( O(1) )
+	entriesToken memEntriesTokenMap                           //key is the token and value is the url entry for a fast lookup ( O(1) )
+	entriesUrl   memEntriesUrlMap                             //key is the url and owner and value is the url entry for a fast lookup ( O(1) )
+	visits       []*entity.UrlVisit                           //every recorded visit in the order they were saved
+	entries      []*entity.UrlEntry                           //every entry in the order they were saved
+	domains      []*entity.Domain                             //every short domain, the id is the position plus one
+	revisions    map[memEntriesTokenKey][]*entity.UrlRevision //the revisions of each entry in the order they were made
+	revisionID   int64                                        //the id of the last revision
+	reports      map[memEntriesTokenKey][]*entity.UrlReport   //the open reports of each entry in the order they were made
+	reportID     int64                                        //the id of the last report
+	tokens       entity.TokenStrategy
 }
 
 // NewMemUrlEntryRepository will create a new in memory repository
@@ -24,63 +59,275 @@ func NewMemUrlEntryRepository() *MemUrlEntryRepository {
 	return &MemUrlEntryRepository{
 		entriesToken: make(memEntriesTokenMap),
 		entriesUrl:   make(memEntriesUrlMap),
+		revisions:    make(map[memEntriesTokenKey][]*entity.UrlRevision),
+		reports:      make(map[memEntriesTokenKey][]*entity.UrlReport),
 	}
 }
 
+// WithTokenStrategy will set the strategy used to generate tokens for new url entries
+func (s *MemUrlEntryRepository) WithTokenStrategy(strategy entity.TokenStrategy) *MemUrlEntryRepository {
+	s.tokens = strategy
+	return s
+}
+
 // Save will save the url entry to the repository
-func (s *MemUrlEntryRepository) SaveUrl(ctx context.Context, url entity.Url) (*entity.UrlEntry, error) {
+func (s *MemUrlEntryRepository) SaveUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64, manageTokenHash string) (*entity.UrlEntry, error) {
 
 	var entry *entity.UrlEntry
 
-	//if the url already exists escape with an error
-	if _, ok := s.entriesUrl[url]; ok {
+	//if the url already exists for the owner escape with an error
+	if _, ok := s.entriesUrl[urlKey(url, domainID, ownerID, workspaceID)]; ok {
 		return nil, fmt.Errorf("entry already exists")
 	}
 
+	host := ""
+	if domainID != 0 {
+		if domainID > int64(len(s.domains)) {
+			return nil, fmt.Errorf("domain not found")
+		}
+		host = s.domains[domainID-1].Host
+	}
+
 	var token entity.UrlToken
 	var err error
 	for {
-		token, err = entity.NewUrlToken()
+		token, err = s.tokens.NewUrlToken()
 		if err != nil {
 			return nil, err
 		}
-		if _, ok := s.entriesToken[token]; !ok {
+		if _, ok := s.entriesToken[memEntriesTokenKey{domainID, token}]; !ok {
 			break
 		}
 	}
 	entry = &entity.UrlEntry{
-		Url:        url,
-		Token:      token,
-		VisitCount: 0,
+		Url:         url,
+		Token:       token,
+		DomainID:    domainID,
+		Domain:      host,
+		VisitCount:  0,
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		Status:      entity.UrlStatusActive,
+		CreatedAt:   time.Now(),
+
+		ManageTokenHash: manageTokenHash,
 	}
 
-	s.entriesToken[entry.Token] = entry
-	s.entriesUrl[entry.Url] = entry
+	s.entriesToken[memEntriesTokenKey{domainID, entry.Token}] = entry
+	s.entriesUrl[urlKey(entry.Url, domainID, ownerID, workspaceID)] = entry
+	s.entries = append(s.entries, entry)
 
 	return entry, nil
 }
 
-// SaveVisit will increment the number of times the url has been visited
-func (s *MemUrlEntryRepository) SaveVisit(ctx context.Context, token entity.UrlToken) error {
-	if e, ok := s.entriesToken[token]; ok {
+// SaveVisit will record the visit and increment the number of times the url has been visited
+func (s *MemUrlEntryRepository) SaveVisit(ctx context.Context, visit *entity.UrlVisit) error {
+	if e, ok := s.entriesToken[memEntriesTokenKey{visit.DomainID, visit.Token}]; ok {
 		e.VisitCount++
+		if visit.IsBot {
+			e.BotVisitCount++
+		}
+		if visit.CreatedAt.IsZero() {
+			visit.CreatedAt = time.Now()
+		}
+		s.visits = append(s.visits, visit)
 		return nil
 	}
 	return fmt.Errorf("entry not found")
 }
 
-// GetFromToken will return the url entry for the given token
-func (s *MemUrlEntryRepository) GetFromToken(ctx context.Context, token entity.UrlToken) (*entity.UrlEntry, error) {
-	if e, ok := s.entriesToken[token]; ok {
+// GetFromToken will return the url entry for the given token on the domain
+func (s *MemUrlEntryRepository) GetFromToken(ctx context.Context, domainID int64, token entity.UrlToken) (*entity.UrlEntry, error) {
+	if e, ok := s.entriesToken[memEntriesTokenKey{domainID, token}]; ok {
 		return e, nil
 	}
 	return nil, fmt.Errorf("entry not found")
 }
 
-// GetFromUrl will return the url entry for the given url
-func (s *MemUrlEntryRepository) GetFromUrl(ctx context.Context, url entity.Url) (*entity.UrlEntry, error) {
-	if e, ok := s.entriesUrl[url]; ok {
+// GetFromUrl will return the active url entry of the owner or workspace on the domain for the given url
+func (s *MemUrlEntryRepository) GetFromUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64) (*entity.UrlEntry, error) {
+	if e, ok := s.entriesUrl[urlKey(url, domainID, ownerID, workspaceID)]; ok {
 		return e, nil
 	}
 	return nil, fmt.Errorf("entry not found")
 }
+
+// ListByOwner will return a page of the url entries of the owner or workspace
+func (s *MemUrlEntryRepository) ListByOwner(ctx context.Context, query url.ListQuery) ([]*entity.UrlEntry, int, error) {
+
+	search := strings.ToLower(query.Search)
+	key := urlKey("", 0, query.OwnerID, query.WorkspaceID)
+
+	var matches []*entity.UrlEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		if urlKey("", 0, e.OwnerID, e.WorkspaceID) != key || e.Status == entity.UrlStatusDeleted {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(e.Url.String()), search) && !strings.Contains(strings.ToLower(e.Token.String()), search) {
+			continue
+		}
+		matches = append(matches, e)
+	}
+
+	if query.Sort == url.UrlSortVisits {
+		slices.SortStableFunc(matches, func(a, b *entity.UrlEntry) int {
+			return b.VisitCount - a.VisitCount
+		})
+	}
+
+	total := len(matches)
+	start := min(query.Offset, total)
+	end := min(start+query.Limit, total)
+	return matches[start:end], total, nil
+}
+
+// UpdateUrl will change the long url of the owner's entry and record the revision
+func (s *MemUrlEntryRepository) UpdateUrl(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, revision *entity.UrlRevision) error {
+	key := memEntriesTokenKey{domainID, token}
+	e, ok := s.entriesToken[key]
+	if !ok || e.OwnerID != ownerID {
+		return url.ErrNotFound
+	}
+	if _, ok := s.entriesUrl[urlKey(revision.NewUrl, domainID, ownerID, e.WorkspaceID)]; ok && e.Active() {
+		return fmt.Errorf("entry already exists")
+	}
+	if s.entriesUrl[urlKey(e.Url, domainID, ownerID, e.WorkspaceID)] == e {
+		delete(s.entriesUrl, urlKey(e.Url, domainID, ownerID, e.WorkspaceID))
+	}
+	s.revisionID++
+	revision.ID = s.revisionID
+	revision.OldUrl = e.Url
+	revision.CreatedAt = time.Now()
+	s.revisions[key] = append(s.revisions[key], revision)
+	e.Url = revision.NewUrl
+	if e.Active() {
+		s.entriesUrl[urlKey(e.Url, domainID, ownerID, e.WorkspaceID)] = e
+	}
+	return nil
+}
+
+// ListRevisions will return the revisions of the entry, newest first
+func (s *MemUrlEntryRepository) ListRevisions(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlRevision, error) {
+	revisions := slices.Clone(s.revisions[memEntriesTokenKey{domainID, token}])
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// SetStatus will change the status of the owner's entry, only the active entries hold their url
+func (s *MemUrlEntryRepository) SetStatus(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, status entity.UrlStatus, reason string) error {
+	e, ok := s.entriesToken[memEntriesTokenKey{domainID, token}]
+	if !ok || e.OwnerID != ownerID {
+		return url.ErrNotFound
+	}
+	key := urlKey(e.Url, domainID, ownerID, e.WorkspaceID)
+	if status == entity.UrlStatusActive {
+		if other, ok := s.entriesUrl[key]; ok && other != e {
+			return fmt.Errorf("entry already exists")
+		}
+		s.entriesUrl[key] = e
+	} else if s.entriesUrl[key] == e {
+		delete(s.entriesUrl, key)
+	}
+	e.Status = status
+	e.TakedownReason = reason
+	return nil
+}
+
+// CountVisitsByDay will return the number of human visits of the entry on each day since the time
+func (s *MemUrlEntryRepository) CountVisitsByDay(ctx context.Context, domainID int64, token entity.UrlToken, since time.Time) ([]entity.VisitDay, error) {
+	var days []entity.VisitDay
+	for _, v := range s.visits {
+		if v.DomainID != domainID || v.Token != token || v.IsBot || v.CreatedAt.Before(since) {
+			continue
+		}
+		day := v.CreatedAt.UTC().Truncate(24 * time.Hour)
+		if len(days) > 0 && days[len(days)-1].Day.Equal(day) {
+			days[len(days)-1].Count++
+			continue
+		}
+		days = append(days, entity.VisitDay{Day: day, Count: 1})
+	}
+	return days, nil
+}
+
+// SaveReport will add an open report to the entry unless the reporter already has one
+func (s *MemUrlEntryRepository) SaveReport(ctx context.Context, domainID int64, token entity.UrlToken, report *entity.UrlReport) (int, error) {
+	key := memEntriesTokenKey{domainID, token}
+	if _, ok := s.entriesToken[key]; !ok {
+		return 0, url.ErrNotFound
+	}
+	for _, r := range s.reports[key] {
+		if r.ReporterHash == report.ReporterHash {
+			return len(s.reports[key]), nil
+		}
+	}
+	s.reportID++
+	report.ID = s.reportID
+	report.CreatedAt = time.Now()
+	s.reports[key] = append(s.reports[key], report)
+	return len(s.reports[key]), nil
+}
+
+// ListReports will return the open reports of the entry, newest first
+func (s *MemUrlEntryRepository) ListReports(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlReport, error) {
+	reports := slices.Clone(s.reports[memEntriesTokenKey{domainID, token}])
+	slices.Reverse(reports)
+	return reports, nil
+}
+
+// ListReported will return a page of the held entries and the active and disabled entries with open reports, the most reported first
+func (s *MemUrlEntryRepository) ListReported(ctx context.Context, limit int, offset int) ([]*entity.ReportedUrl, int, error) {
+	var matches []*entity.ReportedUrl
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		e := s.entries[i]
+		reports := s.reports[memEntriesTokenKey{e.DomainID, e.Token}]
+		reported := len(reports) > 0 && (e.Status == entity.UrlStatusActive || e.Status == entity.UrlStatusDisabled)
+		if !reported && e.Status != entity.UrlStatusHeld {
+			continue
+		}
+		matches = append(matches, &entity.ReportedUrl{Entry: e, OpenReports: len(reports)})
+	}
+	slices.SortStableFunc(matches, func(a, b *entity.ReportedUrl) int {
+		return b.OpenReports - a.OpenReports
+	})
+
+	total := len(matches)
+	start := min(offset, total)
+	end := min(start+limit, total)
+	return matches[start:end], total, nil
+}
+
+// ResolveReports will close the open reports of the entry
+func (s *MemUrlEntryRepository) ResolveReports(ctx context.Context, domainID int64, token entity.UrlToken) error {
+	delete(s.reports, memEntriesTokenKey{domainID, token})
+	return nil
+}
+
+// SaveDomain will add the short domain, or change the fallback url of the domain with the same host
+func (s *MemUrlEntryRepository) SaveDomain(ctx context.Context, host string, fallbackUrl entity.Url) (*entity.Domain, error) {
+	for _, d := range s.domains {
+		if d.Host == host {
+			d.FallbackUrl = fallbackUrl
+			return d, nil
+		}
+	}
+	d := &entity.Domain{
+		ID:          int64(len(s.domains) + 1),
+		Host:        host,
+		FallbackUrl: fallbackUrl,
+		CreatedAt:   time.Now(),
+	}
+	s.domains = append(s.domains, d)
+	return d, nil
+}
+
+// GetDomainByHost will return the short domain with the host
+func (s *MemUrlEntryRepository) GetDomainByHost(ctx context.Context, host string) (*entity.Domain, error) {
+	for _, d := range s.domains {
+		if d.Host == host {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("domain not found")
+}
@@ -2,45 +2,122 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/url"
 	"github.com/griggsjared/getsit/internal/url/entity"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// dbSystem is the span attribute that marks the repository spans as postgres queries
+var dbSystem = attribute.String("db.system", "postgresql")
+
 type PGXUrlEntryRepository struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	tokens entity.TokenStrategy
+	logger *slog.Logger
 }
 
 func NewPGXUrlEntryRepository(db *pgxpool.Pool) *PGXUrlEntryRepository {
 	return &PGXUrlEntryRepository{
-		db: db,
+		db:     db,
+		logger: slog.Default(),
+	}
+}
+
+// WithLogger will set the logger failed queries are logged to
+func (s *PGXUrlEntryRepository) WithLogger(logger *slog.Logger) *PGXUrlEntryRepository {
+	s.logger = logger
+	return s
+}
+
+// logError will log a failed query with the request context and return the error.
+// pgx.ErrNoRows is not logged as it is the expected result of a lookup that finds nothing.
+func (s *PGXUrlEntryRepository) logError(ctx context.Context, op string, err error) error {
+	if !errors.Is(err, pgx.ErrNoRows) {
+		s.logger.ErrorContext(ctx, "query failed", slog.String("op", op), slog.String("error", err.Error()))
 	}
+	return err
+}
+
+// WithTokenStrategy will set the strategy used to generate tokens for new url entries
+func (s *PGXUrlEntryRepository) WithTokenStrategy(strategy entity.TokenStrategy) *PGXUrlEntryRepository {
+	s.tokens = strategy
+	return s
 }
 
+// urlEntryColumns are the columns scanned by scanUrlEntry
+const urlEntryColumns = "token, COALESCE(domain_id, 0), COALESCE((SELECT host FROM domains WHERE domains.id = url_entries.domain_id), ''), url, visit_count, bot_visit_count, COALESCE(owner_id, 0), COALESCE(workspace_id, 0), status, created_at, COALESCE(manage_token_hash, ''), takedown_reason"
+
 type urlEntry struct {
-	Token      string
-	Url        string
-	VisitCount int
+	Token           string
+	DomainID        int64
+	Domain          string
+	Url             string
+	VisitCount      int
+	BotVisitCount   int
+	OwnerID         int64
+	WorkspaceID     int64
+	Status          string
+	CreatedAt       time.Time
+	ManageTokenHash string
+	TakedownReason  string
 }
 
-func (s *PGXUrlEntryRepository) SaveUrl(ctx context.Context, url entity.Url) (*entity.UrlEntry, error) {
+// scanUrlEntry will scan a row of the urlEntryColumns into a url entry
+func scanUrlEntry(row pgx.Row) (*entity.UrlEntry, error) {
+	var urlEntry urlEntry
+	err := row.Scan(&urlEntry.Token, &urlEntry.DomainID, &urlEntry.Domain, &urlEntry.Url, &urlEntry.VisitCount, &urlEntry.BotVisitCount, &urlEntry.OwnerID, &urlEntry.WorkspaceID, &urlEntry.Status, &urlEntry.CreatedAt, &urlEntry.ManageTokenHash, &urlEntry.TakedownReason)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.UrlEntry{
+		Token:         entity.UrlToken(urlEntry.Token),
+		DomainID:      urlEntry.DomainID,
+		Domain:        urlEntry.Domain,
+		Url:           entity.Url(urlEntry.Url),
+		VisitCount:    urlEntry.VisitCount,
+		BotVisitCount: urlEntry.BotVisitCount,
+		OwnerID:       urlEntry.OwnerID,
+		WorkspaceID:   urlEntry.WorkspaceID,
+		Status:        entity.UrlStatus(urlEntry.Status),
+		CreatedAt:     urlEntry.CreatedAt,
 
-	//if the url already exists escape with an error
-	_, err := s.GetFromUrl(ctx, url)
+		ManageTokenHash: urlEntry.ManageTokenHash,
+		TakedownReason:  urlEntry.TakedownReason,
+	}, nil
+}
+
+func (s *PGXUrlEntryRepository) SaveUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64, manageTokenHash string) (*entity.UrlEntry, error) {
+
+	defer metrics.ObserveRepositoryQuery("SaveUrl", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.SaveUrl", dbSystem)
+	defer span.End()
+
+	//if the url already exists for the owner escape with an error
+	_, err := s.GetFromUrl(ctx, url, domainID, ownerID, workspaceID)
 	if err == nil {
 		return nil, fmt.Errorf("entry already exists")
 	}
 
-	//find a unique token
+	//find a token that is unique on the domain
 	var token entity.UrlToken
 	for {
-		token, err = entity.NewUrlToken()
+		token, err = s.tokens.NewUrlToken()
 		if err != nil {
-			return nil, err
+			return nil, s.logError(ctx, "SaveUrl", err)
 		}
-		_, err := s.GetFromToken(ctx, token)
+		_, err := s.GetFromToken(ctx, domainID, token)
 		if err != nil {
 			break
 		}
@@ -48,88 +125,578 @@ func (s *PGXUrlEntryRepository) SaveUrl(ctx context.Context, url entity.Url) (*e
 
 	tx, err := s.db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return nil, s.logError(ctx, "SaveUrl", err)
 	}
 	defer tx.Rollback(ctx)
 
 	query := `
-		INSERT INTO url_entries (url, token)
-		VALUES ($1, $2)
+		INSERT INTO url_entries (url, token, owner_id, workspace_id, manage_token_hash, domain_id)
+		VALUES ($1, $2, NULLIF($3::bigint, 0), NULLIF($4::bigint, 0), NULLIF($5, ''), NULLIF($6::bigint, 0))
+		RETURNING created_at, COALESCE((SELECT host FROM domains WHERE domains.id = url_entries.domain_id), '')
 	`
-	_, err = s.db.Exec(ctx, query, url, token.String())
+	var createdAt time.Time
+	var host string
+	err = tx.QueryRow(ctx, query, url, token.String(), ownerID, workspaceID, manageTokenHash, domainID).Scan(&createdAt, &host)
 	if err != nil {
-		return nil, err
+		return nil, s.logError(ctx, "SaveUrl", err)
 	}
 
 	err = tx.Commit(ctx)
 	if err != nil {
-		return nil, err
+		return nil, s.logError(ctx, "SaveUrl", err)
 	}
 
 	return &entity.UrlEntry{
-		Token:      token,
-		Url:        entity.Url(url),
-		VisitCount: 0,
+		Token:       token,
+		DomainID:    domainID,
+		Domain:      host,
+		Url:         entity.Url(url),
+		VisitCount:  0,
+		OwnerID:     ownerID,
+		WorkspaceID: workspaceID,
+		Status:      entity.UrlStatusActive,
+		CreatedAt:   createdAt,
+
+		ManageTokenHash: manageTokenHash,
 	}, nil
 }
 
-func (s *PGXUrlEntryRepository) SaveVisit(ctx context.Context, token entity.UrlToken) error {
+func (s *PGXUrlEntryRepository) SaveVisit(ctx context.Context, visit *entity.UrlVisit) error {
+
+	defer metrics.ObserveRepositoryQuery("SaveVisit", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.SaveVisit", dbSystem)
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return s.logError(ctx, "SaveVisit", err)
+	}
+	defer tx.Rollback(ctx)
 
 	query := `
 		UPDATE url_entries
-		SET visit_count = visit_count + 1
-		WHERE token = $1
+		SET visit_count = visit_count + 1,
+			bot_visit_count = bot_visit_count + CASE WHEN $2 THEN 1 ELSE 0 END
+		WHERE token = $1 AND COALESCE(domain_id, 0) = $3
+		RETURNING id
 	`
 
-	_, err := s.db.Exec(ctx, query, token)
+	var id int
+	err = tx.QueryRow(ctx, query, visit.Token, visit.IsBot, visit.DomainID).Scan(&id)
 	if err != nil {
-		return err
+		return s.logError(ctx, "SaveVisit", err)
+	}
+
+	query = `
+		INSERT INTO url_visits (url_entry_id, is_bot, browser_family, browser_version, os, device_class, country, region, city)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at
+	`
+
+	err = tx.QueryRow(ctx, query, id, visit.IsBot, visit.BrowserFamily, visit.BrowserVersion, visit.OS, visit.DeviceClass, visit.Country, visit.Region, visit.City).Scan(&visit.CreatedAt)
+	if err != nil {
+		return s.logError(ctx, "SaveVisit", err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return s.logError(ctx, "SaveVisit", err)
 	}
 
 	return nil
 }
 
-func (s *PGXUrlEntryRepository) GetFromUrl(ctx context.Context, url entity.Url) (*entity.UrlEntry, error) {
+// ownerScope matches the entries of the workspace in $3, or when it is 0 the personal entries of the owner in $2.
+// An owner of 0 matches the anonymous entries.
+const ownerScope = `workspace_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0) AND ($3::bigint <> 0 OR owner_id IS NOT DISTINCT FROM NULLIF($2::bigint, 0))`
+
+func (s *PGXUrlEntryRepository) GetFromUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64) (*entity.UrlEntry, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetFromUrl", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.GetFromUrl", dbSystem)
+	defer span.End()
 
 	query := `
-		SELECT token, url, visit_count
+		SELECT ` + urlEntryColumns + `
 		FROM url_entries
-		WHERE url = $1
+		WHERE url = $1 AND COALESCE(domain_id, 0) = $4 AND status = 'active' AND ` + ownerScope + `
 	`
 
-	row := s.db.QueryRow(ctx, query, url)
+	entry, err := scanUrlEntry(s.db.QueryRow(ctx, query, url, ownerID, workspaceID, domainID))
+	if err != nil {
+		return nil, s.logError(ctx, "GetFromUrl", err)
+	}
 
-	var urlEntry urlEntry
-	err := row.Scan(&urlEntry.Token, &urlEntry.Url, &urlEntry.VisitCount)
+	return entry, nil
+}
+
+func (s *PGXUrlEntryRepository) GetFromToken(ctx context.Context, domainID int64, token entity.UrlToken) (*entity.UrlEntry, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetFromToken", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.GetFromToken", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + urlEntryColumns + `
+		FROM url_entries
+		WHERE token = $1 AND COALESCE(domain_id, 0) = $2
+	`
+
+	entry, err := scanUrlEntry(s.db.QueryRow(ctx, query, token, domainID))
 	if err != nil {
-		return nil, err
+		return nil, s.logError(ctx, "GetFromToken", err)
 	}
 
-	return &entity.UrlEntry{
-		Token:      entity.UrlToken(urlEntry.Token),
-		Url:        entity.Url(url),
-		VisitCount: urlEntry.VisitCount,
-	}, nil
+	return entry, nil
 }
 
-func (s *PGXUrlEntryRepository) GetFromToken(ctx context.Context, token entity.UrlToken) (*entity.UrlEntry, error) {
+// listOrder is the order by clause of each sort, the id breaks ties so pages are stable
+var listOrder = map[url.UrlSort]string{
+	url.UrlSortCreated: "created_at DESC, id DESC",
+	url.UrlSortVisits:  "visit_count DESC, id DESC",
+}
+
+func (s *PGXUrlEntryRepository) ListByOwner(ctx context.Context, query url.ListQuery) ([]*entity.UrlEntry, int, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListByOwner", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.ListByOwner", dbSystem)
+	defer span.End()
 
+	order, ok := listOrder[query.Sort]
+	if !ok {
+		order = listOrder[url.UrlSortCreated]
+	}
+
+	// the search is matched literally, the like wildcards in it are escaped
+	search := ""
+	if query.Search != "" {
+		search = "%" + likeEscaper.Replace(query.Search) + "%"
+	}
+	where := ownerScope + ` AND status <> 'deleted' AND ($1 = '' OR url ILIKE $1 OR token ILIKE $1)`
+
+	var total int
+	err := s.db.QueryRow(ctx, `SELECT count(*) FROM url_entries WHERE `+where, search, query.OwnerID, query.WorkspaceID).Scan(&total)
+	if err != nil {
+		return nil, 0, s.logError(ctx, "ListByOwner", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+urlEntryColumns+`
+		FROM url_entries
+		WHERE `+where+`
+		ORDER BY `+order+`
+		LIMIT $4 OFFSET $5
+	`, search, query.OwnerID, query.WorkspaceID, query.Limit, query.Offset)
+	if err != nil {
+		return nil, 0, s.logError(ctx, "ListByOwner", err)
+	}
+	defer rows.Close()
+
+	var entries []*entity.UrlEntry
+	for rows.Next() {
+		entry, err := scanUrlEntry(rows)
+		if err != nil {
+			return nil, 0, s.logError(ctx, "ListByOwner", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, s.logError(ctx, "ListByOwner", err)
+	}
+
+	return entries, total, nil
+}
+
+// likeEscaper escapes the wildcards of a like pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (s *PGXUrlEntryRepository) UpdateUrl(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, revision *entity.UrlRevision) error {
+
+	defer metrics.ObserveRepositoryQuery("UpdateUrl", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.UpdateUrl", dbSystem)
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return s.logError(ctx, "UpdateUrl", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// the old url is read from the locked row so concurrent changes each record the url they replaced
 	query := `
-		SELECT token, url, visit_count
+		UPDATE url_entries
+		SET url = $4
+		FROM (SELECT id, url FROM url_entries WHERE ` + ownedEntry + ` FOR UPDATE) AS old
+		WHERE url_entries.id = old.id
+		RETURNING url_entries.id, old.url
+	`
+
+	var id int
+	var oldUrl string
+	err = tx.QueryRow(ctx, query, domainID, token, ownerID, revision.NewUrl).Scan(&id, &oldUrl)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return url.ErrNotFound
+	}
+	if err != nil {
+		return s.logError(ctx, "UpdateUrl", err)
+	}
+
+	query = `
+		INSERT INTO url_revisions (url_entry_id, old_url, new_url, user_id, api_key_id)
+		VALUES ($1, $2, $3, NULLIF($4::bigint, 0), NULLIF($5::bigint, 0))
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(ctx, query, id, oldUrl, revision.NewUrl, revision.UserID, revision.APIKeyID).Scan(&revision.ID, &revision.CreatedAt)
+	if err != nil {
+		return s.logError(ctx, "UpdateUrl", err)
+	}
+	revision.OldUrl = entity.Url(oldUrl)
+
+	if err := tx.Commit(ctx); err != nil {
+		return s.logError(ctx, "UpdateUrl", err)
+	}
+
+	return nil
+}
+
+func (s *PGXUrlEntryRepository) ListRevisions(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlRevision, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListRevisions", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.ListRevisions", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT r.id, r.old_url, r.new_url, COALESCE(r.user_id, 0), COALESCE(u.email, ''), COALESCE(r.api_key_id, 0), r.created_at
+		FROM url_revisions r
+		JOIN url_entries e ON e.id = r.url_entry_id
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE e.token = $1 AND COALESCE(e.domain_id, 0) = $2
+		ORDER BY r.created_at DESC, r.id DESC
+	`
+
+	rows, err := s.db.Query(ctx, query, token, domainID)
+	if err != nil {
+		return nil, s.logError(ctx, "ListRevisions", err)
+	}
+	defer rows.Close()
+
+	var revisions []*entity.UrlRevision
+	for rows.Next() {
+		var r entity.UrlRevision
+		var oldUrl, newUrl string
+		if err := rows.Scan(&r.ID, &oldUrl, &newUrl, &r.UserID, &r.UserEmail, &r.APIKeyID, &r.CreatedAt); err != nil {
+			return nil, s.logError(ctx, "ListRevisions", err)
+		}
+		r.OldUrl = entity.Url(oldUrl)
+		r.NewUrl = entity.Url(newUrl)
+		revisions = append(revisions, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "ListRevisions", err)
+	}
+
+	return revisions, nil
+}
+
+func (s *PGXUrlEntryRepository) SetStatus(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, status entity.UrlStatus, reason string) error {
+
+	defer metrics.ObserveRepositoryQuery("SetStatus", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.SetStatus", dbSystem)
+	defer span.End()
+
+	query := `
+		UPDATE url_entries
+		SET status = $4, takedown_reason = $5
+		WHERE ` + ownedEntry + `
+	`
+
+	return s.execOwned(ctx, "SetStatus", query, domainID, token, ownerID, string(status), reason)
+}
+
+// ownedEntry matches the entry of the token in $2 on the domain in $1 when it is owned by the owner in $3.
+// An owner of 0 matches an anonymous entry.
+const ownedEntry = `COALESCE(domain_id, 0) = $1 AND token = $2 AND owner_id IS NOT DISTINCT FROM NULLIF($3::bigint, 0)`
+
+// execOwned will run a statement against the owner's entry, url.ErrNotFound is returned when no row was changed
+func (s *PGXUrlEntryRepository) execOwned(ctx context.Context, op string, query string, args ...any) error {
+	tag, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return s.logError(ctx, op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return url.ErrNotFound
+	}
+	return nil
+}
+
+func (s *PGXUrlEntryRepository) CountVisitsByDay(ctx context.Context, domainID int64, token entity.UrlToken, since time.Time) ([]entity.VisitDay, error) {
+
+	defer metrics.ObserveRepositoryQuery("CountVisitsByDay", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.CountVisitsByDay", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT date_trunc('day', v.created_at) AS day, count(*)
+		FROM url_visits v
+		JOIN url_entries e ON e.id = v.url_entry_id
+		WHERE e.token = $1 AND COALESCE(e.domain_id, 0) = $2 AND v.created_at >= $3 AND NOT v.is_bot
+		GROUP BY day
+		ORDER BY day
+	`
+
+	rows, err := s.db.Query(ctx, query, token, domainID, since.UTC())
+	if err != nil {
+		return nil, s.logError(ctx, "CountVisitsByDay", err)
+	}
+	defer rows.Close()
+
+	var days []entity.VisitDay
+	for rows.Next() {
+		var d entity.VisitDay
+		if err := rows.Scan(&d.Day, &d.Count); err != nil {
+			return nil, s.logError(ctx, "CountVisitsByDay", err)
+		}
+		days = append(days, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "CountVisitsByDay", err)
+	}
+
+	return days, nil
+}
+
+func (s *PGXUrlEntryRepository) SaveReport(ctx context.Context, domainID int64, token entity.UrlToken, report *entity.UrlReport) (int, error) {
+
+	defer metrics.ObserveRepositoryQuery("SaveReport", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.SaveReport", dbSystem)
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, s.logError(ctx, "SaveReport", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, `SELECT id FROM url_entries WHERE token = $1 AND COALESCE(domain_id, 0) = $2`, token, domainID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, url.ErrNotFound
+	}
+	if err != nil {
+		return 0, s.logError(ctx, "SaveReport", err)
+	}
+
+	// a reporter with an open report keeps it, the conflict inserts nothing
+	query := `
+		INSERT INTO url_reports (url_entry_id, category, description, reporter_hash, user_id)
+		VALUES ($1, $2, $3, $4, NULLIF($5::bigint, 0))
+		ON CONFLICT (url_entry_id, reporter_hash) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING id, created_at
+	`
+
+	err = tx.QueryRow(ctx, query, id, string(report.Category), report.Description, report.ReporterHash, report.UserID).Scan(&report.ID, &report.CreatedAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, s.logError(ctx, "SaveReport", err)
+	}
+
+	var open int
+	err = tx.QueryRow(ctx, `SELECT count(*) FROM url_reports WHERE url_entry_id = $1 AND resolved_at IS NULL`, id).Scan(&open)
+	if err != nil {
+		return 0, s.logError(ctx, "SaveReport", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, s.logError(ctx, "SaveReport", err)
+	}
+
+	return open, nil
+}
+
+func (s *PGXUrlEntryRepository) ListReports(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlReport, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListReports", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.ListReports", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT r.id, r.category, r.description, r.reporter_hash, COALESCE(r.user_id, 0), r.created_at
+		FROM url_reports r
+		JOIN url_entries e ON e.id = r.url_entry_id
+		WHERE e.token = $1 AND COALESCE(e.domain_id, 0) = $2 AND r.resolved_at IS NULL
+		ORDER BY r.created_at DESC, r.id DESC
+	`
+
+	rows, err := s.db.Query(ctx, query, token, domainID)
+	if err != nil {
+		return nil, s.logError(ctx, "ListReports", err)
+	}
+	defer rows.Close()
+
+	var reports []*entity.UrlReport
+	for rows.Next() {
+		var r entity.UrlReport
+		var category string
+		if err := rows.Scan(&r.ID, &category, &r.Description, &r.ReporterHash, &r.UserID, &r.CreatedAt); err != nil {
+			return nil, s.logError(ctx, "ListReports", err)
+		}
+		r.Category = entity.ReportCategory(category)
+		reports = append(reports, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "ListReports", err)
+	}
+
+	return reports, nil
+}
+
+// reportedRow scans the columns after the urlEntryColumns of a reported entry along with the entry
+type reportedRow struct {
+	pgx.Row
+	extra []any
+}
+
+// Scan will scan the url entry into dest and the columns after it into the extra destinations
+func (r reportedRow) Scan(dest ...any) error {
+	return r.Row.Scan(append(dest, r.extra...)...)
+}
+
+func (s *PGXUrlEntryRepository) ListReported(ctx context.Context, limit int, offset int) ([]*entity.ReportedUrl, int, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListReported", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.ListReported", dbSystem)
+	defer span.End()
+
+	// held entries stay listed after their reports are resolved, until an admin restores or takes them down
+	reported := `
 		FROM url_entries
-		WHERE token = $1
+		LEFT JOIN (
+			SELECT url_entry_id, count(*) AS open_reports, max(created_at) AS last_reported_at
+			FROM url_reports
+			WHERE resolved_at IS NULL
+			GROUP BY url_entry_id
+		) AS reported ON reported.url_entry_id = url_entries.id
+		WHERE (reported.url_entry_id IS NOT NULL AND status IN ('active', 'disabled')) OR status = 'held'
 	`
-	row := s.db.QueryRow(ctx, query, token)
 
-	var urlEntry urlEntry
-	err := row.Scan(&urlEntry.Token, &urlEntry.Url, &urlEntry.VisitCount)
+	var total int
+	if err := s.db.QueryRow(ctx, `SELECT count(*) `+reported).Scan(&total); err != nil {
+		return nil, 0, s.logError(ctx, "ListReported", err)
+	}
+
+	rows, err := s.db.Query(ctx, `
+		SELECT `+urlEntryColumns+`, COALESCE((SELECT email FROM users WHERE users.id = url_entries.owner_id), ''), COALESCE(reported.open_reports, 0)
+		`+reported+`
+		ORDER BY COALESCE(reported.open_reports, 0) DESC, reported.last_reported_at DESC NULLS LAST, url_entries.id DESC
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
 	if err != nil {
+		return nil, 0, s.logError(ctx, "ListReported", err)
+	}
+	defer rows.Close()
+
+	var entries []*entity.ReportedUrl
+	for rows.Next() {
+		var r entity.ReportedUrl
+		entry, err := scanUrlEntry(reportedRow{rows, []any{&r.CreatorEmail, &r.OpenReports}})
+		if err != nil {
+			return nil, 0, s.logError(ctx, "ListReported", err)
+		}
+		r.Entry = entry
+		entries = append(entries, &r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, s.logError(ctx, "ListReported", err)
+	}
+
+	return entries, total, nil
+}
+
+func (s *PGXUrlEntryRepository) ResolveReports(ctx context.Context, domainID int64, token entity.UrlToken) error {
+
+	defer metrics.ObserveRepositoryQuery("ResolveReports", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.ResolveReports", dbSystem)
+	defer span.End()
+
+	query := `
+		UPDATE url_reports
+		SET resolved_at = CURRENT_TIMESTAMP
+		FROM url_entries e
+		WHERE e.id = url_reports.url_entry_id AND e.token = $1 AND COALESCE(e.domain_id, 0) = $2 AND url_reports.resolved_at IS NULL
+	`
+
+	if _, err := s.db.Exec(ctx, query, token, domainID); err != nil {
+		return s.logError(ctx, "ResolveReports", err)
+	}
+
+	return nil
+}
+
+// domainColumns are the columns scanned by scanDomain
+const domainColumns = "id, host, COALESCE(fallback_url, ''), created_at"
+
+// scanDomain will scan a row of the domainColumns into a domain
+func scanDomain(row pgx.Row) (*entity.Domain, error) {
+	var d entity.Domain
+	var fallbackUrl string
+	if err := row.Scan(&d.ID, &d.Host, &fallbackUrl, &d.CreatedAt); err != nil {
 		return nil, err
 	}
+	d.FallbackUrl = entity.Url(fallbackUrl)
+	return &d, nil
+}
 
-	return &entity.UrlEntry{
-		Token:      entity.UrlToken(token),
-		Url:        entity.Url(urlEntry.Url),
-		VisitCount: urlEntry.VisitCount,
-	}, nil
+func (s *PGXUrlEntryRepository) SaveDomain(ctx context.Context, host string, fallbackUrl entity.Url) (*entity.Domain, error) {
+
+	defer metrics.ObserveRepositoryQuery("SaveDomain", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.SaveDomain", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO domains (host, fallback_url)
+		VALUES ($1, NULLIF($2, ''))
+		ON CONFLICT (host) DO UPDATE SET fallback_url = EXCLUDED.fallback_url, updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + domainColumns + `
+	`
+
+	d, err := scanDomain(s.db.QueryRow(ctx, query, host, fallbackUrl))
+	if err != nil {
+		return nil, s.logError(ctx, "SaveDomain", err)
+	}
+
+	return d, nil
+}
+
+func (s *PGXUrlEntryRepository) GetDomainByHost(ctx context.Context, host string) (*entity.Domain, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetDomainByHost", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUrlEntryRepository.GetDomainByHost", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + domainColumns + `
+		FROM domains
+		WHERE host = $1
+	`
+
+	d, err := scanDomain(s.db.QueryRow(ctx, query, host))
+	if err != nil {
+		return nil, s.logError(ctx, "GetDomainByHost", err)
+	}
+
+	return d, nil
 }
@@ -3,32 +3,99 @@ package url
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/tracing"
 	"github.com/griggsjared/getsit/internal/url/entity"
 )
 
-// ErrValidation is a generic validation error that can be returned when input validation fails
-var ErrValidation = errors.New("validation error")
+var (
+	// ErrValidation is a generic validation error that can be returned when input validation fails
+	ErrValidation = errors.New("validation error")
+	// ErrNotFound is returned when the url entry does not exist or is not owned by the user
+	ErrNotFound = errors.New("url entry not found")
+	// ErrForbidden is returned when the role in the workspace does not allow the change
+	ErrForbidden = errors.New("not allowed by the role in the workspace")
+	// ErrDeleted is returned when the url entry existed but its owner deleted it
+	ErrDeleted = errors.New("url entry deleted")
+)
+
+// UrlSort is the order url entries are listed in
+type UrlSort string
+
+const (
+	UrlSortCreated UrlSort = "created" // Newest first
+	UrlSortVisits  UrlSort = "visits"  // Most visited first
+)
+
+// ListQuery is a page of the url entries of an owner or a workspace
+type ListQuery struct {
+	OwnerID     int64  // The personal entries of the user, ignored when the workspace is set
+	WorkspaceID int64  // The entries of the workspace
+	Search      string // Matches part of the url or token, case insensitive, empty matches everything
+	Sort        UrlSort
+	Limit       int
+	Offset      int
+}
 
 // withValidationErrors is a struct that can be embedded into the various input structs to hold validation errors
 type withValidationErrors struct {
 	ValidationErrors map[string]string
 }
 
-// UrlEntryRepository is the interface that defines the method that the service will use to interact with the repository
+// UrlEntryRepository is the interface that defines the method that the service will use to interact with the repository.
+// Tokens are unique per domain, a domain id of 0 is the base url.
 type UrlEntryRepository interface {
-	// Save will url entry to the store
-	SaveUrl(ctx context.Context, url entity.Url) (entry *entity.UrlEntry, err error)
-	// SaveVisit will increment the number of times the url has been visited
-	SaveVisit(ctx context.Context, token entity.UrlToken) error
-	// GetFromToken will get the url entry from the token
-	GetFromToken(ctx context.Context, token entity.UrlToken) (*entity.UrlEntry, error)
-	// GetFromUrl will get the url entry from the url
-	GetFromUrl(ctx context.Context, url entity.Url) (*entity.UrlEntry, error)
+	// Save will url entry to the store, an owner id of 0 saves an anonymous entry.
+	// A workspace id saves the entry to the workspace, the owner id is kept as its creator.
+	SaveUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64, manageTokenHash string) (entry *entity.UrlEntry, err error)
+	// SaveVisit will record the visit and increment the number of times the url has been visited
+	SaveVisit(ctx context.Context, visit *entity.UrlVisit) error
+	// GetFromToken will get the url entry from the token on the domain
+	GetFromToken(ctx context.Context, domainID int64, token entity.UrlToken) (*entity.UrlEntry, error)
+	// GetFromUrl will get the active url entry of the owner on the domain from the url, an owner id of 0 gets the anonymous entry.
+	// A workspace id gets the entry of the workspace whoever created it. Only the active entries hold their url.
+	GetFromUrl(ctx context.Context, url entity.Url, domainID int64, ownerID int64, workspaceID int64) (*entity.UrlEntry, error)
+	// ListByOwner will get a page of the url entries of the owner or workspace and the total number of matching entries.
+	// Deleted entries are not listed.
+	ListByOwner(ctx context.Context, query ListQuery) (entries []*entity.UrlEntry, total int, err error)
+	// UpdateUrl will change the long url of the owner's entry to the new url of the revision and record the revision,
+	// its id, old url and time are set. ErrNotFound is returned when the owner has no such entry.
+	// An owner id of 0 changes an anonymous entry.
+	UpdateUrl(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, revision *entity.UrlRevision) error
+	// ListRevisions will get the revisions of the entry of the token on the domain, newest first
+	ListRevisions(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlRevision, error)
+	// SetStatus will change the status of the owner's entry and replace its takedown reason, ErrNotFound is returned
+	// when the owner has no such entry. An owner id of 0 changes an anonymous entry.
+	SetStatus(ctx context.Context, domainID int64, token entity.UrlToken, ownerID int64, status entity.UrlStatus, reason string) error
+	// CountVisitsByDay will get the number of human visits of the entry of the token on the domain for each day since the time,
+	// days without visits are left out
+	CountVisitsByDay(ctx context.Context, domainID int64, token entity.UrlToken, since time.Time) ([]entity.VisitDay, error)
+	// SaveReport will add an open report to the entry of the token on the domain, its id and time are set, and return the
+	// number of open reports of the entry. A reporter that already has an open report for the entry is not saved again,
+	// the id stays 0. ErrNotFound is returned when there is no such entry.
+	SaveReport(ctx context.Context, domainID int64, token entity.UrlToken, report *entity.UrlReport) (openReports int, err error)
+	// ListReports will get the open reports of the entry of the token on the domain, newest first
+	ListReports(ctx context.Context, domainID int64, token entity.UrlToken) ([]*entity.UrlReport, error)
+	// ListReported will get a page of the held entries and the active and disabled entries with open reports, the most
+	// reported first, and the total number of them. The reports and visits of the entries are not set.
+	ListReported(ctx context.Context, limit int, offset int) (entries []*entity.ReportedUrl, total int, err error)
+	// ResolveReports will close the open reports of the entry of the token on the domain
+	ResolveReports(ctx context.Context, domainID int64, token entity.UrlToken) error
+	// SaveDomain will add the short domain, or change the fallback url of the domain with the same host
+	SaveDomain(ctx context.Context, host string, fallbackUrl entity.Url) (*entity.Domain, error)
+	// GetDomainByHost will get the short domain from its host
+	GetDomainByHost(ctx context.Context, host string) (*entity.Domain, error)
 }
 
 type Service struct {
-	repo UrlEntryRepository
+	repo            UrlEntryRepository
+	reportThreshold int
+	reporterKey     []byte
 }
 
 // New will create a new service
@@ -38,17 +105,45 @@ func NewService(repo UrlEntryRepository) *Service {
 	}
 }
 
+// WithReportThreshold will set the number of open reports from different reporters that puts a link on hold until an admin
+// reviews it, 0 never holds a link
+func (s *Service) WithReportThreshold(threshold int) *Service {
+	s.reportThreshold = threshold
+	return s
+}
+
+// WithReporterKey will set the server secret the reporters of the reports are hashed with, reports can not be saved without it
+func (s *Service) WithReporterKey(key []byte) *Service {
+	s.reporterKey = key
+	return s
+}
+
 // SaveUrlInput is the input struct for the SaveUrl method
 type SaveUrlInput struct {
 	withValidationErrors
-	Url string
+	Url    string
+	Domain string // The host of the short domain the token is on, empty for the base url
+
+	// ManageToken is set by SaveUrl to the secret that allows managing the new entry without owning it.
+	// Only its hash is stored so this is the only time it is available. It is empty for a workspace entry,
+	// those are only managed through the roles of the workspace.
+	ManageToken string
 }
 
-// SaveUrl will validate the url string and save it to the store
+// SaveUrl will validate the url string and save it to the store with a new token on the domain.
+// The entry belongs to the workspace the request acts in, otherwise to the logged in user, otherwise to nobody.
 func (s *Service) SaveUrl(ctx context.Context, input *SaveUrlInput) (*entity.UrlEntry, error) {
 
+	ctx, span := tracing.Start(ctx, "url.Service.SaveUrl")
+	defer span.End()
+
 	input.ValidationErrors = make(map[string]string)
 
+	p := authz.FromContext(ctx)
+	if p.WorkspaceID != 0 && !p.Role.CanEdit() {
+		return nil, ErrForbidden
+	}
+
 	// Validate the url
 	urlEntry := entity.Url(input.Url)
 	if err := urlEntry.Validate(); err != nil {
@@ -56,11 +151,25 @@ func (s *Service) SaveUrl(ctx context.Context, input *SaveUrlInput) (*entity.Url
 		return nil, ErrValidation
 	}
 
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	var manageToken, manageTokenHash string
+	if p.WorkspaceID == 0 {
+		manageToken, manageTokenHash, err = entity.NewManageToken()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Save the url
-	entry, err := s.repo.SaveUrl(ctx, urlEntry)
+	entry, err := s.repo.SaveUrl(ctx, urlEntry, domain.ID, p.UserID, p.WorkspaceID, manageTokenHash)
 	if err != nil {
 		return nil, err
 	}
+	input.ManageToken = manageToken
 
 	return entry, nil
 }
@@ -68,12 +177,16 @@ func (s *Service) SaveUrl(ctx context.Context, input *SaveUrlInput) (*entity.Url
 // GetUrlInput is the input struct for the GetUrl method
 type GetUrlByTokenInput struct {
 	withValidationErrors
-	Token string
+	Token  string
+	Domain string // The host of the short domain the token is on, empty for the base url
 }
 
-// GetUrl will get the url entry from the url string
+// GetUrl will get the url entry from the token on the domain, ErrDeleted is returned for a deleted entry
 func (s *Service) GetUrlByToken(ctx context.Context, input *GetUrlByTokenInput) (*entity.UrlEntry, error) {
 
+	ctx, span := tracing.Start(ctx, "url.Service.GetUrlByToken")
+	defer span.End()
+
 	input.ValidationErrors = make(map[string]string)
 
 	// Validate the token
@@ -83,11 +196,19 @@ func (s *Service) GetUrlByToken(ctx context.Context, input *GetUrlByTokenInput)
 		return nil, ErrValidation
 	}
 
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the url entry
-	entry, err := s.repo.GetFromToken(ctx, token)
+	entry, err := s.repo.GetFromToken(ctx, domain.ID, token)
 	if err != nil {
 		return nil, errors.New("failed to get url")
 	}
+	if entry.Status == entity.UrlStatusDeleted {
+		return nil, ErrDeleted
+	}
 
 	return entry, nil
 }
@@ -95,12 +216,18 @@ func (s *Service) GetUrlByToken(ctx context.Context, input *GetUrlByTokenInput)
 // GetUrlInput is the input struct for the GetUrl method
 type GetUrlByUrlInput struct {
 	withValidationErrors
-	Url string
+	Url    string
+	Domain string // The host of the short domain to look on, empty for the base url
 }
 
-// GetUrl will get the url entry from the url string
+// GetUrl will get the active url entry from the url string.
+// Only the entries the request would save to are matched, those on the domain of its workspace, its user or the anonymous entries.
+// A disabled or taken down entry is never returned, so a new request for its url gets a new token.
 func (s *Service) GetUrlByUrl(ctx context.Context, input *GetUrlByUrlInput) (*entity.UrlEntry, error) {
 
+	ctx, span := tracing.Start(ctx, "url.Service.GetUrlByUrl")
+	defer span.End()
+
 	input.ValidationErrors = make(map[string]string)
 
 	// Validate the url
@@ -110,8 +237,14 @@ func (s *Service) GetUrlByUrl(ctx context.Context, input *GetUrlByUrlInput) (*en
 		return nil, ErrValidation
 	}
 
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the url entry
-	entry, err := s.repo.GetFromUrl(ctx, urlEntry)
+	p := authz.FromContext(ctx)
+	entry, err := s.repo.GetFromUrl(ctx, urlEntry, domain.ID, p.UserID, p.WorkspaceID)
 	if err != nil {
 		return nil, errors.New("failed to get url")
 	}
@@ -122,12 +255,24 @@ func (s *Service) GetUrlByUrl(ctx context.Context, input *GetUrlByUrlInput) (*en
 // VisitUrlInput is the input struct for the VisitUrl method
 type VisitUrlByTokenInput struct {
 	withValidationErrors
-	Token string
+	Token          string
+	Domain         string // The host of the short domain the token is on, empty for the base url
+	IsBot          bool
+	BrowserFamily  string
+	BrowserVersion string
+	OS             string
+	DeviceClass    string
+	Country        string
+	Region         string
+	City           string
 }
 
 // VisitUrl will increment the number of times the url has been visited
 func (s *Service) VisitUrlByToken(ctx context.Context, input *VisitUrlByTokenInput) error {
 
+	ctx, span := tracing.Start(ctx, "url.Service.VisitUrlByToken")
+	defer span.End()
+
 	input.ValidationErrors = make(map[string]string)
 
 	// Validate the token
@@ -137,11 +282,800 @@ func (s *Service) VisitUrlByToken(ctx context.Context, input *VisitUrlByTokenInp
 		return ErrValidation
 	}
 
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+
 	// Save the visit
-	err := s.repo.SaveVisit(ctx, urlToken)
+	err = s.repo.SaveVisit(ctx, &entity.UrlVisit{
+		Token:          urlToken,
+		DomainID:       domain.ID,
+		IsBot:          input.IsBot,
+		BrowserFamily:  input.BrowserFamily,
+		BrowserVersion: input.BrowserVersion,
+		OS:             input.OS,
+		DeviceClass:    input.DeviceClass,
+		Country:        input.Country,
+		Region:         input.Region,
+		City:           input.City,
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// defaultPerPage and maxPerPage are the page sizes of ListUrls
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// ListUrlsInput is the input struct for the ListUrls method
+type ListUrlsInput struct {
+	withValidationErrors
+	Search  string
+	Sort    string // One of the UrlSort values, anything else sorts by created
+	Page    int    // The 1 based page number
+	PerPage int    // Defaults to 20, at most 100
+}
+
+// UrlEntryPage is a page of url entries
+type UrlEntryPage struct {
+	Entries []*entity.UrlEntry
+	Total   int // The number of matching entries across all of the pages
+	Page    int
+	PerPage int
+	Sort    UrlSort
+}
+
+// Pages will return the number of pages, there is always at least one
+func (p *UrlEntryPage) Pages() int {
+	if p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// ListUrls will get a page of the url entries of the workspace the request acts in, otherwise of the logged in user
+func (s *Service) ListUrls(ctx context.Context, input *ListUrlsInput) (*UrlEntryPage, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.ListUrls")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	p := authz.FromContext(ctx)
+	if p.WorkspaceID != 0 && !p.Role.CanView() {
+		return nil, ErrForbidden
+	}
+	if p.WorkspaceID == 0 && p.UserID == 0 {
+		input.ValidationErrors["owner"] = "only the links of a user or workspace can be listed"
+		return nil, ErrValidation
+	}
+
+	sort := UrlSort(input.Sort)
+	if sort != UrlSortVisits {
+		sort = UrlSortCreated
+	}
+	page := max(input.Page, 1)
+	perPage := input.PerPage
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+
+	entries, total, err := s.repo.ListByOwner(ctx, ListQuery{
+		OwnerID:     p.UserID,
+		WorkspaceID: p.WorkspaceID,
+		Search:      strings.TrimSpace(input.Search),
+		Sort:        sort,
+		Limit:       perPage,
+		Offset:      (page - 1) * perPage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UrlEntryPage{
+		Entries: entries,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+		Sort:    sort,
+	}, nil
+}
+
+// UpdateUrlInput is the input struct for the UpdateUrl method
+type UpdateUrlInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	ManageToken string // Allows changing the entry without owning it
+	Url         string
+}
+
+// UpdateUrl will change where the token redirects to, the token stays the same.
+// Every change is recorded as a revision with the user or api key that made it.
+// The request has to be allowed to manage the entry, see CanManage, and a taken down entry can not be changed.
+func (s *Service) UpdateUrl(ctx context.Context, input *UpdateUrlInput) (*entity.UrlEntry, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.UpdateUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return nil, ErrValidation
+	}
+	newUrl := entity.Url(input.Url)
+	if err := newUrl.Validate(); err != nil {
+		input.ValidationErrors["url"] = err.Error()
+		return nil, ErrValidation
+	}
+
+	entry, err := s.getManaged(ctx, input.Domain, token, input.ManageToken, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+	if entry.TakenDown() {
+		input.ValidationErrors["status"] = "the link was taken down and can not be changed"
+		return nil, ErrValidation
+	}
+	if entry.Url == newUrl {
+		return entry, nil
+	}
+
+	if other, err := s.repo.GetFromUrl(ctx, newUrl, entry.DomainID, entry.OwnerID, entry.WorkspaceID); err == nil && other.Token != token {
+		switch {
+		case entry.WorkspaceID != 0:
+			input.ValidationErrors["url"] = "the workspace already has a link for this url"
+		case entry.OwnerID == 0:
+			input.ValidationErrors["url"] = "there is already a link for this url"
+		default:
+			input.ValidationErrors["url"] = "you already have a link for this url"
+		}
+		return nil, ErrValidation
+	}
+
+	p := authz.FromContext(ctx)
+	revision := &entity.UrlRevision{
+		NewUrl:   newUrl,
+		UserID:   p.UserID,
+		APIKeyID: p.APIKeyID,
+	}
+	if err := s.repo.UpdateUrl(ctx, entry.DomainID, token, entry.OwnerID, revision); err != nil {
+		return nil, err
+	}
+
+	entry.Url = newUrl
+	return entry, nil
+}
+
+// ListUrlRevisionsInput is the input struct for the ListUrlRevisions method
+type ListUrlRevisionsInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	ManageToken string // Allows seeing the revisions without owning the entry
+}
+
+// ListUrlRevisions will return every change of where the token redirects to, newest first.
+// The request has to be allowed to manage the entry, or be allowed to view the entries of its workspace.
+// Who made a change is only shown to that user and to the workspace of the entry, a manage token does not reveal other users.
+func (s *Service) ListUrlRevisions(ctx context.Context, input *ListUrlRevisionsInput) ([]*entity.UrlRevision, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.ListUrlRevisions")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return nil, ErrValidation
+	}
+
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.repo.GetFromToken(ctx, domain.ID, token)
+	if err != nil || entry.Status == entity.UrlStatusDeleted {
+		return nil, ErrNotFound
+	}
+
+	// the destinations a link had are not public, unlike where it goes now
+	if !s.CanManage(ctx, entry, input.ManageToken) && (entry.WorkspaceID == 0 || !s.CanView(ctx, entry)) {
+		return nil, ErrNotFound
+	}
+
+	revisions, err := s.repo.ListRevisions(ctx, entry.DomainID, token)
+	if err != nil {
+		return nil, err
+	}
+
+	p := authz.FromContext(ctx)
+	for i, revision := range revisions {
+		if revision.UserID == 0 || revision.UserID == p.UserID || (entry.WorkspaceID != 0 && p.InWorkspace(entry.WorkspaceID)) {
+			continue
+		}
+		hidden := *revision
+		hidden.UserID = 0
+		hidden.UserEmail = ""
+		hidden.UserHidden = true
+		revisions[i] = &hidden
+	}
+	return revisions, nil
+}
+
+// RevertUrlInput is the input struct for the RevertUrl method
+type RevertUrlInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	ManageToken string // Allows changing the entry without owning it
+	RevisionID  int64
+}
+
+// RevertUrl will change where the token redirects back to the url it had before the revision.
+// The revert is recorded as a new revision, the request has to be allowed to manage the entry, see CanManage.
+func (s *Service) RevertUrl(ctx context.Context, input *RevertUrlInput) (*entity.UrlEntry, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.RevertUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return nil, ErrValidation
+	}
+
+	entry, err := s.getManaged(ctx, input.Domain, token, input.ManageToken, input.ValidationErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.repo.ListRevisions(ctx, entry.DomainID, token)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range revisions {
+		if r.ID != input.RevisionID {
+			continue
+		}
+		update := &UpdateUrlInput{
+			Token:       input.Token,
+			Domain:      input.Domain,
+			ManageToken: input.ManageToken,
+			Url:         r.OldUrl.String(),
+		}
+		entry, err := s.UpdateUrl(ctx, update)
+		input.ValidationErrors = update.ValidationErrors
+		return entry, err
+	}
+
+	input.ValidationErrors["revision"] = "the link has no such revision"
+	return nil, ErrValidation
+}
+
+// statusTransitions are the statuses each status can change to, a deleted entry stays deleted.
+// Only an admin can hold or take down an entry, and only an admin can change a held entry to anything but deleted, see setStatus.
+var statusTransitions = map[entity.UrlStatus][]entity.UrlStatus{
+	entity.UrlStatusActive:    {entity.UrlStatusDisabled, entity.UrlStatusHeld, entity.UrlStatusDeleted, entity.UrlStatusTakenDown},
+	entity.UrlStatusDisabled:  {entity.UrlStatusActive, entity.UrlStatusHeld, entity.UrlStatusDeleted, entity.UrlStatusTakenDown},
+	entity.UrlStatusHeld:      {entity.UrlStatusActive, entity.UrlStatusDeleted, entity.UrlStatusTakenDown},
+	entity.UrlStatusTakenDown: {entity.UrlStatusActive},
+}
+
+// SetUrlStatusInput is the input struct for the SetUrlStatus method
+type SetUrlStatusInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	ManageToken string // Allows changing the entry without owning it
+	Status      entity.UrlStatus
+}
+
+// SetUrlStatus will enable or disable the token.
+// The request has to be allowed to manage the entry, see CanManage.
+func (s *Service) SetUrlStatus(ctx context.Context, input *SetUrlStatusInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.SetUrlStatus")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+	if input.Status != entity.UrlStatusActive && input.Status != entity.UrlStatusDisabled {
+		input.ValidationErrors["status"] = fmt.Sprintf("status must be one of %s or %s", entity.UrlStatusActive, entity.UrlStatusDisabled)
+		return ErrValidation
+	}
+
+	entry, err := s.getManaged(ctx, input.Domain, token, input.ManageToken, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+
+	return s.setStatus(ctx, entry, input.Status, "", input.ValidationErrors)
+}
+
+// DeleteUrlInput is the input struct for the DeleteUrl method
+type DeleteUrlInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	ManageToken string // Allows deleting the entry without owning it
+}
+
+// DeleteUrl will stop the token for good, the entry and its visits are kept so the token is never given out again.
+// The request has to be allowed to manage the entry, see CanManage.
+func (s *Service) DeleteUrl(ctx context.Context, input *DeleteUrlInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.DeleteUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+
+	entry, err := s.getManaged(ctx, input.Domain, token, input.ManageToken, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+
+	return s.setStatus(ctx, entry, entity.UrlStatusDeleted, "", input.ValidationErrors)
+}
+
+// TakeDownUrlInput is the input struct for the TakeDownUrl method
+type TakeDownUrlInput struct {
+	withValidationErrors
+	Token  string
+	Domain string // The host of the short domain the token is on, empty for the base url
+	Reason string // Why the link was taken down, shown to its visitors
+}
+
+// TakeDownUrl will stop the token for a legal reason, its managers can not bring it back or change it.
+// Only an admin can take a link down.
+func (s *Service) TakeDownUrl(ctx context.Context, input *TakeDownUrlInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.TakeDownUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if !authz.FromContext(ctx).Admin {
+		return ErrForbidden
+	}
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		input.ValidationErrors["reason"] = "reason is required"
+		return ErrValidation
+	}
+
+	entry, err := s.getAdministered(ctx, input.Domain, token, input.ValidationErrors)
 	if err != nil {
 		return err
 	}
 
+	return s.setStatus(ctx, entry, entity.UrlStatusTakenDown, reason, input.ValidationErrors)
+}
+
+// RestoreUrlInput is the input struct for the RestoreUrl method
+type RestoreUrlInput struct {
+	withValidationErrors
+	Token  string
+	Domain string // The host of the short domain the token is on, empty for the base url
+}
+
+// RestoreUrl will make a taken down or held token redirect again and close its open reports, so it is not held again by them.
+// Only an admin can restore a link.
+func (s *Service) RestoreUrl(ctx context.Context, input *RestoreUrlInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.RestoreUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if !authz.FromContext(ctx).Admin {
+		return ErrForbidden
+	}
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+
+	entry, err := s.getAdministered(ctx, input.Domain, token, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+	if !entry.TakenDown() && !entry.Held() {
+		input.ValidationErrors["status"] = "the link was not taken down or held"
+		return ErrValidation
+	}
+	if err := s.setStatus(ctx, entry, entity.UrlStatusActive, "", input.ValidationErrors); err != nil {
+		return err
+	}
+
+	return s.repo.ResolveReports(ctx, entry.DomainID, token)
+}
+
+// setStatus will change the status of the entry when the transition is allowed, see statusTransitions.
+// An entry can only become active again while its owner has no other active entry for the url.
+func (s *Service) setStatus(ctx context.Context, entry *entity.UrlEntry, status entity.UrlStatus, reason string, validationErrors map[string]string) error {
+	current := entry.Status
+	if current == "" {
+		current = entity.UrlStatusActive
+	}
+	if current == status && status != entity.UrlStatusTakenDown {
+		return nil
+	}
+
+	admin := authz.FromContext(ctx).Admin
+	if current == entity.UrlStatusTakenDown && !admin {
+		validationErrors["status"] = "the link was taken down and can not be changed"
+		return ErrValidation
+	}
+	if current == entity.UrlStatusHeld && status != entity.UrlStatusDeleted && !admin {
+		validationErrors["status"] = "the link is on hold until an admin reviews it"
+		return ErrValidation
+	}
+	if (status == entity.UrlStatusTakenDown || status == entity.UrlStatusHeld) && !admin {
+		return ErrForbidden
+	}
+	if current != status && !slices.Contains(statusTransitions[current], status) {
+		validationErrors["status"] = fmt.Sprintf("a %s link can not be %s", current, status)
+		return ErrValidation
+	}
+
+	if status == entity.UrlStatusActive {
+		if other, err := s.repo.GetFromUrl(ctx, entry.Url, entry.DomainID, entry.OwnerID, entry.WorkspaceID); err == nil && other.Token != entry.Token {
+			validationErrors["status"] = "there is already an active link for this url"
+			return ErrValidation
+		}
+	}
+
+	if err := s.repo.SetStatus(ctx, entry.DomainID, entry.Token, entry.OwnerID, status, reason); err != nil {
+		return err
+	}
+
+	entry.Status = status
+	entry.TakedownReason = reason
 	return nil
 }
+
+// ReportUrlInput is the input struct for the ReportUrl method
+type ReportUrlInput struct {
+	withValidationErrors
+	Token       string
+	Domain      string // The host of the short domain the token is on, empty for the base url
+	Category    string // One of the entity.ReportCategories
+	Description string // Optional
+	Reporter    string // Who made an anonymous report, like the network of the client, only its hash is stored
+}
+
+// ReportUrl will record a report of abuse of an active link for the admins to review.
+// Logged in users are told apart by their id, everyone else by the reporter, each has one open report per link.
+// Once the link has reports from as many reporters as the report threshold it is held until an admin reviews it.
+func (s *Service) ReportUrl(ctx context.Context, input *ReportUrlInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.ReportUrl")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+	category := entity.ReportCategory(input.Category)
+	if err := category.Validate(); err != nil {
+		input.ValidationErrors["category"] = err.Error()
+		return ErrValidation
+	}
+	description := strings.TrimSpace(input.Description)
+	if len(description) > entity.MaxReportDescriptionLength {
+		input.ValidationErrors["description"] = fmt.Sprintf("description must be at most %d characters", entity.MaxReportDescriptionLength)
+		return ErrValidation
+	}
+
+	p := authz.FromContext(ctx)
+	reporter := input.Reporter
+	if p.UserID != 0 {
+		reporter = fmt.Sprintf("user:%d", p.UserID)
+	}
+	if reporter == "" {
+		input.ValidationErrors["reporter"] = "reporter is required"
+		return ErrValidation
+	}
+	if len(s.reporterKey) == 0 {
+		return errors.New("no reporter key to hash the reporter with")
+	}
+
+	domain, err := s.inputDomain(ctx, input.Domain, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+	entry, err := s.repo.GetFromToken(ctx, domain.ID, token)
+	if err != nil || !entry.Active() {
+		return ErrNotFound
+	}
+
+	open, err := s.repo.SaveReport(ctx, entry.DomainID, token, &entity.UrlReport{
+		Category:     category,
+		Description:  description,
+		ReporterHash: entity.HashReporter(s.reporterKey, reporter),
+		UserID:       p.UserID,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the reporter can not manage the link, so the status is changed without the checks of setStatus
+	if s.reportThreshold > 0 && open >= s.reportThreshold {
+		return s.repo.SetStatus(ctx, entry.DomainID, token, entry.OwnerID, entity.UrlStatusHeld, "")
+	}
+
+	return nil
+}
+
+// reportVisitDays is the number of days of visits shown with a reported link
+const reportVisitDays = 7
+
+// ListReportedUrlsInput is the input struct for the ListReportedUrls method
+type ListReportedUrlsInput struct {
+	withValidationErrors
+	Page    int // The 1 based page number
+	PerPage int // Defaults to 20, at most 100
+}
+
+// ReportedUrlPage is a page of the moderation queue
+type ReportedUrlPage struct {
+	Entries []*entity.ReportedUrl
+	Total   int // The number of reported entries across all of the pages
+	Page    int
+	PerPage int
+}
+
+// Pages will return the number of pages, there is always at least one
+func (p *ReportedUrlPage) Pages() int {
+	if p.Total == 0 {
+		return 1
+	}
+	return (p.Total + p.PerPage - 1) / p.PerPage
+}
+
+// ListReportedUrls will get a page of the links with open reports or on hold, the most reported first, with their reports
+// and the human visits of each of the last days. Only an admin can see the reported links.
+func (s *Service) ListReportedUrls(ctx context.Context, input *ListReportedUrlsInput) (*ReportedUrlPage, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.ListReportedUrls")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if !authz.FromContext(ctx).Admin {
+		return nil, ErrForbidden
+	}
+
+	page := max(input.Page, 1)
+	perPage := input.PerPage
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	perPage = min(perPage, maxPerPage)
+
+	entries, total, err := s.repo.ListReported(ctx, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-reportVisitDays)
+	for _, r := range entries {
+		if r.Reports, err = s.repo.ListReports(ctx, r.Entry.DomainID, r.Entry.Token); err != nil {
+			return nil, err
+		}
+		counts, err := s.repo.CountVisitsByDay(ctx, r.Entry.DomainID, r.Entry.Token, since)
+		if err != nil {
+			return nil, err
+		}
+		r.Visits = make([]entity.VisitDay, reportVisitDays)
+		for i := range r.Visits {
+			r.Visits[i].Day = since.AddDate(0, 0, i)
+		}
+		for _, c := range counts {
+			if i := int(c.Day.UTC().Sub(since).Hours() / 24); i >= 0 && i < reportVisitDays {
+				r.Visits[i].Count += c.Count
+			}
+		}
+	}
+
+	return &ReportedUrlPage{
+		Entries: entries,
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}, nil
+}
+
+// ResolveReportsInput is the input struct for the ResolveReports method
+type ResolveReportsInput struct {
+	withValidationErrors
+	Token  string
+	Domain string           // The host of the short domain the token is on, empty for the base url
+	Status entity.UrlStatus // Active keeps the link, held keeps it off until an admin lifts the hold, taken down is for good
+	Reason string           // Why the link is taken down, required with the taken down status
+}
+
+// ResolveReports will close the open reports of a link after changing it to the status an admin decided on.
+// Only an admin can resolve reports.
+func (s *Service) ResolveReports(ctx context.Context, input *ResolveReportsInput) error {
+
+	ctx, span := tracing.Start(ctx, "url.Service.ResolveReports")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if !authz.FromContext(ctx).Admin {
+		return ErrForbidden
+	}
+
+	token := entity.UrlToken(input.Token)
+	if err := token.Validate(); err != nil {
+		input.ValidationErrors["token"] = err.Error()
+		return ErrValidation
+	}
+	reason := ""
+	switch input.Status {
+	case entity.UrlStatusActive, entity.UrlStatusHeld:
+	case entity.UrlStatusTakenDown:
+		if reason = strings.TrimSpace(input.Reason); reason == "" {
+			input.ValidationErrors["reason"] = "reason is required"
+			return ErrValidation
+		}
+	default:
+		input.ValidationErrors["status"] = fmt.Sprintf("status must be one of %s, %s or %s", entity.UrlStatusActive, entity.UrlStatusHeld, entity.UrlStatusTakenDown)
+		return ErrValidation
+	}
+
+	entry, err := s.getAdministered(ctx, input.Domain, token, input.ValidationErrors)
+	if err != nil {
+		return err
+	}
+	if err := s.setStatus(ctx, entry, input.Status, reason, input.ValidationErrors); err != nil {
+		return err
+	}
+
+	return s.repo.ResolveReports(ctx, entry.DomainID, token)
+}
+
+// CanView will return true when the request is allowed to see the information of the entry.
+// The entries of a workspace are only visible in the workspace, the other entries are public.
+func (s *Service) CanView(ctx context.Context, entry *entity.UrlEntry) bool {
+	if entry.WorkspaceID == 0 {
+		return true
+	}
+	p := authz.FromContext(ctx)
+	return p.InWorkspace(entry.WorkspaceID) && p.Role.CanView()
+}
+
+// CanManage will return true when the request is allowed to change the entry.
+// That is an editor of the entry's workspace, the user that owns a personal entry or anyone with the manage token of an entry outside a workspace.
+func (s *Service) CanManage(ctx context.Context, entry *entity.UrlEntry, manageToken string) bool {
+	p := authz.FromContext(ctx)
+	if p.InWorkspace(entry.WorkspaceID) && p.Role.CanEdit() {
+		return true
+	}
+	return entry.CanManage(p.UserID, manageToken)
+}
+
+// getManaged will get the entry of the token on the domain when the request is allowed to manage it, see CanManage.
+// A role that can only view the entry gets ErrForbidden, everyone else gets ErrNotFound.
+func (s *Service) getManaged(ctx context.Context, host string, token entity.UrlToken, manageToken string, validationErrors map[string]string) (*entity.UrlEntry, error) {
+	domain, err := s.inputDomain(ctx, host, validationErrors)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.repo.GetFromToken(ctx, domain.ID, token)
+	if err != nil || entry.Status == entity.UrlStatusDeleted {
+		return nil, ErrNotFound
+	}
+	if s.CanManage(ctx, entry, manageToken) {
+		return entry, nil
+	}
+	if authz.FromContext(ctx).InWorkspace(entry.WorkspaceID) {
+		return nil, ErrForbidden
+	}
+	return nil, ErrNotFound
+}
+
+// getAdministered will get the entry of the token on the domain for an admin, whoever manages it
+func (s *Service) getAdministered(ctx context.Context, host string, token entity.UrlToken, validationErrors map[string]string) (*entity.UrlEntry, error) {
+	domain, err := s.inputDomain(ctx, host, validationErrors)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.repo.GetFromToken(ctx, domain.ID, token)
+	if err != nil || entry.Status == entity.UrlStatusDeleted {
+		return nil, ErrNotFound
+	}
+	return entry, nil
+}
+
+// SaveDomainInput is the input struct for the SaveDomain method
+type SaveDomainInput struct {
+	withValidationErrors
+	Host        string
+	FallbackUrl string // Optional, where unknown tokens on the domain redirect to
+}
+
+// SaveDomain will add a short domain links can be created on, a domain that already exists gets the new fallback url
+func (s *Service) SaveDomain(ctx context.Context, input *SaveDomainInput) (*entity.Domain, error) {
+
+	ctx, span := tracing.Start(ctx, "url.Service.SaveDomain")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	host := entity.NormalizeHost(input.Host)
+	if err := entity.ValidateHost(host); err != nil {
+		input.ValidationErrors["host"] = err.Error()
+		return nil, ErrValidation
+	}
+	fallbackUrl := entity.Url(strings.TrimSpace(input.FallbackUrl))
+	if fallbackUrl != "" {
+		if err := fallbackUrl.Validate(); err != nil {
+			input.ValidationErrors["fallback_url"] = err.Error()
+			return nil, ErrValidation
+		}
+	}
+
+	return s.repo.SaveDomain(ctx, host, fallbackUrl)
+}
+
+// GetDomain will get the short domain from its host, ErrNotFound is returned when the host is not a short domain.
+// An empty host is the base url.
+func (s *Service) GetDomain(ctx context.Context, host string) (*entity.Domain, error) {
+	if host == "" {
+		return &entity.Domain{}, nil
+	}
+	domain, err := s.repo.GetDomainByHost(ctx, entity.NormalizeHost(host))
+	if err != nil {
+		return nil, ErrNotFound
+	}
+	return domain, nil
+}
+
+// inputDomain will get the short domain of the host sent in an input, a host that is not a short domain is a validation error
+func (s *Service) inputDomain(ctx context.Context, host string, validationErrors map[string]string) (*entity.Domain, error) {
+	domain, err := s.GetDomain(ctx, host)
+	if err != nil {
+		validationErrors["domain"] = "domain is not known"
+		return nil, ErrValidation
+	}
+	return domain, nil
+}
@@ -2,13 +2,21 @@ package url_test
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
+	"github.com/griggsjared/getsit/internal/authz"
 	"github.com/griggsjared/getsit/internal/url"
 	"github.com/griggsjared/getsit/internal/url/entity"
 	"github.com/griggsjared/getsit/internal/url/repository"
 )
 
+// asUser will return the context of a request by the user, 0 is an anonymous request
+func asUser(ctx context.Context, userID int64) context.Context {
+	return authz.WithPrincipal(ctx, authz.Principal{UserID: userID})
+}
+
 func TestService_SaveUrl(t *testing.T) {
 
 	ctx := context.Background()
@@ -229,3 +237,808 @@ func TestService_VisitUrlByToken(t *testing.T) {
 		})
 	}
 }
+
+func TestService_VisitUrlByToken_BotVisits(t *testing.T) {
+
+	ctx := context.Background()
+	r := repository.NewMemUrlEntryRepository()
+	s := url.NewService(r)
+
+	entry, err := s.SaveUrl(ctx, &url.SaveUrlInput{
+		Url: "https://example.com",
+	})
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+
+	for _, isBot := range []bool{false, true, true} {
+		err := s.VisitUrlByToken(ctx, &url.VisitUrlByTokenInput{
+			Token: entry.Token.String(),
+			IsBot: isBot,
+		})
+		if err != nil {
+			t.Fatalf("VisitUrlByToken() error = %v", err)
+		}
+	}
+
+	got, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{
+		Token: entry.Token.String(),
+	})
+	if err != nil {
+		t.Fatalf("GetUrlByToken() error = %v", err)
+	}
+
+	if got.VisitCount != 3 {
+		t.Errorf("VisitCount = %v, want %v", got.VisitCount, 3)
+	}
+	if got.BotVisitCount != 2 {
+		t.Errorf("BotVisitCount = %v, want %v", got.BotVisitCount, 2)
+	}
+	if got.HumanVisitCount() != 1 {
+		t.Errorf("HumanVisitCount() = %v, want %v", got.HumanVisitCount(), 1)
+	}
+}
+
+func TestService_SaveUrlOwner(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	tests := []struct {
+		name    string
+		ownerID int64
+		wantErr bool
+	}{
+		{name: "anonymous", ownerID: 0, wantErr: false},
+		{name: "owner", ownerID: 1, wantErr: false},
+		{name: "another owner", ownerID: 2, wantErr: false},
+		{name: "same owner again", ownerID: 1, wantErr: true},
+		{name: "anonymous again", ownerID: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := asUser(ctx, tt.ownerID)
+			entry, err := s.SaveUrl(ctx, &url.SaveUrlInput{
+				Url: "https://shared.com",
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SaveUrl() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if entry.OwnerID != tt.ownerID {
+				t.Errorf("SaveUrl() owner = %v, want %v", entry.OwnerID, tt.ownerID)
+			}
+			found, err := s.GetUrlByUrl(ctx, &url.GetUrlByUrlInput{Url: "https://shared.com"})
+			if err != nil || found.Token != entry.Token {
+				t.Errorf("GetUrlByUrl() = %v, %v, want the entry of the owner", found, err)
+			}
+		})
+	}
+}
+
+func TestService_ListUrls(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	for _, u := range []string{"https://one.com", "https://two.com", "https://three.com"} {
+		if _, err := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: u}); err != nil {
+			t.Fatalf("SaveUrl() error = %v", err)
+		}
+	}
+	if _, err := s.SaveUrl(asUser(ctx, 2), &url.SaveUrlInput{Url: "https://other.com"}); err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	two, _ := s.GetUrlByUrl(asUser(ctx, 1), &url.GetUrlByUrlInput{Url: "https://two.com"})
+	for range 2 {
+		s.VisitUrlByToken(ctx, &url.VisitUrlByTokenInput{Token: two.Token.String()})
+	}
+
+	tests := []struct {
+		name      string
+		userID    int64
+		input     url.ListUrlsInput
+		wantUrls  []string
+		wantTotal int
+		wantPages int
+		wantErr   bool
+	}{
+		{
+			name:      "newest first",
+			userID:    1,
+			input:     url.ListUrlsInput{},
+			wantUrls:  []string{"https://three.com", "https://two.com", "https://one.com"},
+			wantTotal: 3,
+			wantPages: 1,
+		},
+		{
+			name:      "most visited first",
+			userID:    1,
+			input:     url.ListUrlsInput{Sort: "visits"},
+			wantUrls:  []string{"https://two.com", "https://three.com", "https://one.com"},
+			wantTotal: 3,
+			wantPages: 1,
+		},
+		{
+			name:      "search",
+			userID:    1,
+			input:     url.ListUrlsInput{Search: " TWO.COM "}, // the dot can not be in a token so only the url matches
+			wantUrls:  []string{"https://two.com"},
+			wantTotal: 1,
+			wantPages: 1,
+		},
+		{
+			name:      "second page",
+			userID:    1,
+			input:     url.ListUrlsInput{Page: 2, PerPage: 2},
+			wantUrls:  []string{"https://one.com"},
+			wantTotal: 3,
+			wantPages: 2,
+		},
+		{
+			name:      "no links",
+			userID:    3,
+			input:     url.ListUrlsInput{},
+			wantTotal: 0,
+			wantPages: 1,
+		},
+		{
+			name:    "anonymous",
+			userID:  0,
+			input:   url.ListUrlsInput{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.ListUrls(asUser(ctx, tt.userID), &tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListUrls() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var urls []string
+			for _, e := range page.Entries {
+				urls = append(urls, e.Url.String())
+			}
+			if len(urls) != len(tt.wantUrls) {
+				t.Fatalf("ListUrls() urls = %v, want %v", urls, tt.wantUrls)
+			}
+			for i := range urls {
+				if urls[i] != tt.wantUrls[i] {
+					t.Errorf("ListUrls() urls = %v, want %v", urls, tt.wantUrls)
+					break
+				}
+			}
+			if page.Total != tt.wantTotal || page.Pages() != tt.wantPages {
+				t.Errorf("ListUrls() total = %v, pages = %v, want %v, %v", page.Total, page.Pages(), tt.wantTotal, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestService_ManageUrl(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	owned, _ := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://owned.com"})
+	s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://taken.com"})
+	anonymous, _ := s.SaveUrl(ctx, &url.SaveUrlInput{Url: "https://anonymous.com"})
+
+	updateTests := []struct {
+		name    string
+		token   string
+		ownerID int64
+		url     string
+		wantErr error
+	}{
+		{name: "invalid url", token: owned.Token.String(), ownerID: 1, url: "not a url", wantErr: url.ErrValidation},
+		{name: "another owner", token: owned.Token.String(), ownerID: 2, url: "https://new.com", wantErr: url.ErrNotFound},
+		{name: "anonymous entry", token: anonymous.Token.String(), ownerID: 0, url: "https://new.com", wantErr: url.ErrNotFound},
+		{name: "url of another link", token: owned.Token.String(), ownerID: 1, url: "https://taken.com", wantErr: url.ErrValidation},
+		{name: "owner", token: owned.Token.String(), ownerID: 1, url: "https://new.com"},
+	}
+	for _, tt := range updateTests {
+		t.Run("update "+tt.name, func(t *testing.T) {
+			_, err := s.UpdateUrl(asUser(ctx, tt.ownerID), &url.UpdateUrlInput{Token: tt.token, Url: tt.url})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUrl() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+	entry, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: owned.Token.String()})
+	if entry.Url != "https://new.com" {
+		t.Errorf("UpdateUrl() url = %v, want https://new.com", entry.Url)
+	}
+
+	if err := s.SetUrlStatus(asUser(ctx, 2), &url.SetUrlStatusInput{Token: owned.Token.String(), Status: entity.UrlStatusDisabled}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("SetUrlStatus() another owner error = %v, want %v", err, url.ErrNotFound)
+	}
+	if err := s.SetUrlStatus(asUser(ctx, 1), &url.SetUrlStatusInput{Token: owned.Token.String(), Status: "gone"}); !errors.Is(err, url.ErrValidation) {
+		t.Errorf("SetUrlStatus() invalid status error = %v, want %v", err, url.ErrValidation)
+	}
+	if err := s.SetUrlStatus(asUser(ctx, 1), &url.SetUrlStatusInput{Token: owned.Token.String(), Status: entity.UrlStatusDisabled}); err != nil {
+		t.Fatalf("SetUrlStatus() error = %v", err)
+	}
+	entry, _ = s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: owned.Token.String()})
+	if entry.Active() {
+		t.Errorf("SetUrlStatus() entry is still active")
+	}
+
+	if err := s.DeleteUrl(asUser(ctx, 1), &url.DeleteUrlInput{Token: anonymous.Token.String()}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("DeleteUrl() anonymous entry error = %v, want %v", err, url.ErrNotFound)
+	}
+	if err := s.DeleteUrl(asUser(ctx, 1), &url.DeleteUrlInput{Token: owned.Token.String()}); err != nil {
+		t.Fatalf("DeleteUrl() error = %v", err)
+	}
+	if _, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: owned.Token.String()}); !errors.Is(err, url.ErrDeleted) {
+		t.Errorf("GetUrlByToken() deleted entry error = %v, want %v", err, url.ErrDeleted)
+	}
+	if _, err := s.GetUrlByUrl(asUser(ctx, 1), &url.GetUrlByUrlInput{Url: "https://new.com"}); err == nil {
+		t.Errorf("DeleteUrl() entry can still be found by its url")
+	}
+}
+
+func TestService_ManageUrlWithManageToken(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	input := &url.SaveUrlInput{Url: "https://anonymous.com"}
+	entry, err := s.SaveUrl(ctx, input)
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	if input.ManageToken == "" || !entry.CanManage(0, input.ManageToken) {
+		t.Fatalf("SaveUrl() manage token %q does not manage the entry", input.ManageToken)
+	}
+
+	if _, err := s.UpdateUrl(ctx, &url.UpdateUrlInput{Token: entry.Token.String(), ManageToken: "wrong", Url: "https://new.com"}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("UpdateUrl() wrong manage token error = %v, want %v", err, url.ErrNotFound)
+	}
+	if _, err := s.UpdateUrl(ctx, &url.UpdateUrlInput{Token: entry.Token.String(), ManageToken: input.ManageToken, Url: "https://new.com"}); err != nil {
+		t.Errorf("UpdateUrl() error = %v", err)
+	}
+	if err := s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: entry.Token.String(), ManageToken: input.ManageToken, Status: entity.UrlStatusDisabled}); err != nil {
+		t.Errorf("SetUrlStatus() error = %v", err)
+	}
+	if err := s.DeleteUrl(asUser(ctx, 1), &url.DeleteUrlInput{Token: entry.Token.String()}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("DeleteUrl() without the manage token error = %v, want %v", err, url.ErrNotFound)
+	}
+	if err := s.DeleteUrl(ctx, &url.DeleteUrlInput{Token: entry.Token.String(), ManageToken: input.ManageToken}); err != nil {
+		t.Errorf("DeleteUrl() error = %v", err)
+	}
+	if _, err := s.GetUrlByUrl(ctx, &url.GetUrlByUrlInput{Url: "https://new.com"}); err == nil {
+		t.Errorf("DeleteUrl() entry can still be found by its url")
+	}
+}
+
+func TestService_UrlStatus(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+	owner := asUser(ctx, 1)
+	admin := authz.WithPrincipal(ctx, authz.Principal{UserID: 9, Admin: true})
+
+	entry, _ := s.SaveUrl(owner, &url.SaveUrlInput{Url: "https://status.com"})
+	token := entry.Token.String()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		change     func(ctx context.Context) error
+		wantErr    error
+		wantStatus entity.UrlStatus
+	}{
+		{name: "disable", ctx: owner, change: func(ctx context.Context) error {
+			return s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusDisabled})
+		}, wantStatus: entity.UrlStatusDisabled},
+		{name: "take down as the owner", ctx: owner, change: func(ctx context.Context) error {
+			return s.TakeDownUrl(ctx, &url.TakeDownUrlInput{Token: token, Reason: "phishing"})
+		}, wantErr: url.ErrForbidden, wantStatus: entity.UrlStatusDisabled},
+		{name: "set taken down as the owner", ctx: owner, change: func(ctx context.Context) error {
+			return s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusTakenDown})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusDisabled},
+		{name: "take down without a reason", ctx: admin, change: func(ctx context.Context) error {
+			return s.TakeDownUrl(ctx, &url.TakeDownUrlInput{Token: token, Reason: " "})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusDisabled},
+		{name: "take down", ctx: admin, change: func(ctx context.Context) error {
+			return s.TakeDownUrl(ctx, &url.TakeDownUrlInput{Token: token, Reason: "phishing"})
+		}, wantStatus: entity.UrlStatusTakenDown},
+		{name: "enable a taken down link", ctx: owner, change: func(ctx context.Context) error {
+			return s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusActive})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusTakenDown},
+		{name: "update a taken down link", ctx: owner, change: func(ctx context.Context) error {
+			_, err := s.UpdateUrl(ctx, &url.UpdateUrlInput{Token: token, Url: "https://elsewhere.com"})
+			return err
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusTakenDown},
+		{name: "delete a taken down link", ctx: owner, change: func(ctx context.Context) error {
+			return s.DeleteUrl(ctx, &url.DeleteUrlInput{Token: token})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusTakenDown},
+		{name: "restore as the owner", ctx: owner, change: func(ctx context.Context) error {
+			return s.RestoreUrl(ctx, &url.RestoreUrlInput{Token: token})
+		}, wantErr: url.ErrForbidden, wantStatus: entity.UrlStatusTakenDown},
+		{name: "restore", ctx: admin, change: func(ctx context.Context) error {
+			return s.RestoreUrl(ctx, &url.RestoreUrlInput{Token: token})
+		}, wantStatus: entity.UrlStatusActive},
+		{name: "restore an active link", ctx: admin, change: func(ctx context.Context) error {
+			return s.RestoreUrl(ctx, &url.RestoreUrlInput{Token: token})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusActive},
+		{name: "hold as the owner", ctx: owner, change: func(ctx context.Context) error {
+			return s.ResolveReports(ctx, &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusHeld})
+		}, wantErr: url.ErrForbidden, wantStatus: entity.UrlStatusActive},
+		{name: "hold", ctx: admin, change: func(ctx context.Context) error {
+			return s.ResolveReports(ctx, &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusHeld})
+		}, wantStatus: entity.UrlStatusHeld},
+		{name: "enable a held link", ctx: owner, change: func(ctx context.Context) error {
+			return s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusActive})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusHeld},
+		{name: "disable a held link", ctx: owner, change: func(ctx context.Context) error {
+			return s.SetUrlStatus(ctx, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusDisabled})
+		}, wantErr: url.ErrValidation, wantStatus: entity.UrlStatusHeld},
+		{name: "restore a held link", ctx: admin, change: func(ctx context.Context) error {
+			return s.RestoreUrl(ctx, &url.RestoreUrlInput{Token: token})
+		}, wantStatus: entity.UrlStatusActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(tt.ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token})
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+
+	found, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token})
+	if found.TakedownReason != "" {
+		t.Errorf("RestoreUrl() kept the takedown reason %q", found.TakedownReason)
+	}
+
+	// a disabled link does not hold its url, the same url gets a new link
+	s.SetUrlStatus(owner, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusDisabled})
+	if existing, err := s.GetUrlByUrl(owner, &url.GetUrlByUrlInput{Url: "https://status.com"}); err == nil {
+		t.Errorf("GetUrlByUrl() returned the disabled entry %v", existing.Token)
+	}
+	again, err := s.SaveUrl(owner, &url.SaveUrlInput{Url: "https://status.com"})
+	if err != nil || again.Token == entry.Token {
+		t.Fatalf("SaveUrl() of a disabled url = %v, %v, want a new entry", again, err)
+	}
+	if err := s.SetUrlStatus(owner, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusActive}); !errors.Is(err, url.ErrValidation) {
+		t.Errorf("SetUrlStatus() enable with another active link error = %v, want %v", err, url.ErrValidation)
+	}
+
+	if err := s.DeleteUrl(owner, &url.DeleteUrlInput{Token: token}); err != nil {
+		t.Fatalf("DeleteUrl() error = %v", err)
+	}
+	if err := s.SetUrlStatus(owner, &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusActive}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("SetUrlStatus() deleted entry error = %v, want %v", err, url.ErrNotFound)
+	}
+	if err := s.TakeDownUrl(admin, &url.TakeDownUrlInput{Token: token, Reason: "phishing"}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("TakeDownUrl() deleted entry error = %v, want %v", err, url.ErrNotFound)
+	}
+	if page, _ := s.ListUrls(owner, &url.ListUrlsInput{}); page.Total != 1 {
+		t.Errorf("ListUrls() total = %d, want the deleted entry left out", page.Total)
+	}
+}
+
+func TestService_ReportUrl(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository()).WithReportThreshold(2).WithReporterKey([]byte("secret"))
+	entry, _ := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://phish.com"})
+	token := entry.Token.String()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		input   url.ReportUrlInput
+		wantErr error
+		wantKey string
+	}{
+		{name: "invalid token", ctx: ctx, input: url.ReportUrlInput{Token: "bad", Category: "phishing", Reporter: "1.1.1.1"}, wantErr: url.ErrValidation, wantKey: "token"},
+		{name: "unknown category", ctx: ctx, input: url.ReportUrlInput{Token: token, Category: "rude", Reporter: "1.1.1.1"}, wantErr: url.ErrValidation, wantKey: "category"},
+		{name: "long description", ctx: ctx, input: url.ReportUrlInput{Token: token, Category: "other", Description: strings.Repeat("a", entity.MaxReportDescriptionLength+1), Reporter: "1.1.1.1"}, wantErr: url.ErrValidation, wantKey: "description"},
+		{name: "anonymous without a reporter", ctx: ctx, input: url.ReportUrlInput{Token: token, Category: "phishing"}, wantErr: url.ErrValidation, wantKey: "reporter"},
+		{name: "unknown token", ctx: ctx, input: url.ReportUrlInput{Token: "abcdefgh", Category: "phishing", Reporter: "1.1.1.1"}, wantErr: url.ErrNotFound},
+		{name: "anonymous", ctx: ctx, input: url.ReportUrlInput{Token: token, Category: "phishing", Description: "asks for my bank login", Reporter: "1.1.1.1"}},
+		{name: "same reporter again", ctx: ctx, input: url.ReportUrlInput{Token: token, Category: "spam", Reporter: "1.1.1.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := tt.input
+			err := s.ReportUrl(tt.ctx, &input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReportUrl() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantKey != "" && input.ValidationErrors[tt.wantKey] == "" {
+				t.Errorf("ReportUrl() validation errors = %v, want %s", input.ValidationErrors, tt.wantKey)
+			}
+		})
+	}
+
+	found, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token})
+	if !found.Active() {
+		t.Fatalf("ReportUrl() disabled the link with reports from one reporter")
+	}
+
+	// a logged in user is a different reporter whatever ip they report from
+	if err := s.ReportUrl(asUser(ctx, 2), &url.ReportUrlInput{Token: token, Category: "phishing", Reporter: "1.1.1.1"}); err != nil {
+		t.Fatalf("ReportUrl() error = %v", err)
+	}
+	found, _ = s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token})
+	if found.Status != entity.UrlStatusHeld {
+		t.Fatalf("ReportUrl() status = %v after the threshold, want %v", found.Status, entity.UrlStatusHeld)
+	}
+	if err := s.ReportUrl(ctx, &url.ReportUrlInput{Token: token, Category: "phishing", Reporter: "2.2.2.2"}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("ReportUrl() held link error = %v, want %v", err, url.ErrNotFound)
+	}
+	input := &url.SetUrlStatusInput{Token: token, Status: entity.UrlStatusActive}
+	if err := s.SetUrlStatus(asUser(ctx, 1), input); !errors.Is(err, url.ErrValidation) || input.ValidationErrors["status"] == "" {
+		t.Errorf("SetUrlStatus() owner enable of a held link error = %v %v, want a status error", err, input.ValidationErrors)
+	}
+
+	if _, err := s.ListReportedUrls(asUser(ctx, 1), &url.ListReportedUrlsInput{}); !errors.Is(err, url.ErrForbidden) {
+		t.Errorf("ListReportedUrls() not an admin error = %v, want %v", err, url.ErrForbidden)
+	}
+	admin := authz.WithPrincipal(ctx, authz.Principal{UserID: 9, Admin: true})
+	page, err := s.ListReportedUrls(admin, &url.ListReportedUrlsInput{})
+	if err != nil {
+		t.Fatalf("ListReportedUrls() error = %v", err)
+	}
+	if page.Total != 1 || page.Entries[0].OpenReports != 2 || len(page.Entries[0].Reports) != 2 || len(page.Entries[0].Visits) != 7 {
+		t.Fatalf("ListReportedUrls() = %+v, want the link with its 2 reports and 7 days of visits", page)
+	}
+	if got := page.Entries[0].Reports[1]; got.Category != entity.ReportCategoryPhishing || got.Description != "asks for my bank login" {
+		t.Errorf("ListReportedUrls() first report = %+v, want the anonymous phishing report", got)
+	}
+
+	// a held link stays in the queue after its reports are resolved, until an admin restores it
+	if err := s.ResolveReports(admin, &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusHeld}); err != nil {
+		t.Fatalf("ResolveReports() error = %v", err)
+	}
+	if page, _ := s.ListReportedUrls(admin, &url.ListReportedUrlsInput{}); page.Total != 1 || page.Entries[0].OpenReports != 0 {
+		t.Fatalf("ListReportedUrls() = %+v after holding, want the held link without open reports", page)
+	}
+	if err := s.RestoreUrl(admin, &url.RestoreUrlInput{Token: token}); err != nil {
+		t.Fatalf("RestoreUrl() error = %v", err)
+	}
+	if page, _ := s.ListReportedUrls(admin, &url.ListReportedUrlsInput{}); page.Total != 0 {
+		t.Errorf("ListReportedUrls() total = %d after restoring, want 0", page.Total)
+	}
+	// the reports closed by restoring do not hold the link again
+	if err := s.ReportUrl(ctx, &url.ReportUrlInput{Token: token, Category: "phishing", Reporter: "3.3.3.3"}); err != nil {
+		t.Fatalf("ReportUrl() error = %v", err)
+	}
+	if found, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token}); !found.Active() {
+		t.Errorf("ReportUrl() status = %v after restoring, want active", found.Status)
+	}
+
+	if err := s.ResolveReports(asUser(ctx, 1), &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusActive}); !errors.Is(err, url.ErrForbidden) {
+		t.Errorf("ResolveReports() not an admin error = %v, want %v", err, url.ErrForbidden)
+	}
+	if err := s.ResolveReports(admin, &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusTakenDown}); !errors.Is(err, url.ErrValidation) {
+		t.Errorf("ResolveReports() take down without a reason error = %v, want %v", err, url.ErrValidation)
+	}
+	if err := s.ResolveReports(admin, &url.ResolveReportsInput{Token: token, Status: entity.UrlStatusTakenDown, Reason: "phishing"}); err != nil {
+		t.Fatalf("ResolveReports() error = %v", err)
+	}
+	found, _ = s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: token})
+	if !found.TakenDown() || found.TakedownReason != "phishing" {
+		t.Errorf("ResolveReports() status = %v %q, want taken down for phishing", found.Status, found.TakedownReason)
+	}
+	if page, _ := s.ListReportedUrls(admin, &url.ListReportedUrlsInput{}); page.Total != 0 {
+		t.Errorf("ListReportedUrls() total = %d after resolving, want 0", page.Total)
+	}
+
+	// the owner can still delete a link that is on hold
+	other, _ := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://spam.com"})
+	for _, reporter := range []string{"1.1.1.1", "2.2.2.2"} {
+		if err := s.ReportUrl(ctx, &url.ReportUrlInput{Token: other.Token.String(), Category: "spam", Reporter: reporter}); err != nil {
+			t.Fatalf("ReportUrl() error = %v", err)
+		}
+	}
+	if err := s.DeleteUrl(asUser(ctx, 1), &url.DeleteUrlInput{Token: other.Token.String()}); err != nil {
+		t.Errorf("DeleteUrl() held link error = %v", err)
+	}
+}
+
+func TestService_Workspace(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	owner := authz.WithPrincipal(ctx, authz.Principal{UserID: 1, WorkspaceID: 10, Role: authz.RoleOwner})
+	editor := authz.WithPrincipal(ctx, authz.Principal{UserID: 2, WorkspaceID: 10, Role: authz.RoleEditor})
+	viewer := authz.WithPrincipal(ctx, authz.Principal{UserID: 3, WorkspaceID: 10, Role: authz.RoleViewer})
+	apiKey := authz.WithPrincipal(ctx, authz.Principal{WorkspaceID: 10, Role: authz.RoleEditor, APIKeyID: 5})
+	otherWorkspace := authz.WithPrincipal(ctx, authz.Principal{UserID: 4, WorkspaceID: 11, Role: authz.RoleOwner})
+
+	saveInput := &url.SaveUrlInput{Url: "https://team.com"}
+	entry, err := s.SaveUrl(owner, saveInput)
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	if entry.WorkspaceID != 10 || entry.OwnerID != 1 {
+		t.Errorf("SaveUrl() workspace = %v, owner = %v, want 10, 1", entry.WorkspaceID, entry.OwnerID)
+	}
+	if saveInput.ManageToken != "" || entry.ManageTokenHash != "" {
+		t.Errorf("SaveUrl() gave the workspace entry a manage token")
+	}
+	if _, err := s.SaveUrl(editor, &url.SaveUrlInput{Url: "https://team.com"}); err == nil {
+		t.Errorf("SaveUrl() saved the url of the workspace twice")
+	}
+	if found, err := s.GetUrlByUrl(editor, &url.GetUrlByUrlInput{Url: "https://team.com"}); err != nil || found.Token != entry.Token {
+		t.Errorf("GetUrlByUrl() = %v, %v, want the entry of the workspace", found, err)
+	}
+	if _, err := s.SaveUrl(viewer, &url.SaveUrlInput{Url: "https://viewer.com"}); !errors.Is(err, url.ErrForbidden) {
+		t.Errorf("SaveUrl() viewer error = %v, want %v", err, url.ErrForbidden)
+	}
+	if _, err := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://team.com"}); err != nil {
+		t.Errorf("SaveUrl() personal link for the url of the workspace error = %v", err)
+	}
+
+	listTests := []struct {
+		name      string
+		ctx       context.Context
+		wantTotal int
+		wantErr   error
+	}{
+		{name: "viewer", ctx: viewer, wantTotal: 1},
+		{name: "api key", ctx: apiKey, wantTotal: 1},
+		{name: "personal links of the creator", ctx: asUser(ctx, 1), wantTotal: 1},
+		{name: "another workspace", ctx: otherWorkspace, wantTotal: 0},
+		{name: "no role", ctx: authz.WithPrincipal(ctx, authz.Principal{UserID: 3, WorkspaceID: 10}), wantErr: url.ErrForbidden},
+	}
+	for _, tt := range listTests {
+		t.Run("list "+tt.name, func(t *testing.T) {
+			page, err := s.ListUrls(tt.ctx, &url.ListUrlsInput{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListUrls() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && page.Total != tt.wantTotal {
+				t.Errorf("ListUrls() total = %v, want %v", page.Total, tt.wantTotal)
+			}
+		})
+	}
+
+	manageTests := []struct {
+		name     string
+		ctx      context.Context
+		wantErr  error
+		wantView bool
+	}{
+		{name: "viewer", ctx: viewer, wantErr: url.ErrForbidden, wantView: true},
+		{name: "creator outside the workspace", ctx: asUser(ctx, 1), wantErr: url.ErrNotFound},
+		{name: "another workspace", ctx: otherWorkspace, wantErr: url.ErrNotFound},
+		{name: "anonymous", ctx: ctx, wantErr: url.ErrNotFound},
+		{name: "editor", ctx: editor, wantView: true},
+		{name: "api key", ctx: apiKey, wantView: true},
+	}
+	for _, tt := range manageTests {
+		t.Run("manage "+tt.name, func(t *testing.T) {
+			if got := s.CanView(tt.ctx, entry); got != tt.wantView {
+				t.Errorf("CanView() = %v, want %v", got, tt.wantView)
+			}
+			err := s.SetUrlStatus(tt.ctx, &url.SetUrlStatusInput{Token: entry.Token.String(), Status: entity.UrlStatusActive})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetUrlStatus() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := s.UpdateUrl(editor, &url.UpdateUrlInput{Token: entry.Token.String(), Url: "https://team.org"}); err != nil {
+		t.Errorf("UpdateUrl() editor error = %v", err)
+	}
+	if err := s.DeleteUrl(viewer, &url.DeleteUrlInput{Token: entry.Token.String()}); !errors.Is(err, url.ErrForbidden) {
+		t.Errorf("DeleteUrl() viewer error = %v, want %v", err, url.ErrForbidden)
+	}
+	if err := s.DeleteUrl(apiKey, &url.DeleteUrlInput{Token: entry.Token.String()}); err != nil {
+		t.Errorf("DeleteUrl() api key error = %v", err)
+	}
+}
+
+func TestService_Domains(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	domainTests := []struct {
+		name        string
+		host        string
+		fallbackUrl string
+		wantHost    string
+		wantErr     error
+	}{
+		{name: "host", host: " Go.Example.com. ", wantHost: "go.example.com"},
+		{name: "host with a fallback", host: "l.example.com:8080", fallbackUrl: "https://example.com", wantHost: "l.example.com:8080"},
+		{name: "resave keeps the host", host: "go.example.com", fallbackUrl: "https://example.com", wantHost: "go.example.com"},
+		{name: "empty host", host: "", wantErr: url.ErrValidation},
+		{name: "host with a path", host: "example.com/path", wantErr: url.ErrValidation},
+		{name: "invalid fallback", host: "x.example.com", fallbackUrl: "not a url", wantErr: url.ErrValidation},
+	}
+	for _, tt := range domainTests {
+		t.Run("save "+tt.name, func(t *testing.T) {
+			d, err := s.SaveDomain(ctx, &url.SaveDomainInput{Host: tt.host, FallbackUrl: tt.fallbackUrl})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveDomain() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && d.Host != tt.wantHost {
+				t.Errorf("SaveDomain() host = %q, want %q", d.Host, tt.wantHost)
+			}
+		})
+	}
+
+	domain, err := s.GetDomain(ctx, "GO.example.com")
+	if err != nil || domain.Default() || domain.FallbackUrl != "https://example.com" {
+		t.Fatalf("GetDomain() = %+v, %v, want the resaved domain with its fallback", domain, err)
+	}
+	if domain, err := s.GetDomain(ctx, ""); err != nil || !domain.Default() {
+		t.Errorf("GetDomain() empty host = %+v, %v, want the base url", domain, err)
+	}
+	if _, err := s.GetDomain(ctx, "unknown.example.com"); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("GetDomain() unknown host error = %v, want %v", err, url.ErrNotFound)
+	}
+
+	base, err := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://shared.com"})
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	short, err := s.SaveUrl(asUser(ctx, 1), &url.SaveUrlInput{Url: "https://shared.com", Domain: "go.example.com"})
+	if err != nil {
+		t.Fatalf("SaveUrl() on the short domain error = %v", err)
+	}
+	if short.Domain != "go.example.com" || short.DomainID != domain.ID || base.Domain != "" {
+		t.Errorf("SaveUrl() domains = %q, %q, want the short domain and the base url", short.Domain, base.Domain)
+	}
+	if _, err := s.SaveUrl(ctx, &url.SaveUrlInput{Url: "https://shared.com", Domain: "unknown.example.com"}); !errors.Is(err, url.ErrValidation) {
+		t.Errorf("SaveUrl() unknown domain error = %v, want %v", err, url.ErrValidation)
+	}
+
+	if found, err := s.GetUrlByUrl(asUser(ctx, 1), &url.GetUrlByUrlInput{Url: "https://shared.com", Domain: "go.example.com"}); err != nil || found.Token != short.Token {
+		t.Errorf("GetUrlByUrl() = %v, %v, want the entry on the short domain", found, err)
+	}
+	if found, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: short.Token.String(), Domain: "go.example.com"}); err != nil || found.DomainID != domain.ID {
+		t.Errorf("GetUrlByToken() = %v, %v, want the entry on the short domain", found, err)
+	}
+	if short.Token != base.Token {
+		if _, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: short.Token.String()}); err == nil {
+			t.Errorf("GetUrlByToken() found the token of the short domain on the base url")
+		}
+	}
+
+	if _, err := s.UpdateUrl(asUser(ctx, 1), &url.UpdateUrlInput{Token: short.Token.String(), Domain: "go.example.com", Url: "https://short.com"}); err != nil {
+		t.Errorf("UpdateUrl() error = %v", err)
+	}
+	if found, _ := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: base.Token.String()}); found == nil || found.Url != "https://shared.com" {
+		t.Errorf("UpdateUrl() changed the entry on the base url")
+	}
+	if err := s.DeleteUrl(asUser(ctx, 1), &url.DeleteUrlInput{Token: short.Token.String(), Domain: "go.example.com"}); err != nil {
+		t.Fatalf("DeleteUrl() error = %v", err)
+	}
+	if _, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: short.Token.String(), Domain: "go.example.com"}); err == nil {
+		t.Errorf("DeleteUrl() entry still exists on the short domain")
+	}
+	if _, err := s.GetUrlByToken(ctx, &url.GetUrlByTokenInput{Token: base.Token.String()}); err != nil {
+		t.Errorf("DeleteUrl() removed the entry on the base url, error = %v", err)
+	}
+}
+
+func TestService_UrlRevisions(t *testing.T) {
+
+	ctx := context.Background()
+	s := url.NewService(repository.NewMemUrlEntryRepository())
+
+	owner := asUser(ctx, 1)
+	entry, _ := s.SaveUrl(owner, &url.SaveUrlInput{Url: "https://first.com"})
+	token := entry.Token.String()
+	s.SaveUrl(owner, &url.SaveUrlInput{Url: "https://taken.com"})
+
+	for _, u := range []string{"https://second.com", "https://second.com", "https://third.com"} {
+		if _, err := s.UpdateUrl(owner, &url.UpdateUrlInput{Token: token, Url: u}); err != nil {
+			t.Fatalf("UpdateUrl() error = %v", err)
+		}
+	}
+
+	revisions, err := s.ListUrlRevisions(owner, &url.ListUrlRevisionsInput{Token: token})
+	if err != nil {
+		t.Fatalf("ListUrlRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("ListUrlRevisions() = %d revisions, want 2 as an unchanged url is not a revision", len(revisions))
+	}
+	if revisions[0].OldUrl != "https://second.com" || revisions[0].NewUrl != "https://third.com" || revisions[0].UserID != 1 {
+		t.Errorf("ListUrlRevisions() newest = %+v, want second.com to third.com by user 1", revisions[0])
+	}
+	if _, err := s.ListUrlRevisions(asUser(ctx, 2), &url.ListUrlRevisionsInput{Token: token}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("ListUrlRevisions() another user error = %v, want %v", err, url.ErrNotFound)
+	}
+
+	revertTests := []struct {
+		name       string
+		ctx        context.Context
+		revisionID int64
+		wantUrl    entity.Url
+		wantErr    error
+	}{
+		{name: "another user", ctx: asUser(ctx, 2), revisionID: revisions[1].ID, wantErr: url.ErrNotFound},
+		{name: "unknown revision", ctx: owner, revisionID: 100, wantErr: url.ErrValidation},
+		{name: "first revision", ctx: owner, revisionID: revisions[1].ID, wantUrl: "https://first.com"},
+	}
+	for _, tt := range revertTests {
+		t.Run("revert "+tt.name, func(t *testing.T) {
+			reverted, err := s.RevertUrl(tt.ctx, &url.RevertUrlInput{Token: token, RevisionID: tt.revisionID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RevertUrl() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && reverted.Url != tt.wantUrl {
+				t.Errorf("RevertUrl() url = %v, want %v", reverted.Url, tt.wantUrl)
+			}
+		})
+	}
+
+	revisions, _ = s.ListUrlRevisions(owner, &url.ListUrlRevisionsInput{Token: token})
+	if len(revisions) != 3 || revisions[0].OldUrl != "https://third.com" || revisions[0].NewUrl != "https://first.com" {
+		t.Errorf("ListUrlRevisions() after the revert = %d revisions, newest %+v, want the revert recorded", len(revisions), revisions[0])
+	}
+
+	// a revert to a url another link of the owner now has is rejected like an edit
+	s.UpdateUrl(owner, &url.UpdateUrlInput{Token: token, Url: "https://fourth.com"})
+	s.SaveUrl(owner, &url.SaveUrlInput{Url: "https://first.com"})
+	revisions, _ = s.ListUrlRevisions(owner, &url.ListUrlRevisionsInput{Token: token})
+	input := &url.RevertUrlInput{Token: token, RevisionID: revisions[0].ID}
+	if _, err := s.RevertUrl(owner, input); !errors.Is(err, url.ErrValidation) || input.ValidationErrors["url"] == "" {
+		t.Errorf("RevertUrl() to a taken url error = %v, %v, want a url validation error", err, input.ValidationErrors)
+	}
+
+	s.DeleteUrl(owner, &url.DeleteUrlInput{Token: token})
+	if _, err := s.ListUrlRevisions(owner, &url.ListUrlRevisionsInput{Token: token}); !errors.Is(err, url.ErrNotFound) {
+		t.Errorf("ListUrlRevisions() deleted entry error = %v, want %v", err, url.ErrNotFound)
+	}
+
+	workspaceCtx := authz.WithPrincipal(ctx, authz.Principal{UserID: 3, WorkspaceID: 10, Role: authz.RoleEditor})
+	viewer := authz.WithPrincipal(ctx, authz.Principal{UserID: 4, WorkspaceID: 10, Role: authz.RoleViewer})
+	team, _ := s.SaveUrl(workspaceCtx, &url.SaveUrlInput{Url: "https://team.com"})
+	s.UpdateUrl(workspaceCtx, &url.UpdateUrlInput{Token: team.Token.String(), Url: "https://team.org"})
+	if revisions, err := s.ListUrlRevisions(viewer, &url.ListUrlRevisionsInput{Token: team.Token.String()}); err != nil || len(revisions) != 1 {
+		t.Errorf("ListUrlRevisions() viewer = %v, %v, want the revision of the workspace link", revisions, err)
+	}
+	if _, err := s.RevertUrl(viewer, &url.RevertUrlInput{Token: team.Token.String(), RevisionID: 1}); !errors.Is(err, url.ErrForbidden) {
+		t.Errorf("RevertUrl() viewer error = %v, want %v", err, url.ErrForbidden)
+	}
+	if revisions, _ := s.ListUrlRevisions(viewer, &url.ListUrlRevisionsInput{Token: team.Token.String()}); len(revisions) != 1 || revisions[0].UserID != 3 {
+		t.Errorf("ListUrlRevisions() viewer = %v, want the change by user 3", revisions)
+	}
+
+	// a user that changed an anonymous link with its manage token is only shown to themselves
+	anonymous := &url.SaveUrlInput{Url: "https://anonymous.com"}
+	shared, _ := s.SaveUrl(ctx, anonymous)
+	s.UpdateUrl(asUser(ctx, 5), &url.UpdateUrlInput{Token: shared.Token.String(), ManageToken: anonymous.ManageToken, Url: "https://anonymous.org"})
+	whoTests := []struct {
+		name       string
+		ctx        context.Context
+		wantUserID int64
+		wantHidden bool
+	}{
+		{name: "anonymous", ctx: ctx, wantHidden: true},
+		{name: "another user", ctx: asUser(ctx, 6), wantHidden: true},
+		{name: "the user", ctx: asUser(ctx, 5), wantUserID: 5},
+	}
+	for _, tt := range whoTests {
+		t.Run("who changed as "+tt.name, func(t *testing.T) {
+			revisions, err := s.ListUrlRevisions(tt.ctx, &url.ListUrlRevisionsInput{Token: shared.Token.String(), ManageToken: anonymous.ManageToken})
+			if err != nil || len(revisions) != 1 {
+				t.Fatalf("ListUrlRevisions() = %v, %v, want one revision", revisions, err)
+			}
+			if revisions[0].UserID != tt.wantUserID || revisions[0].UserHidden != tt.wantHidden {
+				t.Errorf("ListUrlRevisions() user = %d, hidden = %v, want %d, %v", revisions[0].UserID, revisions[0].UserHidden, tt.wantUserID, tt.wantHidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	passwordMinLength = 8
+	passwordMaxBytes  = 72 // bcrypt ignores everything after 72 bytes
+)
+
+// Email is the address a user logs in with
+type Email string
+
+// NormalizeEmail will trim and lower case the address so lookups do not depend on how it was typed
+func NormalizeEmail(email string) Email {
+	return Email(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// Validate will check the email is a single bare address
+func (e Email) Validate() error {
+	addr, err := mail.ParseAddress(e.String())
+	if err != nil || addr.Address != e.String() || addr.Name != "" {
+		return fmt.Errorf("email is not valid")
+	}
+	return nil
+}
+
+// String will return the string representation of the email
+func (e Email) String() string {
+	return string(e)
+}
+
+// Password is a plain text password as it was typed
+type Password string
+
+// Validate will check the password is long enough and fits in a bcrypt hash
+func (p Password) Validate() error {
+	if utf8.RuneCountInString(string(p)) < passwordMinLength {
+		return fmt.Errorf("password must be at least %d characters", passwordMinLength)
+	}
+	if len(p) > passwordMaxBytes {
+		return fmt.Errorf("password must be at most %d bytes", passwordMaxBytes)
+	}
+	return nil
+}
+
+// User is a registered account, links created while logged in are owned by the user
+type User struct {
+	ID           int64
+	Email        Email
+	PasswordHash []byte // The bcrypt hash of the password
+	CreatedAt    time.Time
+}
+
+// SessionKey will return a short fingerprint of the password hash.
+// It is stored in the session so changing the password logs out every other session.
+func (u *User) SessionKey() string {
+	sum := sha256.Sum256(u.PasswordHash)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Identity links a user to an account at an OpenID Connect identity provider.
+// The groups are the ones the provider said the user was in at their last login.
+type Identity struct {
+	UserID  int64
+	Issuer  string
+	Subject string
+	Groups  []string
+}
+
+// PasswordReset is a single use password reset for a user.
+// Only the sha256 hash of the token is stored, the token itself is only in the email.
+type PasswordReset struct {
+	UserID    int64
+	TokenHash string
+	ExpiresAt time.Time
+}
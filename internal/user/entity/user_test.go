@@ -0,0 +1,56 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/user/entity"
+)
+
+func TestEmail_Validate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		email   string
+		wantErr bool
+	}{
+		{name: "valid", email: "jane@example.com", wantErr: false},
+		{name: "normalized", email: "  Jane@Example.COM ", wantErr: false},
+		{name: "empty", email: "", wantErr: true},
+		{name: "no at", email: "example.com", wantErr: true},
+		{name: "display name", email: "jane <jane@example.com>", wantErr: true},
+		{name: "two addresses", email: "jane@example.com, joe@example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := entity.NormalizeEmail(tt.email).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPassword_Validate(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		password string
+		wantErr  bool
+	}{
+		{name: "valid", password: "correct horse", wantErr: false},
+		{name: "too short", password: "short", wantErr: true},
+		{name: "72 bytes", password: strings.Repeat("a", 72), wantErr: false},
+		{name: "too long", password: strings.Repeat("a", 73), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := entity.Password(tt.password).Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
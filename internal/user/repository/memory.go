@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/user"
+	"github.com/griggsjared/getsit/internal/user/entity"
+)
+
+// memPasswordReset is a password reset and whether it has been used
+type memPasswordReset struct {
+	reset entity.PasswordReset
+	used  bool
+}
+
+// MemUserRepository is a in memory repository that will repository the users
+type MemUserRepository struct {
+	users  map[int64]*entity.User
+	emails map[entity.Email]int64 //key is the email and value is the user id for a fast lookup ( O(1) )
+	resets map[string]*memPasswordReset
+	idents map[[2]string]*entity.Identity //key is the issuer and subject
+	nextID int64
+}
+
+// NewMemUserRepository will create a new in memory repository
+func NewMemUserRepository() *MemUserRepository {
+	return &MemUserRepository{
+		users:  make(map[int64]*entity.User),
+		emails: make(map[entity.Email]int64),
+		resets: make(map[string]*memPasswordReset),
+		idents: make(map[[2]string]*entity.Identity),
+	}
+}
+
+// CreateUser will save a new user
+func (s *MemUserRepository) CreateUser(ctx context.Context, email entity.Email, passwordHash []byte) (*entity.User, error) {
+	if _, ok := s.emails[email]; ok {
+		return nil, user.ErrEmailTaken
+	}
+	s.nextID++
+	u := &entity.User{
+		ID:           s.nextID,
+		Email:        email,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now(),
+	}
+	s.users[u.ID] = u
+	s.emails[email] = u.ID
+	c := *u
+	return &c, nil
+}
+
+// GetByID will return the user for the given id
+func (s *MemUserRepository) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+	if u, ok := s.users[id]; ok {
+		c := *u
+		return &c, nil
+	}
+	return nil, user.ErrNotFound
+}
+
+// GetByEmail will return the user for the given email
+func (s *MemUserRepository) GetByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+	if id, ok := s.emails[email]; ok {
+		return s.GetByID(ctx, id)
+	}
+	return nil, user.ErrNotFound
+}
+
+// UpdatePassword will replace the password hash of the user
+func (s *MemUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash []byte) error {
+	u, ok := s.users[id]
+	if !ok {
+		return user.ErrNotFound
+	}
+	u.PasswordHash = passwordHash
+	return nil
+}
+
+// SavePasswordReset will save a new password reset
+func (s *MemUserRepository) SavePasswordReset(ctx context.Context, reset *entity.PasswordReset) error {
+	s.resets[reset.TokenHash] = &memPasswordReset{reset: *reset}
+	return nil
+}
+
+// TakePasswordReset will mark the unused and unexpired password reset as used and return it
+func (s *MemUserRepository) TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*entity.PasswordReset, error) {
+	r, ok := s.resets[tokenHash]
+	if !ok || r.used || now.After(r.reset.ExpiresAt) {
+		return nil, user.ErrNotFound
+	}
+	r.used = true
+	reset := r.reset
+	return &reset, nil
+}
+
+// GetByIdentity will return the user the identity provider account is linked to
+func (s *MemUserRepository) GetByIdentity(ctx context.Context, issuer string, subject string) (*entity.User, error) {
+	if i, ok := s.idents[[2]string{issuer, subject}]; ok {
+		return s.GetByID(ctx, i.UserID)
+	}
+	return nil, user.ErrNotFound
+}
+
+// SaveIdentity will link the identity provider account to the user or update the groups of the existing link
+func (s *MemUserRepository) SaveIdentity(ctx context.Context, identity *entity.Identity) error {
+	if _, ok := s.users[identity.UserID]; !ok {
+		return user.ErrNotFound
+	}
+	if i, ok := s.idents[[2]string{identity.Issuer, identity.Subject}]; ok && i.UserID != identity.UserID {
+		return user.ErrNotFound
+	}
+	i := *identity
+	i.Groups = slices.Clone(identity.Groups)
+	s.idents[[2]string{i.Issuer, i.Subject}] = &i
+	return nil
+}
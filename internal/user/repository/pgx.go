@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/user"
+	"github.com/griggsjared/getsit/internal/user/entity"
+)
+
+// dbSystem is the span attribute that marks the repository spans as postgres queries
+var dbSystem = attribute.String("db.system", "postgresql")
+
+// uniqueViolation is the postgres error code for a unique constraint violation
+const uniqueViolation = "23505"
+
+type PGXUserRepository struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewPGXUserRepository(db *pgxpool.Pool) *PGXUserRepository {
+	return &PGXUserRepository{
+		db:     db,
+		logger: slog.Default(),
+	}
+}
+
+// WithLogger will set the logger failed queries are logged to
+func (s *PGXUserRepository) WithLogger(logger *slog.Logger) *PGXUserRepository {
+	s.logger = logger
+	return s
+}
+
+// logError will log a failed query with the request context and return the error.
+// pgx.ErrNoRows is returned as user.ErrNotFound and is not logged.
+func (s *PGXUserRepository) logError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return user.ErrNotFound
+	}
+	s.logger.ErrorContext(ctx, "query failed", slog.String("op", op), slog.String("error", err.Error()))
+	return err
+}
+
+func (s *PGXUserRepository) CreateUser(ctx context.Context, email entity.Email, passwordHash []byte) (*entity.User, error) {
+
+	defer metrics.ObserveRepositoryQuery("CreateUser", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.CreateUser", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO users (email, password_hash)
+		VALUES ($1, $2)
+		RETURNING id, created_at
+	`
+
+	u := &entity.User{
+		Email:        email,
+		PasswordHash: passwordHash,
+	}
+	err := s.db.QueryRow(ctx, query, email, string(passwordHash)).Scan(&u.ID, &u.CreatedAt)
+	if pgErr, ok := errors.AsType[*pgconn.PgError](err); ok && pgErr.Code == uniqueViolation {
+		return nil, user.ErrEmailTaken
+	}
+	if err != nil {
+		return nil, s.logError(ctx, "CreateUser", err)
+	}
+
+	return u, nil
+}
+
+func (s *PGXUserRepository) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetUserByID", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.GetByID", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT id, email, password_hash, created_at
+		FROM users
+		WHERE id = $1
+	`
+
+	u, err := scanUser(s.db.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, s.logError(ctx, "GetByID", err)
+	}
+	return u, nil
+}
+
+func (s *PGXUserRepository) GetByEmail(ctx context.Context, email entity.Email) (*entity.User, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetUserByEmail", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.GetByEmail", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT id, email, password_hash, created_at
+		FROM users
+		WHERE email = $1
+	`
+
+	u, err := scanUser(s.db.QueryRow(ctx, query, email))
+	if err != nil {
+		return nil, s.logError(ctx, "GetByEmail", err)
+	}
+	return u, nil
+}
+
+func (s *PGXUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash []byte) error {
+
+	defer metrics.ObserveRepositoryQuery("UpdatePassword", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.UpdatePassword", dbSystem)
+	defer span.End()
+
+	query := `
+		UPDATE users
+		SET password_hash = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`
+
+	tag, err := s.db.Exec(ctx, query, id, string(passwordHash))
+	if err != nil {
+		return s.logError(ctx, "UpdatePassword", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+func (s *PGXUserRepository) SavePasswordReset(ctx context.Context, reset *entity.PasswordReset) error {
+
+	defer metrics.ObserveRepositoryQuery("SavePasswordReset", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.SavePasswordReset", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`
+
+	_, err := s.db.Exec(ctx, query, reset.UserID, reset.TokenHash, reset.ExpiresAt.UTC())
+	if err != nil {
+		return s.logError(ctx, "SavePasswordReset", err)
+	}
+	return nil
+}
+
+func (s *PGXUserRepository) TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*entity.PasswordReset, error) {
+
+	defer metrics.ObserveRepositoryQuery("TakePasswordReset", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.TakePasswordReset", dbSystem)
+	defer span.End()
+
+	query := `
+		UPDATE password_resets
+		SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > $2
+		RETURNING user_id, token_hash, expires_at
+	`
+
+	var reset entity.PasswordReset
+	err := s.db.QueryRow(ctx, query, tokenHash, now.UTC()).Scan(&reset.UserID, &reset.TokenHash, &reset.ExpiresAt)
+	if err != nil {
+		return nil, s.logError(ctx, "TakePasswordReset", err)
+	}
+	return &reset, nil
+}
+
+func (s *PGXUserRepository) GetByIdentity(ctx context.Context, issuer string, subject string) (*entity.User, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetUserByIdentity", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.GetByIdentity", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT u.id, u.email, u.password_hash, u.created_at
+		FROM users u
+		JOIN user_identities i ON i.user_id = u.id
+		WHERE i.issuer = $1 AND i.subject = $2
+	`
+
+	u, err := scanUser(s.db.QueryRow(ctx, query, issuer, subject))
+	if err != nil {
+		return nil, s.logError(ctx, "GetByIdentity", err)
+	}
+	return u, nil
+}
+
+func (s *PGXUserRepository) SaveIdentity(ctx context.Context, identity *entity.Identity) error {
+
+	defer metrics.ObserveRepositoryQuery("SaveIdentity", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXUserRepository.SaveIdentity", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, groups)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (issuer, subject) DO UPDATE
+		SET groups = EXCLUDED.groups, updated_at = CURRENT_TIMESTAMP
+		WHERE user_identities.user_id = EXCLUDED.user_id
+	`
+
+	groups := identity.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	tag, err := s.db.Exec(ctx, query, identity.UserID, identity.Issuer, identity.Subject, groups)
+	if err != nil {
+		return s.logError(ctx, "SaveIdentity", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return user.ErrNotFound
+	}
+	return nil
+}
+
+// scanUser will scan a users row
+func scanUser(row pgx.Row) (*entity.User, error) {
+	var u entity.User
+	var email, hash string
+	if err := row.Scan(&u.ID, &email, &hash, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	u.Email = entity.Email(email)
+	u.PasswordHash = []byte(hash)
+	return &u, nil
+}
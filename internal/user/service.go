@@ -0,0 +1,328 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/griggsjared/getsit/internal/mailer"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/user/entity"
+)
+
+var (
+	// ErrValidation is a generic validation error that can be returned when input validation fails
+	ErrValidation = errors.New("validation error")
+	// ErrNotFound is returned by the repository when there is no matching user or password reset
+	ErrNotFound = errors.New("not found")
+	// ErrEmailTaken is returned by the repository when the email is already registered
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrInvalidCredentials is returned when the email and password do not match an account
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidResetToken is returned when the password reset token is unknown, used or expired
+	ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+	// ErrUnverifiedEmail is returned when an identity provider logs in someone new without a verified email
+	ErrUnverifiedEmail = errors.New("the identity provider did not verify the email")
+	// ErrPasswordAccount is returned when an identity provider logs in someone new with the email of an account that has a password
+	ErrPasswordAccount = errors.New("the email belongs to an account with a password")
+)
+
+// passwordResetTTL is how long a password reset link can be used for
+const passwordResetTTL = time.Hour
+
+// withValidationErrors is a struct that can be embedded into the various input structs to hold validation errors
+type withValidationErrors struct {
+	ValidationErrors map[string]string
+}
+
+// UserRepository is the interface that defines the method that the service will use to interact with the repository
+type UserRepository interface {
+	// CreateUser will save a new user, ErrEmailTaken is returned when the email is already registered
+	CreateUser(ctx context.Context, email entity.Email, passwordHash []byte) (*entity.User, error)
+	// GetByID will get the user from the id, ErrNotFound is returned when there is no user
+	GetByID(ctx context.Context, id int64) (*entity.User, error)
+	// GetByEmail will get the user from the email, ErrNotFound is returned when there is no user
+	GetByEmail(ctx context.Context, email entity.Email) (*entity.User, error)
+	// UpdatePassword will replace the password hash of the user
+	UpdatePassword(ctx context.Context, id int64, passwordHash []byte) error
+	// SavePasswordReset will save a new password reset
+	SavePasswordReset(ctx context.Context, reset *entity.PasswordReset) error
+	// TakePasswordReset will mark the unused and unexpired password reset as used and return it,
+	// ErrNotFound is returned when there is no such reset
+	TakePasswordReset(ctx context.Context, tokenHash string, now time.Time) (*entity.PasswordReset, error)
+	// GetByIdentity will get the user the identity provider account is linked to, ErrNotFound is returned when it is not linked
+	GetByIdentity(ctx context.Context, issuer string, subject string) (*entity.User, error)
+	// SaveIdentity will link the identity provider account to the user or update the groups of the existing link
+	SaveIdentity(ctx context.Context, identity *entity.Identity) error
+}
+
+// Service manages the user accounts
+type Service struct {
+	repo   UserRepository
+	mailer mailer.Mailer
+	cost   int
+	now    func() time.Time
+
+	// dummyHash is compared against when the email is unknown so a login takes the same time either way
+	dummyHash []byte
+}
+
+// NewService will create a new service
+func NewService(repo UserRepository, m mailer.Mailer) *Service {
+	return newService(repo, m, bcrypt.DefaultCost)
+}
+
+// NewTestService will create a new service with the cheapest password hashing, it should only be used in tests
+func NewTestService(repo UserRepository, m mailer.Mailer) *Service {
+	return newService(repo, m, bcrypt.MinCost)
+}
+
+func newService(repo UserRepository, m mailer.Mailer, cost int) *Service {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("getsit-dummy-password"), cost)
+	return &Service{
+		repo:      repo,
+		mailer:    m,
+		cost:      cost,
+		now:       time.Now,
+		dummyHash: dummyHash,
+	}
+}
+
+// RegisterInput is the input struct for the Register method
+type RegisterInput struct {
+	withValidationErrors
+	Email    string
+	Password string
+}
+
+// Register will validate the email and password and create a new user
+func (s *Service) Register(ctx context.Context, input *RegisterInput) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.Register")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	email := entity.NormalizeEmail(input.Email)
+	if err := email.Validate(); err != nil {
+		input.ValidationErrors["email"] = err.Error()
+	}
+	password := entity.Password(input.Password)
+	if err := password.Validate(); err != nil {
+		input.ValidationErrors["password"] = err.Error()
+	}
+	if len(input.ValidationErrors) > 0 {
+		return nil, ErrValidation
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the password: %w", err)
+	}
+
+	u, err := s.repo.CreateUser(ctx, email, hash)
+	if errors.Is(err, ErrEmailTaken) {
+		input.ValidationErrors["email"] = "an account with this email already exists"
+		return nil, ErrValidation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// AuthenticateInput is the input struct for the Authenticate method
+type AuthenticateInput struct {
+	Email    string
+	Password string
+}
+
+// Authenticate will return the user when the email and password match, otherwise ErrInvalidCredentials
+func (s *Service) Authenticate(ctx context.Context, input *AuthenticateInput) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.Authenticate")
+	defer span.End()
+
+	u, err := s.repo.GetByEmail(ctx, entity.NormalizeEmail(input.Email))
+	if errors.Is(err, ErrNotFound) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(input.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(input.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return u, nil
+}
+
+// GetByID will get the user from the id
+func (s *Service) GetByID(ctx context.Context, id int64) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.GetByID")
+	defer span.End()
+
+	return s.repo.GetByID(ctx, id)
+}
+
+// GetByEmail will get the user from the email, the email is normalized first
+func (s *Service) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.GetByEmail")
+	defer span.End()
+
+	return s.repo.GetByEmail(ctx, entity.NormalizeEmail(email))
+}
+
+// RequestPasswordResetInput is the input struct for the RequestPasswordReset method
+type RequestPasswordResetInput struct {
+	Email   string
+	LinkFor func(token string) string // Builds the reset link sent in the email
+}
+
+// RequestPasswordReset will email a single use reset link when the email is registered.
+// Nothing is returned about whether the email is registered so the form can not be used to find accounts.
+func (s *Service) RequestPasswordReset(ctx context.Context, input *RequestPasswordResetInput) error {
+
+	ctx, span := tracing.Start(ctx, "user.Service.RequestPasswordReset")
+	defer span.End()
+
+	u, err := s.repo.GetByEmail(ctx, entity.NormalizeEmail(input.Email))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	err = s.repo.SavePasswordReset(ctx, &entity.PasswordReset{
+		UserID:    u.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: s.now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      u.Email.String(),
+		Subject: "Reset your getsit password",
+		Body: "Someone asked to reset the password of your getsit account.\n\n" +
+			"Follow this link within the next hour to choose a new password:\n" + input.LinkFor(token) + "\n\n" +
+			"If it was not you, you can ignore this email.\n",
+	})
+}
+
+// ResetPasswordInput is the input struct for the ResetPassword method
+type ResetPasswordInput struct {
+	withValidationErrors
+	Token    string
+	Password string
+}
+
+// ResetPassword will set a new password with a token from a reset email, the token can only be used once
+func (s *Service) ResetPassword(ctx context.Context, input *ResetPasswordInput) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.ResetPassword")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	password := entity.Password(input.Password)
+	if err := password.Validate(); err != nil {
+		input.ValidationErrors["password"] = err.Error()
+		return nil, ErrValidation
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash the password: %w", err)
+	}
+
+	reset, err := s.repo.TakePasswordReset(ctx, hashToken(input.Token), s.now())
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidResetToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdatePassword(ctx, reset.UserID, hash); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, reset.UserID)
+}
+
+// LoginWithIdentityInput is the input struct for the LoginWithIdentity method
+type LoginWithIdentityInput struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// LoginWithIdentity will return the user an identity provider logged in.
+// An account at the provider is linked to a user the first time it logs in, to the user with the same email or to a new user without a password.
+// The email has to be verified by the provider to be linked, otherwise anyone could claim an existing account.
+// A user with a password is never linked, registering does not prove the email so it could belong to someone else.
+func (s *Service) LoginWithIdentity(ctx context.Context, input *LoginWithIdentityInput) (*entity.User, error) {
+
+	ctx, span := tracing.Start(ctx, "user.Service.LoginWithIdentity")
+	defer span.End()
+
+	identity := &entity.Identity{Issuer: input.Issuer, Subject: input.Subject, Groups: input.Groups}
+
+	u, err := s.repo.GetByIdentity(ctx, input.Issuer, input.Subject)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	if u == nil {
+		email := entity.NormalizeEmail(input.Email)
+		if !input.EmailVerified || email.Validate() != nil {
+			return nil, ErrUnverifiedEmail
+		}
+		u, err = s.repo.GetByEmail(ctx, email)
+		if errors.Is(err, ErrNotFound) {
+			u, err = s.repo.CreateUser(ctx, email, nil)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(u.PasswordHash) > 0 {
+			return nil, ErrPasswordAccount
+		}
+	}
+
+	identity.UserID = u.ID
+	if err := s.repo.SaveIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// hashToken will return the hex sha256 of the token, the tokens are random so a fast hash is enough
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
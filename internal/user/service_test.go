@@ -0,0 +1,258 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/mailer"
+	"github.com/griggsjared/getsit/internal/user"
+	"github.com/griggsjared/getsit/internal/user/repository"
+)
+
+func newService() (*user.Service, *mailer.LogMailer) {
+	m := mailer.NewLogMailer(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	return user.NewTestService(repository.NewMemUserRepository(), m), m
+}
+
+func TestService_Register(t *testing.T) {
+
+	ctx := context.Background()
+	s, _ := newService()
+
+	if _, err := s.Register(ctx, &user.RegisterInput{Email: "taken@example.com", Password: "password1"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		email     string
+		password  string
+		wantField string // The field with a validation error, empty when the registration should succeed
+	}{
+		{name: "valid", email: "new@example.com", password: "password1", wantField: ""},
+		{name: "taken", email: "taken@example.com", password: "password1", wantField: "email"},
+		{name: "taken with different case", email: " Taken@Example.com", password: "password1", wantField: "email"},
+		{name: "invalid email", email: "nope", password: "password1", wantField: "email"},
+		{name: "short password", email: "short@example.com", password: "short", wantField: "password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := &user.RegisterInput{Email: tt.email, Password: tt.password}
+			u, err := s.Register(ctx, input)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("Register() error = %v", err)
+				}
+				if string(u.PasswordHash) == tt.password {
+					t.Errorf("Register() stored the plain text password")
+				}
+				return
+			}
+			if !errors.Is(err, user.ErrValidation) {
+				t.Fatalf("Register() error = %v, want %v", err, user.ErrValidation)
+			}
+			if _, ok := input.ValidationErrors[tt.wantField]; !ok {
+				t.Errorf("Register() validation errors = %v, want one for %s", input.ValidationErrors, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestService_Authenticate(t *testing.T) {
+
+	ctx := context.Background()
+	s, _ := newService()
+
+	registered, err := s.Register(ctx, &user.RegisterInput{Email: "jane@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{name: "valid", email: "jane@example.com", password: "password1", wantErr: nil},
+		{name: "email case", email: "JANE@example.com", password: "password1", wantErr: nil},
+		{name: "wrong password", email: "jane@example.com", password: "password2", wantErr: user.ErrInvalidCredentials},
+		{name: "unknown email", email: "joe@example.com", password: "password1", wantErr: user.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := s.Authenticate(ctx, &user.AuthenticateInput{Email: tt.email, Password: tt.password})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && u.ID != registered.ID {
+				t.Errorf("Authenticate() user = %v, want %v", u.ID, registered.ID)
+			}
+		})
+	}
+}
+
+func TestService_ResetPassword(t *testing.T) {
+
+	ctx := context.Background()
+	s, m := newService()
+
+	registered, err := s.Register(ctx, &user.RegisterInput{Email: "jane@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	linkFor := func(token string) string {
+		return "https://getsit.test/password/reset?token=" + url.QueryEscape(token)
+	}
+
+	// an unknown email is not an error and sends nothing
+	if err := s.RequestPasswordReset(ctx, &user.RequestPasswordResetInput{Email: "joe@example.com", LinkFor: linkFor}); err != nil {
+		t.Fatalf("RequestPasswordReset() unknown email error = %v", err)
+	}
+	if len(m.Sent()) != 0 {
+		t.Fatalf("RequestPasswordReset() sent %d emails for an unknown email", len(m.Sent()))
+	}
+
+	if err := s.RequestPasswordReset(ctx, &user.RequestPasswordResetInput{Email: "jane@example.com", LinkFor: linkFor}); err != nil {
+		t.Fatalf("RequestPasswordReset() error = %v", err)
+	}
+	sent := m.Sent()
+	if len(sent) != 1 || sent[0].To != "jane@example.com" {
+		t.Fatalf("RequestPasswordReset() sent = %+v, want one email to jane@example.com", sent)
+	}
+	_, link, ok := strings.Cut(sent[0].Body, "?token=")
+	if !ok {
+		t.Fatalf("reset email has no link: %q", sent[0].Body)
+	}
+	token, _ := url.QueryUnescape(strings.Fields(link)[0])
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantErr  error
+	}{
+		{name: "short password", token: token, password: "short", wantErr: user.ErrValidation},
+		{name: "unknown token", token: "nope", password: "password2", wantErr: user.ErrInvalidResetToken},
+		{name: "valid", token: token, password: "password2", wantErr: nil},
+		{name: "used token", token: token, password: "password3", wantErr: user.ErrInvalidResetToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := s.ResetPassword(ctx, &user.ResetPasswordInput{Token: tt.token, Password: tt.password})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResetPassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && u.SessionKey() == registered.SessionKey() {
+				t.Errorf("ResetPassword() did not change the session key")
+			}
+		})
+	}
+
+	if _, err := s.Authenticate(ctx, &user.AuthenticateInput{Email: "jane@example.com", Password: "password2"}); err != nil {
+		t.Errorf("Authenticate() with the new password error = %v", err)
+	}
+	if _, err := s.Authenticate(ctx, &user.AuthenticateInput{Email: "jane@example.com", Password: "password1"}); !errors.Is(err, user.ErrInvalidCredentials) {
+		t.Errorf("Authenticate() with the old password error = %v, want %v", err, user.ErrInvalidCredentials)
+	}
+}
+
+func TestService_LoginWithIdentity(t *testing.T) {
+
+	ctx := context.Background()
+	s, _ := newService()
+
+	registered, err := s.Register(ctx, &user.RegisterInput{Email: "jane@example.com", Password: "password1"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		input   user.LoginWithIdentityInput
+		wantID  int64 // The user that should be logged in, -1 for a new user, -2 for the last new user
+		wantErr error
+	}{
+		{
+			name:    "unverified email of an existing user",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "jane", Email: "jane@example.com"},
+			wantErr: user.ErrUnverifiedEmail,
+		},
+		{
+			name:    "verified email of a user with a password",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "jane", Email: "Jane@Example.com", EmailVerified: true},
+			wantErr: user.ErrPasswordAccount,
+		},
+		{
+			name:    "not linked to the user with a password",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "jane"},
+			wantErr: user.ErrUnverifiedEmail,
+		},
+		{
+			name:    "same subject at another issuer",
+			input:   user.LoginWithIdentityInput{Issuer: "https://other.test", Subject: "jane", Email: "someone@example.com"},
+			wantErr: user.ErrUnverifiedEmail,
+		},
+		{
+			name:    "invalid email",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "nope", Email: "nope", EmailVerified: true},
+			wantErr: user.ErrUnverifiedEmail,
+		},
+		{
+			name:    "new user",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "joe", Email: "joe@example.com", EmailVerified: true},
+			wantID:  -1,
+			wantErr: nil,
+		},
+		{
+			name:    "linked without an email",
+			input:   user.LoginWithIdentityInput{Issuer: "https://idp.test", Subject: "joe"},
+			wantID:  -2,
+			wantErr: nil,
+		},
+		{
+			name:    "verified email of a user without a password",
+			input:   user.LoginWithIdentityInput{Issuer: "https://other.test", Subject: "joe", Email: "joe@example.com", EmailVerified: true},
+			wantID:  -2,
+			wantErr: nil,
+		},
+	}
+
+	var created int64
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := s.LoginWithIdentity(ctx, &tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoginWithIdentity() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.wantID == -2 {
+				tt.wantID = created
+			}
+			if tt.wantID == -1 {
+				created = u.ID
+				if u.ID == registered.ID {
+					t.Fatalf("LoginWithIdentity() logged in the existing user, want a new user")
+				}
+				if _, err := s.Authenticate(ctx, &user.AuthenticateInput{Email: tt.input.Email, Password: ""}); !errors.Is(err, user.ErrInvalidCredentials) {
+					t.Errorf("Authenticate() of a new user without a password error = %v, want %v", err, user.ErrInvalidCredentials)
+				}
+				return
+			}
+			if u.ID != tt.wantID {
+				t.Errorf("LoginWithIdentity() user = %d, want %d", u.ID, tt.wantID)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package useragent
+
+import (
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/botdetect"
+)
+
+// DeviceClass is the broad class of device that sent the request
+type DeviceClass string
+
+const (
+	DeviceClassDesktop DeviceClass = "desktop"
+	DeviceClassMobile  DeviceClass = "mobile"
+	DeviceClassTablet  DeviceClass = "tablet"
+	DeviceClassBot     DeviceClass = "bot"
+)
+
+// Other is used for any dimension that could not be identified
+const Other = "Other"
+
+// UserAgent holds the dimensions parsed from a user agent string
+type UserAgent struct {
+	BrowserFamily  string      // The browser family, e.g. Chrome, Firefox, Safari
+	BrowserVersion string      // The major version of the browser, empty when unknown
+	OS             string      // The operating system family, e.g. Windows, macOS, iOS
+	DeviceClass    DeviceClass // The class of device, mobile, tablet, desktop or bot
+}
+
+// browserMatcher maps a token found in the user agent to a browser family.
+// The version is read from the characters that follow the token.
+type browserMatcher struct {
+	token  string
+	family string
+}
+
+// browserMatchers is checked in order, browsers that include other browser tokens in
+// their user agent (Edge and Opera include Chrome, Chrome includes Safari) must come first.
+var browserMatchers = []browserMatcher{
+	{token: "Edg/", family: "Edge"},
+	{token: "EdgA/", family: "Edge"},
+	{token: "EdgiOS/", family: "Edge"},
+	{token: "Edge/", family: "Edge"},
+	{token: "OPR/", family: "Opera"},
+	{token: "OPiOS/", family: "Opera"},
+	{token: "Opera/", family: "Opera"},
+	{token: "SamsungBrowser/", family: "Samsung Internet"},
+	{token: "YaBrowser/", family: "Yandex Browser"},
+	{token: "Vivaldi/", family: "Vivaldi"},
+	{token: "DuckDuckGo/", family: "DuckDuckGo"},
+	{token: "FxiOS/", family: "Firefox"},
+	{token: "Firefox/", family: "Firefox"},
+	{token: "CriOS/", family: "Chrome"},
+	{token: "Chromium/", family: "Chromium"},
+	{token: "Chrome/", family: "Chrome"},
+	{token: "MSIE ", family: "Internet Explorer"},
+	{token: "Trident/", family: "Internet Explorer"},
+}
+
+// Parse will parse the user agent string into its browser, os and device class dimensions
+func Parse(ua string) UserAgent {
+
+	if strings.TrimSpace(ua) == "" || botdetect.IsBotUserAgent(ua) {
+		return UserAgent{
+			BrowserFamily: Other,
+			OS:            parseOS(ua),
+			DeviceClass:   DeviceClassBot,
+		}
+	}
+
+	family, version := parseBrowser(ua)
+
+	return UserAgent{
+		BrowserFamily:  family,
+		BrowserVersion: version,
+		OS:             parseOS(ua),
+		DeviceClass:    parseDeviceClass(ua),
+	}
+}
+
+// parseBrowser will find the browser family and major version
+func parseBrowser(ua string) (string, string) {
+
+	for _, m := range browserMatchers {
+		if idx := strings.Index(ua, m.token); idx >= 0 {
+			version := majorVersion(ua[idx+len(m.token):])
+			if m.token == "Trident/" {
+				// Trident reports the engine version, IE 11 is the only browser still sending it
+				version = "11"
+			}
+			return m.family, version
+		}
+	}
+
+	// Safari reports the browser version in Version/ rather than next to its own token
+	if strings.Contains(ua, "AppleWebKit/") {
+		if idx := strings.Index(ua, "Version/"); idx >= 0 {
+			return "Safari", majorVersion(ua[idx+len("Version/"):])
+		}
+		if strings.Contains(ua, "Mobile/") {
+			// in-app browsers on iOS use WKWebView without a Version/ token
+			return "WebView", ""
+		}
+	}
+
+	return Other, ""
+}
+
+// parseOS will find the operating system family
+func parseOS(ua string) string {
+	switch {
+	case strings.Contains(ua, "Windows Phone"):
+		return "Windows Phone"
+	case strings.Contains(ua, "Windows"):
+		return "Windows"
+	case strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPad"), strings.Contains(ua, "iPod"):
+		return "iOS"
+	case strings.Contains(ua, "Mac OS X"), strings.Contains(ua, "Macintosh"):
+		return "macOS"
+	case strings.Contains(ua, "Android"):
+		return "Android"
+	case strings.Contains(ua, "CrOS"):
+		return "Chrome OS"
+	case strings.Contains(ua, "Linux"), strings.Contains(ua, "X11"):
+		return "Linux"
+	}
+	return Other
+}
+
+// parseDeviceClass will decide if the device is a tablet, mobile or desktop
+func parseDeviceClass(ua string) DeviceClass {
+	switch {
+	case strings.Contains(ua, "iPad"), strings.Contains(ua, "Tablet"):
+		return DeviceClassTablet
+	case strings.Contains(ua, "Android") && !strings.Contains(ua, "Mobile"):
+		return DeviceClassTablet
+	case strings.Contains(ua, "Mobi"), strings.Contains(ua, "iPhone"), strings.Contains(ua, "iPod"), strings.Contains(ua, "Windows Phone"):
+		return DeviceClassMobile
+	}
+	return DeviceClassDesktop
+}
+
+// majorVersion will return the leading digits of a version string
+func majorVersion(v string) string {
+	end := 0
+	for end < len(v) && v[end] >= '0' && v[end] <= '9' {
+		end++
+	}
+	return v[:end]
+}
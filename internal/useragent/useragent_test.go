@@ -0,0 +1,123 @@
+package useragent_test
+
+import (
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/useragent"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want useragent.UserAgent
+	}{
+		{
+			name: "chrome on windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "126", OS: "Windows", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "chrome on macos",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.6422.142 Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "125", OS: "macOS", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "chrome on android phone",
+			ua:   "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.6478.71 Mobile Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "126", OS: "Android", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "chrome on android tablet",
+			ua:   "Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.6478.71 Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "126", OS: "Android", DeviceClass: useragent.DeviceClassTablet},
+		},
+		{
+			name: "chrome on ios",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/126.0.6478.54 Mobile/15E148 Safari/604.1",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "126", OS: "iOS", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "chrome on chromebook",
+			ua:   "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Chrome", BrowserVersion: "126", OS: "Chrome OS", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "safari on macos",
+			ua:   "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+			want: useragent.UserAgent{BrowserFamily: "Safari", BrowserVersion: "17", OS: "macOS", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "safari on iphone",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1",
+			want: useragent.UserAgent{BrowserFamily: "Safari", BrowserVersion: "17", OS: "iOS", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "safari on ipad",
+			ua:   "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			want: useragent.UserAgent{BrowserFamily: "Safari", BrowserVersion: "16", OS: "iOS", DeviceClass: useragent.DeviceClassTablet},
+		},
+		{
+			name: "ios webview",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			want: useragent.UserAgent{BrowserFamily: "WebView", BrowserVersion: "", OS: "iOS", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "firefox on linux",
+			ua:   "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:127.0) Gecko/20100101 Firefox/127.0",
+			want: useragent.UserAgent{BrowserFamily: "Firefox", BrowserVersion: "127", OS: "Linux", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "firefox on ios",
+			ua:   "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/127.0 Mobile/15E148 Safari/605.1.15",
+			want: useragent.UserAgent{BrowserFamily: "Firefox", BrowserVersion: "127", OS: "iOS", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "edge on windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.2592.68",
+			want: useragent.UserAgent{BrowserFamily: "Edge", BrowserVersion: "126", OS: "Windows", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "opera on windows",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 OPR/111.0.0.0",
+			want: useragent.UserAgent{BrowserFamily: "Opera", BrowserVersion: "111", OS: "Windows", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "samsung internet",
+			ua:   "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/25.0 Chrome/121.0.0.0 Mobile Safari/537.36",
+			want: useragent.UserAgent{BrowserFamily: "Samsung Internet", BrowserVersion: "25", OS: "Android", DeviceClass: useragent.DeviceClassMobile},
+		},
+		{
+			name: "internet explorer 11",
+			ua:   "Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			want: useragent.UserAgent{BrowserFamily: "Internet Explorer", BrowserVersion: "11", OS: "Windows", DeviceClass: useragent.DeviceClassDesktop},
+		},
+		{
+			name: "googlebot",
+			ua:   "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want: useragent.UserAgent{BrowserFamily: useragent.Other, BrowserVersion: "", OS: useragent.Other, DeviceClass: useragent.DeviceClassBot},
+		},
+		{
+			name: "slack unfurler",
+			ua:   "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			want: useragent.UserAgent{BrowserFamily: useragent.Other, BrowserVersion: "", OS: useragent.Other, DeviceClass: useragent.DeviceClassBot},
+		},
+		{
+			name: "empty user agent",
+			ua:   "",
+			want: useragent.UserAgent{BrowserFamily: useragent.Other, BrowserVersion: "", OS: useragent.Other, DeviceClass: useragent.DeviceClassBot},
+		},
+		{
+			name: "unknown user agent",
+			ua:   "SomethingNew/1.0",
+			want: useragent.UserAgent{BrowserFamily: useragent.Other, BrowserVersion: "", OS: useragent.Other, DeviceClass: useragent.DeviceClassDesktop},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := useragent.Parse(tt.ua); got != tt.want {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package webapp
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+
+	"github.com/gorilla/sessions"
+
+	"github.com/griggsjared/getsit/internal/baseurl"
+	"github.com/griggsjared/getsit/internal/geoip"
+	"github.com/griggsjared/getsit/internal/health"
+	"github.com/griggsjared/getsit/internal/oidc"
+	"github.com/griggsjared/getsit/internal/pow"
+	"github.com/griggsjared/getsit/internal/qrcode"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/requestid"
+	"github.com/griggsjared/getsit/internal/secheaders"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/user"
+	"github.com/griggsjared/getsit/internal/workspace"
+	"github.com/griggsjared/getsit/web"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// Options are the dependencies of the web application
+type Options struct {
+	UrlService     *url.Service
+	QRCodeService  *qrcode.Service
+	GeoIPService   *geoip.Service
+	Health         *health.Checker
+	Logger         *slog.Logger
+	SessionSecret  string
+	BaseURL        *baseurl.BaseURL   // The public url links are built from, links follow the request when nil
+	RateLimiter    *ratelimit.Limiter // Requests are not limited when nil
+	RateLimits     ratelimit.Policies
+	TrustedProxies []netip.Prefix // The proxies allowed to set the forwarding headers
+	PoWService     *pow.Service   // The create form is not checked when nil
+	Security       secheaders.Config
+	UserService    *user.Service  // Accounts are disabled when nil
+	OIDC           *oidc.Provider // Single sign on is disabled when nil
+	OIDCName       string         // The name of the identity provider shown on the login page
+
+	WorkspaceService *workspace.Service // Workspaces are disabled when nil, they also need the user service
+	Domains          []string           // The hosts of the short domains links can also be created on
+	AdminUserIDs     []int64            // The users that can review reported links and take links down
+}
+
+// App is the server rendered web application for creating and following short urls
+type App struct {
+	urlService    *url.Service
+	qrcodeService *qrcode.Service
+	geoipService  *geoip.Service
+	health        *health.Checker
+	logger        *slog.Logger
+	session       *sessions.CookieStore
+	historyStore  *sessions.CookieStore // The store of the history session, its cookies are accepted for as long as the history lasts
+	realip        *realip.Resolver
+	baseURL       *baseurl.BaseURL
+	limiter       *ratelimit.Limiter
+	limits        ratelimit.Policies
+	powService    *pow.Service
+	security      secheaders.Config
+	userService   *user.Service
+	oidc          *oidc.Provider
+	oidcName      string
+
+	workspaceService *workspace.Service
+	domains          []string
+	adminUserIDs     []int64
+}
+
+// New will create a new web application
+func New(opts Options) *App {
+	if opts.BaseURL == nil {
+		opts.BaseURL = &baseurl.BaseURL{}
+	}
+	// the cookie codecs reject cookies older than 30 days by default, the history lasts longer
+	historyStore := sessions.NewCookieStore([]byte(opts.SessionSecret))
+	historyStore.MaxAge(historyMaxAge)
+	return &App{
+		urlService:    opts.UrlService,
+		qrcodeService: opts.QRCodeService,
+		geoipService:  opts.GeoIPService,
+		health:        opts.Health,
+		logger:        opts.Logger,
+		session:       sessions.NewCookieStore([]byte(opts.SessionSecret)),
+		historyStore:  historyStore,
+		realip:        realip.NewResolver(opts.TrustedProxies),
+		baseURL:       opts.BaseURL,
+		limiter:       opts.RateLimiter,
+		limits:        opts.RateLimits,
+		powService:    opts.PoWService,
+		security:      opts.Security,
+		userService:   opts.UserService,
+		oidc:          opts.OIDC,
+		oidcName:      opts.OIDCName,
+
+		workspaceService: opts.WorkspaceService,
+		domains:          opts.Domains,
+		adminUserIDs:     opts.AdminUserIDs,
+	}
+}
+
+// Handler will return the routes of the web application wrapped in the request middleware.
+// The routes do not include the base url path prefix, it should be stripped before the handler.
+func (a *App) Handler() http.Handler {
+
+	csrfProtection := http.NewCrossOriginProtection()
+	csrfProtection.SetDenyHandler(http.HandlerFunc(a.forbiddenHandler))
+	csrfMiddleware := csrfProtection.Handler
+
+	mux := http.NewServeMux()
+
+	tooManyRequests := a.middlewareStack(http.HandlerFunc(a.tooManyRequestsHandler), a.templateColorMiddleware)
+	createLimit := a.limiter.Middleware(a.limits.Create, tooManyRequests)
+	redirectLimit := a.limiter.Middleware(a.limits.Redirect, tooManyRequests)
+	notFoundLimit := a.limiter.NotFoundMiddleware(a.limits.NotFound, tooManyRequests)
+	authLimit := a.limiter.Middleware(a.limits.Auth, tooManyRequests)
+
+	mux.HandleFunc("GET /{$}", a.middlewareStackFunc(a.homepageHandler, a.templateColorMiddleware, a.userMiddleware))
+	mux.HandleFunc("POST /create", a.middlewareStackFunc(a.createHandler, csrfMiddleware, createLimit, a.userMiddleware))
+	mux.HandleFunc("GET /i/{token}", a.middlewareStackFunc(a.infoHandler, a.templateColorMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("GET /{token}", a.middlewareStackFunc(a.redirectHandler, a.templateColorMiddleware, notFoundLimit, redirectLimit))
+	mux.HandleFunc("GET /links/{token}/edit", a.middlewareStackFunc(a.editLinkPageHandler, a.templateColorMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/edit", a.middlewareStackFunc(a.editLinkHandler, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/disable", a.middlewareStackFunc(a.setLinkStatusHandler(entity.UrlStatusDisabled, "The link has been disabled."), a.templateColorMiddleware, csrfMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/enable", a.middlewareStackFunc(a.setLinkStatusHandler(entity.UrlStatusActive, "The link has been enabled."), a.templateColorMiddleware, csrfMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/revert", a.middlewareStackFunc(a.revertLinkHandler, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/delete", a.middlewareStackFunc(a.deleteLinkHandler, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("GET /links/{token}/report", a.middlewareStackFunc(a.reportLinkPageHandler, a.templateColorMiddleware, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("POST /links/{token}/report", a.middlewareStackFunc(a.reportLinkHandler, a.templateColorMiddleware, csrfMiddleware, createLimit, a.userMiddleware, notFoundLimit))
+	mux.HandleFunc("GET /history", a.middlewareStackFunc(a.historyHandler, a.templateColorMiddleware, a.userMiddleware))
+	mux.HandleFunc("POST /history/{token}/forget", a.middlewareStackFunc(a.forgetHistoryHandler, csrfMiddleware))
+	mux.HandleFunc("POST /history/clear", a.middlewareStackFunc(a.clearHistoryHandler, csrfMiddleware))
+	mux.HandleFunc("GET /healthz", a.healthzHandler)
+	mux.HandleFunc("GET /readyz", a.readyzHandler)
+	mux.HandleFunc("/", a.middlewareStackFunc(a.notFoundHandler, a.templateColorMiddleware, a.userMiddleware, notFoundLimit))
+
+	if a.userService != nil {
+		mux.HandleFunc("GET /register", a.middlewareStackFunc(a.authPageHandler(template.Register), a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /register", a.middlewareStackFunc(a.registerHandler, csrfMiddleware, authLimit))
+		mux.HandleFunc("GET /login", a.middlewareStackFunc(a.authPageHandler(template.Login), a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /login", a.middlewareStackFunc(a.loginHandler, csrfMiddleware, authLimit))
+		mux.HandleFunc("POST /logout", a.middlewareStackFunc(a.logoutHandler, csrfMiddleware))
+		mux.HandleFunc("GET /password/forgot", a.middlewareStackFunc(a.authPageHandler(template.ForgotPassword), a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /password/forgot", a.middlewareStackFunc(a.forgotPasswordHandler, csrfMiddleware, authLimit))
+		mux.HandleFunc("GET /password/reset", a.middlewareStackFunc(a.authPageHandler(template.ResetPassword), a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /password/reset", a.middlewareStackFunc(a.resetPasswordHandler, csrfMiddleware, authLimit))
+
+		mux.HandleFunc("GET /links", a.middlewareStackFunc(a.dashboardHandler, a.requireUserMiddleware, a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("GET /moderation", a.middlewareStackFunc(a.moderationHandler, a.requireUserMiddleware, a.templateColorMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /moderation/{token}/resolve", a.middlewareStackFunc(a.resolveReportsHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+		mux.HandleFunc("POST /moderation/{token}/restore", a.middlewareStackFunc(a.restoreLinkHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+
+		if a.workspaceService != nil {
+			mux.HandleFunc("GET /workspaces", a.middlewareStackFunc(a.workspacesHandler, a.requireUserMiddleware, a.templateColorMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces", a.middlewareStackFunc(a.createWorkspaceHandler, a.requireUserMiddleware, csrfMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces/switch", a.middlewareStackFunc(a.switchWorkspaceHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+			mux.HandleFunc("GET /workspaces/{id}", a.middlewareStackFunc(a.workspaceHandler, a.requireUserMiddleware, a.templateColorMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces/{id}/members", a.middlewareStackFunc(a.saveMemberHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces/{id}/members/{user}/remove", a.middlewareStackFunc(a.removeMemberHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces/{id}/keys", a.middlewareStackFunc(a.createAPIKeyHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+			mux.HandleFunc("POST /workspaces/{id}/keys/{key}/delete", a.middlewareStackFunc(a.deleteAPIKeyHandler, a.requireUserMiddleware, a.templateColorMiddleware, csrfMiddleware, a.userMiddleware))
+		}
+
+		if a.oidc != nil {
+			mux.HandleFunc("GET /login/sso", a.middlewareStackFunc(a.ssoLoginHandler, authLimit))
+			mux.HandleFunc("GET /login/sso/callback", a.middlewareStackFunc(a.ssoCallbackHandler, authLimit))
+		}
+	}
+
+	mux.Handle("/assets/", http.StripPrefix("/assets/", http.FileServer(web.AssetsFS())))
+
+	return a.middlewareStack(mux, a.recoverMiddleware, a.metricsMiddleware, tracing.Middleware, a.templateAccountsMiddleware, a.templatePathMiddleware, secheaders.Middleware(a.security), a.loggerMiddleware, requestid.Middleware, a.realip.Middleware)
+}
+
+// ShortDomainHandler will return the routes served on the short domains, only their short urls are served there.
+// The other pages are redirected to the base url. The short domains are not under the base url path prefix.
+func (a *App) ShortDomainHandler() http.Handler {
+
+	mux := http.NewServeMux()
+
+	tooManyRequests := a.middlewareStack(http.HandlerFunc(a.tooManyRequestsHandler), a.templateColorMiddleware)
+	redirectLimit := a.limiter.Middleware(a.limits.Redirect, tooManyRequests)
+	notFoundLimit := a.limiter.NotFoundMiddleware(a.limits.NotFound, tooManyRequests)
+
+	mux.HandleFunc("GET /{$}", a.shortDomainHomeHandler)
+	mux.HandleFunc("GET /{token}", a.middlewareStackFunc(a.shortDomainRedirectHandler, a.templateColorMiddleware, notFoundLimit, redirectLimit))
+	mux.HandleFunc("GET /healthz", a.healthzHandler)
+	mux.HandleFunc("GET /readyz", a.readyzHandler)
+	mux.HandleFunc("GET /i/{token}", a.shortDomainInfoHandler)
+	mux.HandleFunc("GET /", a.shortDomainPageHandler)
+	mux.HandleFunc("/", a.middlewareStackFunc(a.notFoundHandler, a.templateColorMiddleware, notFoundLimit))
+	mux.Handle("GET /assets/", http.StripPrefix("/assets/", http.FileServer(web.AssetsFS())))
+
+	// the pages rendered on a short domain load their assets from it, the content security policy only allows its own host
+	return a.middlewareStack(mux, a.recoverMiddleware, a.metricsMiddleware, tracing.Middleware, secheaders.Middleware(a.security), a.loggerMiddleware, requestid.Middleware, a.realip.Middleware)
+}
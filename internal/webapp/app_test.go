@@ -0,0 +1,71 @@
+package webapp_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/geoip"
+	"github.com/griggsjared/getsit/internal/qrcode"
+	urlservice "github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/user"
+	userrepository "github.com/griggsjared/getsit/internal/user/repository"
+	"github.com/griggsjared/getsit/internal/webapp"
+)
+
+// newHandler will create the web app handler with the options, filling in the services a test does not care about
+func newHandler(t *testing.T, opts webapp.Options) http.Handler {
+	t.Helper()
+	if opts.UrlService == nil {
+		opts.UrlService = urlservice.NewService(repository.NewMemUrlEntryRepository())
+	}
+	geo, err := geoip.NewService("")
+	if err != nil {
+		t.Fatalf("geoip.NewService() error = %v", err)
+	}
+	opts.GeoIPService = geo
+	opts.QRCodeService = qrcode.NewService()
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts.SessionSecret = "0123456789abcdef0123456789abcdef"
+	return webapp.New(opts).Handler()
+}
+
+func TestApp_HandlerAccountLinks(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		userService *user.Service
+		want        bool
+	}{
+		{
+			name:        "accounts enabled",
+			userService: user.NewTestService(userrepository.NewMemUserRepository(), nil),
+			want:        true,
+		},
+		{
+			name: "accounts disabled",
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHandler(t, webapp.Options{UserService: tt.userService})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET / status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			body := rec.Body.String()
+			if got := strings.Contains(body, `href="/login"`); got != tt.want {
+				t.Errorf("GET / links to the login page = %v, want %v", got, tt.want)
+			}
+			if got := strings.Contains(body, `href="/register"`); got != tt.want {
+				t.Errorf("GET / links to the register page = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"slices"
+
+	"github.com/a-h/templ"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/user"
+	"github.com/griggsjared/getsit/internal/user/entity"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+const authSessionName string = "auth-session"
+
+// authSessionMaxAge is how long a login lasts, 30 days
+const authSessionMaxAge = 30 * 24 * 60 * 60
+
+type userCtxKey struct{}
+
+// currentUser will return the logged in user, or nil when the request is not logged in
+func currentUser(ctx context.Context) *entity.User {
+	u, _ := ctx.Value(userCtxKey{}).(*entity.User)
+	return u
+}
+
+// currentUserID will return the id of the logged in user, or 0 when the request is not logged in
+func currentUserID(ctx context.Context) int64 {
+	if u := currentUser(ctx); u != nil {
+		return u.ID
+	}
+	return 0
+}
+
+// userMiddleware will load the logged in user from the auth session into the context.
+// The request acts as the user, in the workspace the user switched to when they are still a member of it.
+// A session for a user that no longer exists, or that was started before the password changed, is logged out.
+func (a *App) userMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if a.userService == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session, _ := a.session.Get(r, authSessionName)
+		id, ok := session.Values["user_id"].(int64)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		u, err := a.userService.GetByID(r.Context(), id)
+		if err != nil || session.Values["key"] != u.SessionKey() {
+			if err != nil && !errors.Is(err, user.ErrNotFound) {
+				a.logger.ErrorContext(r.Context(), "failed to load the logged in user", slog.String("error", err.Error()))
+			}
+			a.endAuthSession(w, r)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userCtxKey{}, u)
+		ctx = context.WithValue(ctx, template.UserCtxKey, u.Email.String())
+		admin := a.isAdmin(u)
+		ctx = context.WithValue(ctx, template.AdminCtxKey, admin)
+		ctx = authz.WithPrincipal(ctx, authz.Principal{UserID: u.ID, Admin: admin})
+		if a.workspaceService != nil {
+			ctx = a.withActiveWorkspace(ctx, session)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// isAdmin will return true when the user is one of the admin users.
+// Admins are pinned by id, an email is not proof of who the user is as registering does not verify it.
+func (a *App) isAdmin(u *entity.User) bool {
+	return slices.Contains(a.adminUserIDs, u.ID)
+}
+
+// startAuthSession will log the user in
+func (a *App) startAuthSession(w http.ResponseWriter, r *http.Request, u *entity.User) error {
+	session, _ := a.session.Get(r, authSessionName)
+	session.Options = a.cookieOptions(r, authSessionMaxAge)
+	session.Values["user_id"] = u.ID
+	session.Values["key"] = u.SessionKey()
+	delete(session.Values, "workspace_id")
+	return session.Save(r, w)
+}
+
+// endAuthSession will log the user out by expiring the auth session cookie
+func (a *App) endAuthSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := a.session.Get(r, authSessionName)
+	session.Options = a.cookieOptions(r, -1)
+	session.Values = map[any]any{}
+	session.Save(r, w)
+}
+
+// authPageHandler will render an auth page with the flash message, errors and inputs
+func (a *App) authPageHandler(page func(template.AuthViewModel) templ.Component) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vm := template.AuthViewModel{
+			Message: a.getFlashMessage(w, r),
+			Errors:  a.getFlashErrors(w, r),
+			Inputs:  a.getFlashInputs(w, r),
+			Token:   r.URL.Query().Get("token"),
+		}
+		if a.oidc != nil {
+			vm.SSO = a.oidcName
+		}
+		if err := page(vm).Render(r.Context(), w); err != nil {
+			http.Error(w, "Failed to render the page", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// registerHandler will create a new account and log it in
+func (a *App) registerHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &user.RegisterInput{
+		Email:    r.FormValue("email"),
+		Password: r.FormValue("password"),
+	}
+
+	u, err := a.userService.Register(r.Context(), input)
+	if err != nil {
+		if len(input.ValidationErrors) > 0 {
+			a.setFlashErrors(w, r, input.ValidationErrors)
+		} else {
+			a.logger.ErrorContext(r.Context(), "failed to register", slog.String("error", err.Error()))
+			a.setFlashErrors(w, r, map[string]string{"error": "Failed to create the account"})
+		}
+		a.setFlashInputs(w, r, map[string]string{"email": input.Email})
+		http.Redirect(w, r, a.baseURL.Path("/register"), http.StatusFound)
+		return
+	}
+
+	if err := a.startAuthSession(w, r, u); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	a.setFlashMessage(w, r, "Welcome! Links you create are now saved to your account.")
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
+
+// loginHandler will log in with the email and password
+func (a *App) loginHandler(w http.ResponseWriter, r *http.Request) {
+
+	u, err := a.userService.Authenticate(r.Context(), &user.AuthenticateInput{
+		Email:    r.FormValue("email"),
+		Password: r.FormValue("password"),
+	})
+	if err != nil {
+		if errors.Is(err, user.ErrInvalidCredentials) {
+			a.setFlashErrors(w, r, map[string]string{"error": "The email or password is not correct"})
+		} else {
+			a.logger.ErrorContext(r.Context(), "failed to log in", slog.String("error", err.Error()))
+			a.setFlashErrors(w, r, map[string]string{"error": "Failed to log in"})
+		}
+		a.setFlashInputs(w, r, map[string]string{"email": r.FormValue("email")})
+		http.Redirect(w, r, a.baseURL.Path("/login"), http.StatusFound)
+		return
+	}
+
+	if err := a.startAuthSession(w, r, u); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
+
+// logoutHandler will log out
+func (a *App) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	a.endAuthSession(w, r)
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
+
+// forgotPasswordHandler will email a password reset link.
+// The same message is shown whether or not the email has an account.
+func (a *App) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	err := a.userService.RequestPasswordReset(r.Context(), &user.RequestPasswordResetInput{
+		Email: r.FormValue("email"),
+		LinkFor: func(token string) string {
+			return a.baseURL.For(r) + "/password/reset?token=" + neturl.QueryEscape(token)
+		},
+	})
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to send the password reset", slog.String("error", err.Error()))
+		a.setFlashErrors(w, r, map[string]string{"error": "Failed to send the email, please try again"})
+		a.setFlashInputs(w, r, map[string]string{"email": r.FormValue("email")})
+		http.Redirect(w, r, a.baseURL.Path("/password/forgot"), http.StatusFound)
+		return
+	}
+
+	a.setFlashMessage(w, r, "If there is an account for that email, we sent it a link to choose a new password.")
+	http.Redirect(w, r, a.baseURL.Path("/login"), http.StatusFound)
+}
+
+// resetPasswordHandler will set a new password from a reset link and log the user in
+func (a *App) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &user.ResetPasswordInput{
+		Token:    r.FormValue("token"),
+		Password: r.FormValue("password"),
+	}
+
+	u, err := a.userService.ResetPassword(r.Context(), input)
+	if err != nil {
+		switch {
+		case len(input.ValidationErrors) > 0:
+			a.setFlashErrors(w, r, input.ValidationErrors)
+			http.Redirect(w, r, a.baseURL.Path("/password/reset?token="+neturl.QueryEscape(input.Token)), http.StatusFound)
+		case errors.Is(err, user.ErrInvalidResetToken):
+			a.setFlashErrors(w, r, map[string]string{"error": "The reset link is not valid or has expired, please ask for a new one"})
+			http.Redirect(w, r, a.baseURL.Path("/password/forgot"), http.StatusFound)
+		default:
+			a.logger.ErrorContext(r.Context(), "failed to reset the password", slog.String("error", err.Error()))
+			a.setFlashErrors(w, r, map[string]string{"error": "Failed to reset the password"})
+			http.Redirect(w, r, a.baseURL.Path("/password/forgot"), http.StatusFound)
+		}
+		return
+	}
+
+	if err := a.startAuthSession(w, r, u); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	a.setFlashMessage(w, r, "Your password has been changed.")
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
@@ -0,0 +1,463 @@
+package webapp
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/botdetect"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/qrcode"
+	"github.com/griggsjared/getsit/internal/ratelimit"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/useragent"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// homepageHandler will show the homepage of the application that shows the form to create a new short url
+func (a *App) homepageHandler(w http.ResponseWriter, r *http.Request) {
+
+	baseUrl := a.baseURL.For(r)
+	vm := template.HomepageViewModel{
+		Message:  a.getFlashMessage(w, r),
+		Errors:   a.getFlashErrors(w, r),
+		Inputs:   a.getFlashInputs(w, r),
+		BaseHost: baseUrl[strings.Index(baseUrl, "://")+3:],
+		Domains:  a.domains,
+	}
+
+	if history := a.getHistory(r); len(history) > 0 {
+		vm.Recent = a.historyLinks(r, history[:min(len(history), historyRecent)])
+	}
+
+	if a.powService.Enabled() {
+		challenge, err := a.powService.NewChallenge()
+		if err != nil {
+			http.Error(w, "Failed to create the anti-spam challenge", http.StatusInternalServerError)
+			return
+		}
+		vm.PoWChallenge = challenge.Token
+		vm.PoWDifficulty = challenge.Difficulty
+	}
+
+	err := template.Homepage(vm).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render homepage", http.StatusInternalServerError)
+		return
+	}
+}
+
+// createHandler will create a new short url from the long url
+// The long url is sent as a POST request to /create
+// if successful, we will redirect to /i/{token} to show the information about the url entry
+// and the secret manage token that is shown only once.
+// Links created while logged out are added to the browser's history with their manage token.
+// The domain form value picks the short domain the link is created on, empty is the base url.
+// When the proof of work check is enabled the solved challenge is verified before anything else.
+func (a *App) createHandler(w http.ResponseWriter, r *http.Request) {
+
+	if a.powService.Enabled() {
+		if err := a.powService.Verify(r.FormValue("pow_challenge"), r.FormValue("pow_nonce")); err != nil {
+			a.logger.InfoContext(r.Context(), "proof of work rejected", slog.String("error", err.Error()))
+			a.setFlashErrors(w, r, map[string]string{"error": "The anti-spam check failed, please try again"})
+			a.setFlashInputs(w, r, map[string]string{"url": r.FormValue("url"), "domain": r.FormValue("domain")})
+			http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+			return
+		}
+	}
+
+	if exists, _ := a.urlService.GetUrlByUrl(r.Context(), &url.GetUrlByUrlInput{
+		Url:    r.FormValue("url"),
+		Domain: r.FormValue("domain"),
+	}); exists != nil {
+		if key := a.historyKey(r, exists.Token.String(), exists.Domain); key != "" {
+			a.addToHistory(w, r, exists.Token.String(), exists.Domain, key)
+		}
+		http.Redirect(w, r, a.baseURL.Path(template.LinkPath("/i/"+exists.Token.String(), exists.Domain)), http.StatusMovedPermanently)
+		return
+	}
+
+	input := &url.SaveUrlInput{
+		Url:    r.FormValue("url"),
+		Domain: r.FormValue("domain"),
+	}
+
+	entry, err := a.urlService.SaveUrl(r.Context(), input)
+	if err != nil {
+		if len(input.ValidationErrors) > 0 {
+			a.setFlashErrors(w, r, input.ValidationErrors)
+		} else if errors.Is(err, url.ErrForbidden) {
+			a.setFlashErrors(w, r, map[string]string{"error": "Viewers can not shorten links in the workspace, switch to your personal links"})
+		} else {
+			a.setFlashErrors(w, r, map[string]string{"error": "Failed to save url"})
+		}
+		a.setFlashInputs(w, r, map[string]string{"url": r.FormValue("url"), "domain": r.FormValue("domain")})
+		http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+		return
+	}
+
+	if a.powService.Enabled() {
+		a.powService.RecordCreation()
+	}
+
+	if entry.OwnerID == 0 {
+		a.addToHistory(w, r, entry.Token.String(), entry.Domain, input.ManageToken)
+	}
+	a.setFlashManageToken(w, r, input.ManageToken)
+
+	http.Redirect(w, r, a.baseURL.Path(template.LinkPath("/i/"+entry.Token.String(), entry.Domain)), http.StatusMovedPermanently)
+}
+
+// redirectHandler will redirect to the long url from the short url on the base url
+func (a *App) redirectHandler(w http.ResponseWriter, r *http.Request) {
+	a.redirect(w, r, &entity.Domain{})
+}
+
+// shortDomainRedirectHandler will redirect to the long url from the short url on the short domain the request arrived on
+func (a *App) shortDomainRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	domain, err := a.urlService.GetDomain(r.Context(), realip.FromRequest(r).Host)
+	if err != nil {
+		a.notFoundHandler(w, r)
+		return
+	}
+	a.redirect(w, r, domain)
+}
+
+// shortDomainHomeHandler will send the root of a short domain to its fallback url, or to the homepage on the base url
+func (a *App) shortDomainHomeHandler(w http.ResponseWriter, r *http.Request) {
+	domain, err := a.urlService.GetDomain(r.Context(), realip.FromRequest(r).Host)
+	if err == nil && domain.FallbackUrl != "" {
+		http.Redirect(w, r, domain.FallbackUrl.String(), http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, a.baseURL.For(r)+"/", http.StatusFound)
+}
+
+// shortDomainInfoHandler will send the info page of a short url on a short domain to the info page on the base url
+func (a *App) shortDomainInfoHandler(w http.ResponseWriter, r *http.Request) {
+	host := entity.NormalizeHost(realip.FromRequest(r).Host)
+	http.Redirect(w, r, a.baseURL.For(r)+template.LinkPath("/i/"+r.PathValue("token"), host), http.StatusFound)
+}
+
+// shortDomainPageHandler will send the other pages of a short domain, like the links of its not found page, to the base url
+func (a *App) shortDomainPageHandler(w http.ResponseWriter, r *http.Request) {
+	target := a.baseURL.For(r) + r.URL.Path
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// redirect will redirect to the long url from the token of the short url on the domain
+// if successful, we record the visit and redirect to the long url. Links that were stopped get a page saying why,
+// 410 for deleted links, 451 for links taken down and a neutral page for links disabled by their owner.
+// Unknown tokens on a domain with a fallback url are redirected there instead.
+func (a *App) redirect(w http.ResponseWriter, r *http.Request, domain *entity.Domain) {
+
+	entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{
+		Token:  r.PathValue("token"),
+		Domain: domain.Host,
+	})
+	switch {
+	case errors.Is(err, url.ErrDeleted):
+		metrics.Redirects.WithLabelValues("deleted").Inc()
+		a.goneHandler(w, r)
+		return
+	case err == nil && entry.TakenDown():
+		metrics.Redirects.WithLabelValues("taken_down").Inc()
+		a.takenDownHandler(w, r, entry)
+		return
+	case err == nil && !entry.Active():
+		metrics.Redirects.WithLabelValues("disabled").Inc()
+		a.disabledHandler(w, r)
+		return
+	}
+	if err != nil {
+		if domain.FallbackUrl != "" {
+			metrics.Redirects.WithLabelValues("fallback").Inc()
+			http.Redirect(w, r, domain.FallbackUrl.String(), http.StatusFound)
+			return
+		}
+		metrics.Redirects.WithLabelValues("not_found").Inc()
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	isBot := botdetect.IsBot(r)
+	ua := useragent.Parse(r.UserAgent())
+	loc := a.geoipService.Lookup(realip.FromRequest(r).IP)
+
+	err = a.urlService.VisitUrlByToken(r.Context(), &url.VisitUrlByTokenInput{
+		Token:          entry.Token.String(),
+		Domain:         entry.Domain,
+		IsBot:          isBot,
+		BrowserFamily:  ua.BrowserFamily,
+		BrowserVersion: ua.BrowserVersion,
+		OS:             ua.OS,
+		DeviceClass:    string(ua.DeviceClass),
+		Country:        loc.Country,
+		Region:         loc.Region,
+		City:           loc.City,
+	})
+	if err != nil {
+		fmt.Fprintln(w, "Error saving visit")
+		return
+	}
+
+	if isBot {
+		metrics.Redirects.WithLabelValues("bot").Inc()
+	} else {
+		metrics.Redirects.WithLabelValues("human").Inc()
+	}
+
+	http.Redirect(w, r, entry.Url.String(), http.StatusFound)
+}
+
+// infoHandler will show the information about the url entry
+// The token is sent as a GET request to /i/{token}, with the host of its short domain in the domain query parameter
+// if successful, we will show the url, token, and the number of times the url has been visited.
+// The visits query parameter can be set to "human" to exclude visits classified as bots.
+// Only the owner, or a browser with the manage token in the key query parameter or its history, can see the
+// information, everyone else is shown a preview of where the link goes.
+func (a *App) infoHandler(w http.ResponseWriter, r *http.Request) {
+
+	entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{
+		Token:  r.PathValue("token"),
+		Domain: r.FormValue("domain"),
+	})
+	if err != nil {
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	// viewers of the link's workspace can see the information but not change the link
+	key := a.manageKey(r, entry.Token.String(), entry.Domain)
+	canManage := a.urlService.CanManage(r.Context(), entry, key)
+	inWorkspace := authz.FromContext(r.Context()).InWorkspace(entry.WorkspaceID)
+	if !canManage && !inWorkspace {
+		a.previewHandler(w, r, entry)
+		return
+	}
+
+	// a browser that opens the management link can manage the link from then on
+	if r.URL.Query().Get("key") != "" && a.historyKey(r, entry.Token.String(), entry.Domain) != key {
+		a.addToHistory(w, r, entry.Token.String(), entry.Domain, key)
+	}
+
+	workspace := ""
+	if inWorkspace {
+		workspace = workspaceName(r.Context())
+	}
+
+	shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+
+	manageUrl := ""
+	if manageToken := a.getFlashManageToken(w, r); manageToken != "" {
+		manageUrl = a.baseURL.For(r) + template.LinkPath("/i/"+entry.Token.String()+"?key="+neturl.QueryEscape(manageToken), entry.Domain)
+	}
+
+	qr, err := a.qrcodeService.Generate(r.Context(), &qrcode.GenerateInput{
+		Content: shortUrl,
+		Size:    256,
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := a.linkRevisions(r, entry, key)
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the revisions of the link", slog.String("error", err.Error()))
+		a.serverErrorHandler(w, r)
+		return
+	}
+
+	humanVisitsOnly := r.URL.Query().Get("visits") == "human"
+	visitCount := entry.VisitCount
+	if humanVisitsOnly {
+		visitCount = entry.HumanVisitCount()
+	}
+
+	err = template.Info(template.InfoViewModel{
+		Message:           a.getFlashMessage(w, r),
+		Errors:            a.getFlashErrors(w, r),
+		ShortUrl:          shortUrl[strings.Index(shortUrl, "://")+3:],
+		ShortUrlWithProto: shortUrl,
+		Url:               entry.Url.String(),
+		Token:             entry.Token.String(),
+		Domain:            entry.Domain,
+		VisitCount:        visitCount,
+		HumanVisitsOnly:   humanVisitsOnly,
+		QRCode:            qr.Base64(),
+		Owned:             entry.WorkspaceID == 0 && entry.OwnerID != 0 && entry.OwnerID == currentUserID(r.Context()),
+		CreatedByBrowser:  entry.OwnerID == 0 && a.historyKey(r, entry.Token.String(), entry.Domain) != "",
+		Active:            entry.Active(),
+		Held:              entry.Held(),
+		TakedownReason:    entry.TakedownReason,
+		ManageUrl:         manageUrl,
+		Workspace:         workspace,
+		ReadOnly:          !canManage || entry.TakenDown(),
+		Revisions:         revisions,
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render information page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// previewHandler will show where the link goes without any of its information, disabled links are not found
+// and links that were taken down only show why
+func (a *App) previewHandler(w http.ResponseWriter, r *http.Request, entry *entity.UrlEntry) {
+
+	if entry.TakenDown() {
+		a.takenDownHandler(w, r, entry)
+		return
+	}
+	if !entry.Active() {
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+
+	err := template.Preview(template.PreviewViewModel{
+		Token:             entry.Token.String(),
+		Domain:            entry.Domain,
+		ShortUrl:          shortUrl[strings.Index(shortUrl, "://")+3:],
+		ShortUrlWithProto: shortUrl,
+		Url:               entry.Url.String(),
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the preview page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// notFoundHandler will show a 404 error message
+// this is the default handler for when a route is not found and
+// can be used to show return a 404 status from within other handlers
+func (a *App) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusNotFound,
+		Msg:  "404: Page not found",
+		Desc: "Sorry we could not find what you were looking for.",
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render 404 page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// goneHandler will show a 410 error message
+// this is the handler for the links their owner deleted
+func (a *App) goneHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusGone)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusGone,
+		Msg:  "410: Link deleted",
+		Desc: "This link was deleted by its owner and no longer goes anywhere.",
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the deleted link page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// takenDownHandler will show a 451 error message with the reason the link was taken down
+func (a *App) takenDownHandler(w http.ResponseWriter, r *http.Request, entry *entity.UrlEntry) {
+	w.WriteHeader(http.StatusUnavailableForLegalReasons)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusUnavailableForLegalReasons,
+		Msg:  "451: Link taken down",
+		Desc: "This link was taken down: " + entry.TakedownReason,
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the taken down link page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// disabledHandler will show that the link is turned off without saying where it goes
+func (a *App) disabledHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNotFound)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusNotFound,
+		Msg:  "Link disabled",
+		Desc: "This link is turned off for now, check back later or ask whoever shared it.",
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the disabled link page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// forbiddenHandler will show a 403 error message
+// this is the handler for when a request is denied by CSRF protection
+func (a *App) forbiddenHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusForbidden)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusNotFound,
+		Msg:  "Invalid Request",
+		Desc: "Sorry, the request was invalid. Please go back and try again.",
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the token mismatch page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// tooManyRequestsHandler will show a 429 error message
+// this is the handler for when a request is denied by a rate limit, the Retry-After header is already set
+func (a *App) tooManyRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusTooManyRequests)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusTooManyRequests,
+		Msg:  "429: Too many requests",
+		Desc: fmt.Sprintf("Slow down a little, please try again in %s.", ratelimit.RetryAfter(w)),
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the too many requests page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// serverErrorHandler will show a 500 error message
+// this is the handler for when a handler panics
+func (a *App) serverErrorHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusInternalServerError)
+	err := template.ServerError(template.ServerErrorViewModel{
+		Code: http.StatusInternalServerError,
+		Msg:  "500: Something went wrong",
+		Desc: "Sorry, something went wrong on our end. Please try again later.",
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the server error page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// healthzHandler is the handler for the healthz path of the web application.
+func (a *App) healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// readyzHandler is the handler for the readyz path of the web application.
+// It will return a 503 when the database is unreachable, migrations are pending or the server is draining.
+func (a *App) readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if err := a.health.Ready(r.Context()); err != nil {
+		a.logger.WarnContext(r.Context(), "not ready", slog.String("error", err.Error()))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("not ready"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,111 @@
+package webapp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	urlservice "github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/webapp"
+)
+
+func TestApp_HandlerRedirect(t *testing.T) {
+
+	ctx := context.Background()
+	admin := authz.WithPrincipal(ctx, authz.Principal{Admin: true})
+	s := urlservice.NewService(repository.NewMemUrlEntryRepository())
+
+	save := func(u string) (string, string) {
+		t.Helper()
+		input := &urlservice.SaveUrlInput{Url: u}
+		entry, err := s.SaveUrl(ctx, input)
+		if err != nil {
+			t.Fatalf("SaveUrl() error = %v", err)
+		}
+		return entry.Token.String(), input.ManageToken
+	}
+
+	active, _ := save("https://active.com")
+
+	deleted, key := save("https://deleted.com")
+	if err := s.DeleteUrl(ctx, &urlservice.DeleteUrlInput{Token: deleted, ManageToken: key}); err != nil {
+		t.Fatalf("DeleteUrl() error = %v", err)
+	}
+
+	disabled, key := save("https://disabled.com")
+	if err := s.SetUrlStatus(ctx, &urlservice.SetUrlStatusInput{Token: disabled, ManageToken: key, Status: entity.UrlStatusDisabled}); err != nil {
+		t.Fatalf("SetUrlStatus() error = %v", err)
+	}
+
+	takenDown, _ := save("https://taken-down.com")
+	if err := s.TakeDownUrl(admin, &urlservice.TakeDownUrlInput{Token: takenDown, Reason: "Court order 123"}); err != nil {
+		t.Fatalf("TakeDownUrl() error = %v", err)
+	}
+
+	h := newHandler(t, webapp.Options{UrlService: s})
+
+	tests := []struct {
+		name         string
+		path         string
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{
+			name:         "active",
+			path:         "/" + active,
+			wantStatus:   http.StatusFound,
+			wantLocation: "https://active.com",
+		},
+		{
+			name:       "deleted",
+			path:       "/" + deleted,
+			wantStatus: http.StatusGone,
+			wantBody:   "410: Link deleted",
+		},
+		{
+			name:       "taken down",
+			path:       "/" + takenDown,
+			wantStatus: http.StatusUnavailableForLegalReasons,
+			wantBody:   "Court order 123",
+		},
+		{
+			name:       "taken down info page",
+			path:       "/i/" + takenDown,
+			wantStatus: http.StatusUnavailableForLegalReasons,
+			wantBody:   "Court order 123",
+		},
+		{
+			name:       "disabled",
+			path:       "/" + disabled,
+			wantStatus: http.StatusNotFound,
+			wantBody:   "Link disabled",
+		},
+		{
+			name:       "unknown token",
+			path:       "/Unknown1",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404: Page not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GET %s status = %d, want %d", tt.path, rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("GET %s location = %q, want %q", tt.path, got, tt.wantLocation)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET %s body does not contain %q", tt.path, tt.wantBody)
+			}
+		})
+	}
+}
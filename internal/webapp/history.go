@@ -0,0 +1,136 @@
+package webapp
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// historySessionName is the signed session of the links the browser created while logged out
+const historySessionName string = "history-session"
+
+// historyEntry is a link the browser created and the secret manage token that lets the browser manage it
+type historyEntry struct {
+	Token  string `json:"t"`
+	Domain string `json:"d,omitempty"` // The host of the short domain of the link, empty for the base url
+	Key    string `json:"k"`
+}
+
+const (
+	historyLimit  = 20                 // How many of the most recent tokens are kept
+	historyRecent = 5                  // How many are shown on the homepage
+	historyMaxAge = 365 * 24 * 60 * 60 // How long the history lasts without creating another link, 1 year
+)
+
+// getHistory will return the links the browser created, newest first
+func (a *App) getHistory(r *http.Request) []historyEntry {
+	session, _ := a.historyStore.Get(r, historySessionName)
+	raw, ok := session.Values["links"].(string)
+	if !ok {
+		return nil
+	}
+	var history []historyEntry
+	if err := json.Unmarshal([]byte(raw), &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// saveHistory will replace the links the browser created, an empty history removes the cookie
+func (a *App) saveHistory(w http.ResponseWriter, r *http.Request, history []historyEntry) {
+	session, _ := a.historyStore.Get(r, historySessionName)
+	if len(history) == 0 {
+		session.Options = a.cookieOptions(r, -1)
+		session.Values = map[any]any{}
+		session.Save(r, w)
+		return
+	}
+	raw, err := json.Marshal(history)
+	if err != nil {
+		return
+	}
+	session.Options = a.cookieOptions(r, historyMaxAge)
+	session.Values["links"] = string(raw)
+	session.Save(r, w)
+}
+
+// addToHistory will remember the link and its manage token, the oldest link is forgotten past the limit
+func (a *App) addToHistory(w http.ResponseWriter, r *http.Request, token string, domain string, key string) {
+	history := removeFromHistory(a.getHistory(r), token, domain)
+	history = slices.Insert(history, 0, historyEntry{Token: token, Domain: domain, Key: key})
+	a.saveHistory(w, r, history[:min(len(history), historyLimit)])
+}
+
+// removeFromHistory will return the history without the token on the domain
+func removeFromHistory(history []historyEntry, token string, domain string) []historyEntry {
+	return slices.DeleteFunc(history, func(e historyEntry) bool { return e.Token == token && e.Domain == domain })
+}
+
+// historyKey will return the manage token the browser has for the token on the domain, or an empty string when the browser did not create it
+func (a *App) historyKey(r *http.Request, token string, domain string) string {
+	for _, e := range a.getHistory(r) {
+		if e.Token == token && e.Domain == domain {
+			return e.Key
+		}
+	}
+	return ""
+}
+
+// historyLinks will look up the entries of the history, links that no longer exist are skipped
+func (a *App) historyLinks(r *http.Request, history []historyEntry) []template.HistoryLink {
+	var links []template.HistoryLink
+	for _, e := range history {
+		entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{Token: e.Token, Domain: e.Domain})
+		if err != nil || !entry.CanManage(0, e.Key) {
+			continue
+		}
+		links = append(links, a.historyLink(r, entry))
+	}
+	return links
+}
+
+// historyLink will build the history row of the entry
+func (a *App) historyLink(r *http.Request, entry *entity.UrlEntry) template.HistoryLink {
+	shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+	return template.HistoryLink{
+		Token:             entry.Token.String(),
+		Domain:            entry.Domain,
+		ShortUrl:          shortUrl[strings.Index(shortUrl, "://")+3:],
+		ShortUrlWithProto: shortUrl,
+		Url:               entry.Url.String(),
+		VisitCount:        entry.VisitCount,
+	}
+}
+
+// historyHandler will show the links the browser created while logged out
+func (a *App) historyHandler(w http.ResponseWriter, r *http.Request) {
+
+	vm := template.HistoryViewModel{
+		Message: a.getFlashMessage(w, r),
+		Links:   a.historyLinks(r, a.getHistory(r)),
+	}
+
+	if err := template.History(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the history page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// forgetHistoryHandler will remove a token from the history, the link itself keeps working
+func (a *App) forgetHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	a.saveHistory(w, r, removeFromHistory(a.getHistory(r), r.PathValue("token"), r.FormValue("domain")))
+	a.setFlashMessage(w, r, "The link has been removed from this browser's history.")
+	http.Redirect(w, r, a.baseURL.Path("/history"), http.StatusFound)
+}
+
+// clearHistoryHandler will remove every token from the history
+func (a *App) clearHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	a.saveHistory(w, r, nil)
+	a.setFlashMessage(w, r, "This browser's history has been cleared.")
+	http.Redirect(w, r, a.baseURL.Path("/history"), http.StatusFound)
+}
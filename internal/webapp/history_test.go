@@ -0,0 +1,69 @@
+package webapp_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	urlservice "github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/webapp"
+)
+
+// signedCookie will sign the session values the way the session store does, as if the cookie was saved age ago
+func signedCookie(t *testing.T, name string, values map[any]any, age time.Duration) *http.Cookie {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(values); err != nil {
+		t.Fatalf("failed to encode the session values: %v", err)
+	}
+	b := fmt.Sprintf("%s|%d|%s|", name, time.Now().Add(-age).Unix(), base64.URLEncoding.EncodeToString(buf.Bytes()))
+	mac := hmac.New(sha256.New, []byte("0123456789abcdef0123456789abcdef"))
+	mac.Write([]byte(b[:len(b)-1]))
+	value := b[len(name)+1:] + string(mac.Sum(nil))
+	return &http.Cookie{Name: name, Value: base64.URLEncoding.EncodeToString([]byte(value))}
+}
+
+func TestApp_HandlerHistoryAge(t *testing.T) {
+
+	s := urlservice.NewService(repository.NewMemUrlEntryRepository())
+	input := &urlservice.SaveUrlInput{Url: "https://history.com"}
+	entry, err := s.SaveUrl(context.Background(), input)
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	links, _ := json.Marshal([]map[string]string{{"t": entry.Token.String(), "k": input.ManageToken}})
+
+	h := newHandler(t, webapp.Options{UrlService: s})
+
+	tests := []struct {
+		name string
+		age  time.Duration
+		want bool
+	}{
+		{name: "saved today", age: time.Hour, want: true},
+		{name: "saved more than 30 days ago", age: 45 * 24 * time.Hour, want: true},
+		{name: "saved more than a year ago", age: 400 * 24 * time.Hour, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookie := signedCookie(t, "history-session", map[any]any{"links": string(links)}, tt.age)
+			rec := get(h, "/history", []*http.Cookie{cookie})
+			if rec.Code != http.StatusOK {
+				t.Fatalf("GET /history status = %d, want %d", rec.Code, http.StatusOK)
+			}
+			if got := strings.Contains(rec.Body.String(), "https://history.com"); got != tt.want {
+				t.Errorf("GET /history lists the link = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
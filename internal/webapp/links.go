@@ -0,0 +1,298 @@
+package webapp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// requireUserMiddleware will send requests that are not logged in to the login page.
+// It has to run after the userMiddleware has loaded the user.
+func (a *App) requireUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentUser(r.Context()) == nil {
+			http.Redirect(w, r, a.baseURL.Path("/login"), http.StatusFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dashboardHandler will show the links of the logged in user, or of the workspace they switched to
+// The q, sort and page query parameters search, order and page through the links.
+func (a *App) dashboardHandler(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	page, _ := strconv.Atoi(query.Get("page"))
+
+	result, err := a.urlService.ListUrls(r.Context(), &url.ListUrlsInput{
+		Search: query.Get("q"),
+		Sort:   query.Get("sort"),
+		Page:   page,
+	})
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the links", slog.String("error", err.Error()))
+		a.serverErrorHandler(w, r)
+		return
+	}
+
+	p := authz.FromContext(r.Context())
+	vm := template.DashboardViewModel{
+		Message: a.getFlashMessage(w, r),
+		Errors:  a.getFlashErrors(w, r),
+		Search:  query.Get("q"),
+		Sort:    string(result.Sort),
+		Page:    result.Page,
+		Pages:   result.Pages(),
+		Total:   result.Total,
+
+		Workspace: workspaceName(r.Context()),
+		ReadOnly:  p.WorkspaceID != 0 && !p.Role.CanEdit(),
+	}
+	vm.Return = "/links"
+	if r.URL.RawQuery != "" {
+		vm.Return += "?" + r.URL.RawQuery
+	}
+	for _, entry := range result.Entries {
+		shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+		vm.Links = append(vm.Links, template.DashboardLink{
+			Token:             entry.Token.String(),
+			Domain:            entry.Domain,
+			ShortUrl:          shortUrl[strings.Index(shortUrl, "://")+3:],
+			ShortUrlWithProto: shortUrl,
+			Url:               entry.Url.String(),
+			VisitCount:        entry.VisitCount,
+			CreatedAt:         entry.CreatedAt,
+			Active:            entry.Active(),
+			Held:              entry.Held(),
+			TakenDown:         entry.TakenDown(),
+		})
+	}
+
+	if err := template.Dashboard(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the dashboard", http.StatusInternalServerError)
+		return
+	}
+}
+
+// manageKey will return the manage token sent with the request in the key parameter, or the one in the browser's history
+func (a *App) manageKey(r *http.Request, token string, domain string) string {
+	if key := r.FormValue("key"); key != "" {
+		return key
+	}
+	return a.historyKey(r, token, domain)
+}
+
+// editLinkPageHandler will show the form to change where a link goes, to whoever can manage it.
+// A link that was taken down can not be changed.
+func (a *App) editLinkPageHandler(w http.ResponseWriter, r *http.Request) {
+
+	entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{
+		Token:  r.PathValue("token"),
+		Domain: r.FormValue("domain"),
+	})
+	if err != nil || entry.TakenDown() || !a.urlService.CanManage(r.Context(), entry, a.manageKey(r, entry.Token.String(), entry.Domain)) {
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+
+	// a rejected url is shown again so it can be fixed
+	destination := entry.Url.String()
+	if u, ok := a.getFlashInputs(w, r)["url"]; ok {
+		destination = u
+	}
+
+	err = template.EditLink(template.EditLinkViewModel{
+		Errors:   a.getFlashErrors(w, r),
+		Token:    entry.Token.String(),
+		Domain:   entry.Domain,
+		Key:      r.FormValue("key"),
+		ShortUrl: shortUrl[strings.Index(shortUrl, "://")+3:],
+		Url:      destination,
+		Back:     a.linkReturnPath(r, entry.Token.String()),
+	}).Render(r.Context(), w)
+	if err != nil {
+		http.Error(w, "Failed to render the edit page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// editLinkHandler will change where a link goes, the short url stays the same
+func (a *App) editLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.UpdateUrlInput{
+		Token:       r.PathValue("token"),
+		Domain:      r.FormValue("domain"),
+		ManageToken: a.manageKey(r, r.PathValue("token"), r.FormValue("domain")),
+		Url:         r.FormValue("url"),
+	}
+
+	if _, err := a.urlService.UpdateUrl(r.Context(), input); err != nil {
+		if a.linkActionFailed(w, r, input.ValidationErrors, err) {
+			return
+		}
+		a.setFlashErrors(w, r, input.ValidationErrors)
+		a.setFlashInputs(w, r, map[string]string{"url": input.Url})
+		edit := "/links/" + input.Token + "/edit"
+		if key := r.FormValue("key"); key != "" {
+			edit += "?key=" + neturl.QueryEscape(key)
+		}
+		http.Redirect(w, r, a.baseURL.Path(template.LinkPath(edit, input.Domain)), http.StatusFound)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The link has been updated.")
+	http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, input.Token)), http.StatusFound)
+}
+
+// revertLinkHandler will send a link back to the url it went to before the revision in the revision form value
+func (a *App) revertLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.FormValue("revision"), 10, 64)
+	input := &url.RevertUrlInput{
+		Token:       r.PathValue("token"),
+		Domain:      r.FormValue("domain"),
+		ManageToken: a.manageKey(r, r.PathValue("token"), r.FormValue("domain")),
+		RevisionID:  id,
+	}
+
+	if _, err := a.urlService.RevertUrl(r.Context(), input); err != nil {
+		if a.linkActionFailed(w, r, input.ValidationErrors, err) {
+			return
+		}
+		a.setFlashErrors(w, r, input.ValidationErrors)
+		http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, input.Token)), http.StatusFound)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The link has been reverted.")
+	http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, input.Token)), http.StatusFound)
+}
+
+// linkRevisions will return the history of the link for the info page, the user that is logged in sees their own changes as theirs.
+// Other users are only named to the workspace of the link, see url.Service.ListUrlRevisions.
+func (a *App) linkRevisions(r *http.Request, entry *entity.UrlEntry, key string) ([]template.LinkRevision, error) {
+
+	revisions, err := a.urlService.ListUrlRevisions(r.Context(), &url.ListUrlRevisionsInput{
+		Token:       entry.Token.String(),
+		Domain:      entry.Domain,
+		ManageToken: key,
+	})
+	if errors.Is(err, url.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []template.LinkRevision
+	for _, revision := range revisions {
+		by := "the management link"
+		switch {
+		case revision.UserID != 0 && revision.UserID == currentUserID(r.Context()):
+			by = "you"
+		case revision.UserEmail != "":
+			by = revision.UserEmail
+		case revision.UserID != 0, revision.UserHidden:
+			by = "a user"
+		case revision.APIKeyID != 0:
+			by = "an api key"
+		}
+		items = append(items, template.LinkRevision{
+			ID:        revision.ID,
+			OldUrl:    revision.OldUrl.String(),
+			NewUrl:    revision.NewUrl.String(),
+			By:        by,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+	return items, nil
+}
+
+// setLinkStatusHandler will enable or disable a link, a disabled link no longer redirects
+func (a *App) setLinkStatusHandler(status entity.UrlStatus, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		input := &url.SetUrlStatusInput{
+			Token:       r.PathValue("token"),
+			Domain:      r.FormValue("domain"),
+			ManageToken: a.manageKey(r, r.PathValue("token"), r.FormValue("domain")),
+			Status:      status,
+		}
+
+		if err := a.urlService.SetUrlStatus(r.Context(), input); err != nil {
+			a.linkActionFailed(w, r, input.ValidationErrors, err)
+			return
+		}
+
+		a.setFlashMessage(w, r, message)
+		http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, input.Token)), http.StatusFound)
+	}
+}
+
+// deleteLinkHandler will delete a link, its token keeps showing that the link was deleted
+func (a *App) deleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.DeleteUrlInput{
+		Token:       r.PathValue("token"),
+		Domain:      r.FormValue("domain"),
+		ManageToken: a.manageKey(r, r.PathValue("token"), r.FormValue("domain")),
+	}
+
+	if err := a.urlService.DeleteUrl(r.Context(), input); err != nil {
+		a.linkActionFailed(w, r, input.ValidationErrors, err)
+		return
+	}
+
+	a.saveHistory(w, r, removeFromHistory(a.getHistory(r), input.Token, input.Domain))
+
+	a.setFlashMessage(w, r, "The link has been deleted.")
+	to := a.linkReturnPath(r, input.Token)
+	if strings.HasPrefix(to, "/i/") {
+		to = "/"
+	}
+	http.Redirect(w, r, a.baseURL.Path(to), http.StatusFound)
+}
+
+// linkActionFailed will respond to a failed action on a link and return true, unless the failure
+// is a validation error of the url the caller should show on its own form.
+func (a *App) linkActionFailed(w http.ResponseWriter, r *http.Request, validationErrors map[string]string, err error) bool {
+	switch {
+	case errors.Is(err, url.ErrNotFound), validationErrors["token"] != "", validationErrors["domain"] != "", validationErrors["revision"] != "":
+		a.notFoundHandler(w, r)
+	case errors.Is(err, url.ErrValidation) && validationErrors["status"] != "":
+		a.setFlashErrors(w, r, map[string]string{"status": validationErrors["status"]})
+		http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, r.PathValue("token"))), http.StatusFound)
+	case errors.Is(err, url.ErrValidation) && validationErrors["url"] != "":
+		return false
+	case errors.Is(err, url.ErrForbidden):
+		a.setFlashErrors(w, r, map[string]string{"error": "Viewers can not change the links of the workspace"})
+		http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, r.PathValue("token"))), http.StatusFound)
+	default:
+		if !errors.Is(err, url.ErrValidation) {
+			a.logger.ErrorContext(r.Context(), "failed to change the link", slog.String("error", err.Error()))
+		}
+		a.setFlashErrors(w, r, map[string]string{"error": "Failed to change the link"})
+		http.Redirect(w, r, a.baseURL.Path(a.linkReturnPath(r, r.PathValue("token"))), http.StatusFound)
+	}
+	return true
+}
+
+// linkReturnPath will return the page to go back to after an action on a link.
+// The return parameter can pick the dashboard with its query, otherwise it is the info page of the link on the domain parameter.
+func (a *App) linkReturnPath(r *http.Request, token string) string {
+	if to := r.FormValue("return"); to == "/links" || strings.HasPrefix(to, "/links?") {
+		return to
+	}
+	return template.LinkPath("/i/"+token, r.FormValue("domain"))
+}
@@ -0,0 +1,98 @@
+package webapp
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/httpwriter"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// middleware is a type that wraps an http.Handler and returns a new http.Handler
+type middleware func(http.Handler) http.Handler
+
+// middlewareStack will take a handler and a list of middlewares and return a new handler
+func (a *App) middlewareStack(h http.Handler, middlewares ...middleware) http.Handler {
+	for _, middleware := range middlewares {
+		h = middleware(h)
+	}
+	return h
+}
+
+// middlewareStackFunc will take a handler and a list of middlewares and return a new handler.
+// This is a convenience function that wraps middlewareStack to work with http.HandlerFunc
+func (a *App) middlewareStackFunc(h http.HandlerFunc, middleware ...middleware) http.HandlerFunc {
+	return a.middlewareStack(h, middleware...).ServeHTTP
+}
+
+// recoverMiddleware will recover from a panic in a handler, log it with the stack trace and show the server error page.
+// http.ErrAbortHandler is passed on so the server can abort the response as intended.
+func (a *App) recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := httpwriter.NewRecorder(w)
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			a.logger.ErrorContext(r.Context(), "panic", slog.Any("panic", v), slog.String("path", r.URL.Path), slog.String("stack", string(debug.Stack())))
+			if !rw.Started {
+				a.serverErrorHandler(w, r)
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// loggerMiddleware records the and method of the incoming request and the time taken to process the request
+func (a *App) loggerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		a.logger.InfoContext(r.Context(), "request", slog.String("ip", realip.FromRequest(r).IP), slog.String("path", r.URL.Path), slog.String("method", r.Method), slog.Duration("duration", time.Since(start)))
+	})
+}
+
+// templateColorMiddleware will set the color-mode context value based on the color-mode cookie
+func (a *App) templateColorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := "dark"
+		if colorModeCookie, err := r.Cookie("color-mode"); err == nil {
+			if colorModeCookie.Value == "light" {
+				mode = "light"
+			}
+		}
+		ctx := context.WithValue(r.Context(), template.ColorModeCtxKey, mode)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// templatePathMiddleware will set the path prefix context value so templates link under the base url path
+func (a *App) templatePathMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), template.PathPrefixCtxKey, a.baseURL.Prefix())
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// templateAccountsMiddleware will set whether accounts are enabled so templates only link to the login and register pages when they exist
+func (a *App) templateAccountsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), template.AccountsCtxKey, a.userService != nil)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// metricsMiddleware records the request count and latency by route pattern and status.
+// This should wrap the mux so the matched route pattern is available after the request is served
+func (a *App) metricsMiddleware(next http.Handler) http.Handler {
+	return metrics.InstrumentHandler("getsit-web", next)
+}
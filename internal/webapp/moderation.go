@@ -0,0 +1,230 @@
+package webapp
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/griggsjared/getsit/internal/realip"
+	"github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// reportLinkPageHandler will show the form to report abuse of an active link, to anyone
+func (a *App) reportLinkPageHandler(w http.ResponseWriter, r *http.Request) {
+
+	entry, err := a.urlService.GetUrlByToken(r.Context(), &url.GetUrlByTokenInput{
+		Token:  r.PathValue("token"),
+		Domain: r.FormValue("domain"),
+	})
+	if err != nil || !entry.Active() {
+		a.notFoundHandler(w, r)
+		return
+	}
+
+	shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+
+	vm := template.ReportLinkViewModel{
+		Errors:   a.getFlashErrors(w, r),
+		Inputs:   a.getFlashInputs(w, r),
+		Token:    entry.Token.String(),
+		Domain:   entry.Domain,
+		ShortUrl: shortUrl[strings.Index(shortUrl, "://")+3:],
+		Url:      entry.Url.String(),
+	}
+	for _, c := range entity.ReportCategories {
+		vm.Categories = append(vm.Categories, string(c))
+	}
+
+	if err := template.ReportLink(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the report page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// reportLinkHandler will record a report of abuse of a link, anonymous reporters are told apart by their network, see reporterNetwork
+func (a *App) reportLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.ReportUrlInput{
+		Token:       r.PathValue("token"),
+		Domain:      r.FormValue("domain"),
+		Category:    r.FormValue("category"),
+		Description: r.FormValue("description"),
+		Reporter:    reporterNetwork(realip.FromRequest(r).IP),
+	}
+
+	if err := a.urlService.ReportUrl(r.Context(), input); err != nil {
+		switch {
+		case errors.Is(err, url.ErrNotFound), input.ValidationErrors["token"] != "", input.ValidationErrors["domain"] != "":
+			a.notFoundHandler(w, r)
+		case errors.Is(err, url.ErrValidation):
+			a.setFlashErrors(w, r, input.ValidationErrors)
+			a.setFlashInputs(w, r, map[string]string{"category": input.Category, "description": input.Description})
+			http.Redirect(w, r, a.baseURL.Path(template.LinkPath("/links/"+input.Token+"/report", input.Domain)), http.StatusFound)
+		default:
+			a.logger.ErrorContext(r.Context(), "failed to report the link", slog.String("error", err.Error()))
+			a.serverErrorHandler(w, r)
+		}
+		return
+	}
+
+	a.setFlashMessage(w, r, "Thanks, the link has been reported and will be reviewed.")
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
+
+// reporterNetwork will return the network an anonymous report counts for, the ipv4 address or the /64 of an ipv6 address.
+// One ipv6 client usually has a whole /64, so its addresses must not count as different reporters.
+func reporterNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, _ := addr.WithZone("").Prefix(64)
+	return prefix.String()
+}
+
+// moderationHandler will show the links with open reports and the links on hold to an admin, the page query parameter pages through them
+func (a *App) moderationHandler(w http.ResponseWriter, r *http.Request) {
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	result, err := a.urlService.ListReportedUrls(r.Context(), &url.ListReportedUrlsInput{Page: page})
+	if errors.Is(err, url.ErrForbidden) {
+		a.notFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the reported links", slog.String("error", err.Error()))
+		a.serverErrorHandler(w, r)
+		return
+	}
+
+	vm := template.ModerationViewModel{
+		Message: a.getFlashMessage(w, r),
+		Errors:  a.getFlashErrors(w, r),
+		Page:    result.Page,
+		Pages:   result.Pages(),
+		Total:   result.Total,
+	}
+	for _, reported := range result.Entries {
+		entry := reported.Entry
+		shortUrl := a.baseURL.ShortURLOn(r, entry.Domain, entry.Token.String())
+		link := template.ReportedLink{
+			Token:             entry.Token.String(),
+			Domain:            entry.Domain,
+			ShortUrl:          shortUrl[strings.Index(shortUrl, "://")+3:],
+			ShortUrlWithProto: shortUrl,
+			Url:               entry.Url.String(),
+			Creator:           reportedCreator(reported),
+			Active:            entry.Active(),
+			Held:              entry.Held(),
+		}
+		for _, report := range reported.Reports {
+			link.Reports = append(link.Reports, template.LinkReport{
+				Category:    string(report.Category),
+				Description: report.Description,
+				CreatedAt:   report.CreatedAt,
+			})
+		}
+		for _, v := range reported.Visits {
+			link.Visits = append(link.Visits, template.DayVisits{Day: v.Day, Count: v.Count})
+		}
+		vm.Links = append(vm.Links, link)
+	}
+
+	if err := template.Moderation(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the moderation page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// reportedCreator will describe who created a reported link
+func reportedCreator(reported *entity.ReportedUrl) string {
+	creator := "anonymous"
+	switch {
+	case reported.CreatorEmail != "":
+		creator = reported.CreatorEmail
+	case reported.Entry.OwnerID != 0:
+		creator = "a user"
+	case reported.Entry.WorkspaceID != 0:
+		creator = "an api key"
+	}
+	if reported.Entry.WorkspaceID != 0 {
+		creator += " in workspace " + strconv.FormatInt(reported.Entry.WorkspaceID, 10)
+	}
+	return creator
+}
+
+// resolveReportsHandler will close the open reports of a link with the status an admin picked in the status form value
+func (a *App) resolveReportsHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.ResolveReportsInput{
+		Token:  r.PathValue("token"),
+		Domain: r.FormValue("domain"),
+		Status: entity.UrlStatus(r.FormValue("status")),
+		Reason: r.FormValue("reason"),
+	}
+
+	if err := a.urlService.ResolveReports(r.Context(), input); err != nil {
+		a.moderationActionFailed(w, r, input.ValidationErrors, err, "Failed to resolve the reports")
+		return
+	}
+
+	switch input.Status {
+	case entity.UrlStatusTakenDown:
+		a.setFlashMessage(w, r, "The link has been taken down.")
+	case entity.UrlStatusHeld:
+		a.setFlashMessage(w, r, "The link has been put on hold.")
+	default:
+		a.setFlashMessage(w, r, "The reports have been dismissed and the link is kept.")
+	}
+	http.Redirect(w, r, a.baseURL.Path(moderationReturnPath(r)), http.StatusFound)
+}
+
+// restoreLinkHandler will make a link that is on hold redirect again and close its open reports
+func (a *App) restoreLinkHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &url.RestoreUrlInput{
+		Token:  r.PathValue("token"),
+		Domain: r.FormValue("domain"),
+	}
+
+	if err := a.urlService.RestoreUrl(r.Context(), input); err != nil {
+		a.moderationActionFailed(w, r, input.ValidationErrors, err, "Failed to restore the link")
+		return
+	}
+
+	a.setFlashMessage(w, r, "The link has been restored.")
+	http.Redirect(w, r, a.baseURL.Path(moderationReturnPath(r)), http.StatusFound)
+}
+
+// moderationActionFailed will respond to a failed moderation action, requests that are not from an admin get the not found page
+func (a *App) moderationActionFailed(w http.ResponseWriter, r *http.Request, validationErrors map[string]string, err error, message string) {
+	switch {
+	case errors.Is(err, url.ErrForbidden), errors.Is(err, url.ErrNotFound), validationErrors["token"] != "", validationErrors["domain"] != "":
+		a.notFoundHandler(w, r)
+	case errors.Is(err, url.ErrValidation):
+		a.setFlashErrors(w, r, validationErrors)
+		http.Redirect(w, r, a.baseURL.Path(moderationReturnPath(r)), http.StatusFound)
+	default:
+		a.logger.ErrorContext(r.Context(), "failed to moderate the link", slog.String("error", err.Error()))
+		a.setFlashErrors(w, r, map[string]string{"error": message})
+		http.Redirect(w, r, a.baseURL.Path(moderationReturnPath(r)), http.StatusFound)
+	}
+}
+
+// moderationReturnPath will return the page of the moderation queue in the page form value
+func moderationReturnPath(r *http.Request) string {
+	if page, _ := strconv.Atoi(r.FormValue("page")); page > 1 {
+		return "/moderation?page=" + strconv.Itoa(page)
+	}
+	return "/moderation"
+}
@@ -0,0 +1,229 @@
+package webapp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+
+	urlservice "github.com/griggsjared/getsit/internal/url"
+	"github.com/griggsjared/getsit/internal/url/entity"
+	"github.com/griggsjared/getsit/internal/url/repository"
+	"github.com/griggsjared/getsit/internal/user"
+	userrepository "github.com/griggsjared/getsit/internal/user/repository"
+	"github.com/griggsjared/getsit/internal/webapp"
+)
+
+// post will send the form to the handler from the remote address, with the cookies of a logged in user when set
+func post(h http.Handler, path string, form neturl.Values, remoteAddr string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// get will request the path from the handler, with the cookies of a logged in user when set
+func get(h http.Handler, path string, cookies []*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// login will log the user in through the login form and return the cookies of their session
+func login(t *testing.T, h http.Handler, email string) []*http.Cookie {
+	t.Helper()
+	rec := post(h, "/login", neturl.Values{"email": {email}, "password": {"password123"}}, "", nil)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/" {
+		t.Fatalf("POST /login status = %d, location = %q, want a redirect home", rec.Code, rec.Header().Get("Location"))
+	}
+	return rec.Result().Cookies()
+}
+
+func TestApp_HandlerReport(t *testing.T) {
+
+	ctx := context.Background()
+	s := urlservice.NewService(repository.NewMemUrlEntryRepository()).WithReportThreshold(2).WithReporterKey([]byte("secret"))
+	entry, err := s.SaveUrl(ctx, &urlservice.SaveUrlInput{Url: "https://phish.com"})
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	token := entry.Token.String()
+
+	h := newHandler(t, webapp.Options{UrlService: s})
+
+	if rec := get(h, "/links/"+token+"/report", nil); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "phishing") {
+		t.Errorf("GET report page status = %d, want %d with the categories", rec.Code, http.StatusOK)
+	}
+	if rec := get(h, "/links/Unknown1/report", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("GET report page of an unknown link status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		category     string
+		wantStatus   int
+		wantLocation string
+		wantLink     entity.UrlStatus
+	}{
+		{
+			name:         "unknown category",
+			remoteAddr:   "192.0.2.1:1234",
+			category:     "rude",
+			wantStatus:   http.StatusFound,
+			wantLocation: "/links/" + token + "/report",
+			wantLink:     entity.UrlStatusActive,
+		},
+		{
+			name:         "first reporter",
+			remoteAddr:   "[2001:db8::1]:1234",
+			category:     "phishing",
+			wantStatus:   http.StatusFound,
+			wantLocation: "/",
+			wantLink:     entity.UrlStatusActive,
+		},
+		{
+			name:         "another address of the same ipv6 network",
+			remoteAddr:   "[2001:db8::2]:1234",
+			category:     "phishing",
+			wantStatus:   http.StatusFound,
+			wantLocation: "/",
+			wantLink:     entity.UrlStatusActive,
+		},
+		{
+			name:         "another ipv6 network",
+			remoteAddr:   "[2001:db8:0:1::1]:1234",
+			category:     "spam",
+			wantStatus:   http.StatusFound,
+			wantLocation: "/",
+			wantLink:     entity.UrlStatusHeld,
+		},
+		{
+			name:       "held link",
+			remoteAddr: "192.0.2.2:1234",
+			category:   "phishing",
+			wantStatus: http.StatusNotFound,
+			wantLink:   entity.UrlStatusHeld,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(h, "/links/"+token+"/report", neturl.Values{"category": {tt.category}}, tt.remoteAddr, nil)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST report status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("POST report location = %q, want %q", got, tt.wantLocation)
+			}
+			found, err := s.GetUrlByToken(ctx, &urlservice.GetUrlByTokenInput{Token: token})
+			if err != nil {
+				t.Fatalf("GetUrlByToken() error = %v", err)
+			}
+			if found.Status != tt.wantLink {
+				t.Errorf("link status = %v, want %v", found.Status, tt.wantLink)
+			}
+		})
+	}
+}
+
+func TestApp_HandlerModeration(t *testing.T) {
+
+	ctx := context.Background()
+	users := user.NewTestService(userrepository.NewMemUserRepository(), nil)
+	admin, err := users.Register(ctx, &user.RegisterInput{Email: "admin@getsit.to", Password: "password123"})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if _, err := users.Register(ctx, &user.RegisterInput{Email: "user@getsit.to", Password: "password123"}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	s := urlservice.NewService(repository.NewMemUrlEntryRepository()).WithReportThreshold(2).WithReporterKey([]byte("secret"))
+	entry, err := s.SaveUrl(ctx, &urlservice.SaveUrlInput{Url: "https://phish.com"})
+	if err != nil {
+		t.Fatalf("SaveUrl() error = %v", err)
+	}
+	token := entry.Token.String()
+	for _, reporter := range []string{"192.0.2.1", "192.0.2.2"} {
+		if err := s.ReportUrl(ctx, &urlservice.ReportUrlInput{Token: token, Category: "phishing", Reporter: reporter}); err != nil {
+			t.Fatalf("ReportUrl() error = %v", err)
+		}
+	}
+
+	h := newHandler(t, webapp.Options{UrlService: s, UserService: users, AdminUserIDs: []int64{admin.ID}})
+	adminCookies := login(t, h, "admin@getsit.to")
+	userCookies := login(t, h, "user@getsit.to")
+
+	pageTests := []struct {
+		name         string
+		cookies      []*http.Cookie
+		wantStatus   int
+		wantLocation string
+		wantBody     string
+	}{
+		{name: "logged out", wantStatus: http.StatusFound, wantLocation: "/login"},
+		{name: "not an admin", cookies: userCookies, wantStatus: http.StatusNotFound, wantBody: "404: Page not found"},
+		{name: "admin", cookies: adminCookies, wantStatus: http.StatusOK, wantBody: "https://phish.com"},
+	}
+	for _, tt := range pageTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(h, "/moderation", tt.cookies)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("GET /moderation status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("GET /moderation location = %q, want %q", got, tt.wantLocation)
+			}
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("GET /moderation body does not contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	actionTests := []struct {
+		name       string
+		action     string
+		cookies    []*http.Cookie
+		status     string
+		wantStatus int
+		wantLink   entity.UrlStatus
+		wantListed bool
+	}{
+		{name: "resolve as not an admin", action: "resolve", cookies: userCookies, status: "active", wantStatus: http.StatusNotFound, wantLink: entity.UrlStatusHeld, wantListed: true},
+		{name: "restore as not an admin", action: "restore", cookies: userCookies, wantStatus: http.StatusNotFound, wantLink: entity.UrlStatusHeld, wantListed: true},
+		{name: "resolve with an unknown status", action: "resolve", cookies: adminCookies, status: "disabled", wantStatus: http.StatusFound, wantLink: entity.UrlStatusHeld, wantListed: true},
+		{name: "hold", action: "resolve", cookies: adminCookies, status: "held", wantStatus: http.StatusFound, wantLink: entity.UrlStatusHeld, wantListed: true},
+		{name: "restore", action: "restore", cookies: adminCookies, wantStatus: http.StatusFound, wantLink: entity.UrlStatusActive},
+	}
+	for _, tt := range actionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := post(h, "/moderation/"+token+"/"+tt.action, neturl.Values{"status": {tt.status}}, "", tt.cookies)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("POST %s status = %d, want %d", tt.action, rec.Code, tt.wantStatus)
+			}
+			found, err := s.GetUrlByToken(ctx, &urlservice.GetUrlByTokenInput{Token: token})
+			if err != nil {
+				t.Fatalf("GetUrlByToken() error = %v", err)
+			}
+			if found.Status != tt.wantLink {
+				t.Errorf("link status = %v, want %v", found.Status, tt.wantLink)
+			}
+			if got := strings.Contains(get(h, "/moderation", adminCookies).Body.String(), "https://phish.com"); got != tt.wantListed {
+				t.Errorf("GET /moderation lists the link = %v, want %v", got, tt.wantListed)
+			}
+		})
+	}
+}
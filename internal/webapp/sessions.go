@@ -1,16 +1,29 @@
-package main
+package webapp
 
 import (
 	"encoding/json"
 	"net/http"
 
 	"github.com/gorilla/sessions"
+
+	"github.com/griggsjared/getsit/internal/realip"
 )
 
 const flashSessionName string = "flash-session"
 
+// cookieOptions will return the options of a session cookie that lasts for max age seconds, the cookie is only sent over https when the request is
+func (a *App) cookieOptions(r *http.Request, maxAge int) *sessions.Options {
+	return &sessions.Options{
+		Path:     a.baseURL.Path("/"),
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   realip.FromRequest(r).Proto == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
 // setFlashMessage sets a flash message in the session
-func (a *app) setFlashMessage(w http.ResponseWriter, r *http.Request, message string) {
+func (a *App) setFlashMessage(w http.ResponseWriter, r *http.Request, message string) {
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
 		http.Error(w, "Failed to get session", http.StatusInternalServerError)
@@ -22,7 +35,7 @@ func (a *app) setFlashMessage(w http.ResponseWriter, r *http.Request, message st
 }
 
 // getFlashMessage gets a flash message from the session
-func (a *app) getFlashMessage(w http.ResponseWriter, r *http.Request) string {
+func (a *App) getFlashMessage(w http.ResponseWriter, r *http.Request) string {
 
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
@@ -40,8 +53,70 @@ func (a *app) getFlashMessage(w http.ResponseWriter, r *http.Request) string {
 	return flashes[0].(string)
 }
 
+// setFlashManageToken sets the manage token of a new link in the session so it can be shown once
+func (a *App) setFlashManageToken(w http.ResponseWriter, r *http.Request, token string) {
+	session, err := a.session.Get(r, flashSessionName)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	session.AddFlash(token, "manage_token")
+	session.Save(r, w)
+}
+
+// getFlashManageToken gets the manage token of a new link from the session
+func (a *App) getFlashManageToken(w http.ResponseWriter, r *http.Request) string {
+
+	session, err := a.session.Get(r, flashSessionName)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return ""
+	}
+
+	defer session.Save(r, w)
+
+	flashes := session.Flashes("manage_token")
+	if len(flashes) == 0 {
+		return ""
+	}
+
+	return flashes[0].(string)
+}
+
+// setFlashAPIKey sets a new api key in the session so it can be shown once
+func (a *App) setFlashAPIKey(w http.ResponseWriter, r *http.Request, key string) {
+	session, err := a.session.Get(r, flashSessionName)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	session.AddFlash(key, "api_key")
+	session.Save(r, w)
+}
+
+// getFlashAPIKey gets a new api key from the session
+func (a *App) getFlashAPIKey(w http.ResponseWriter, r *http.Request) string {
+
+	session, err := a.session.Get(r, flashSessionName)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return ""
+	}
+
+	defer session.Save(r, w)
+
+	flashes := session.Flashes("api_key")
+	if len(flashes) == 0 {
+		return ""
+	}
+
+	return flashes[0].(string)
+}
+
 // setFlashErrors sets flash errors in the session
-func (a *app) setFlashErrors(w http.ResponseWriter, r *http.Request, errors map[string]string) {
+func (a *App) setFlashErrors(w http.ResponseWriter, r *http.Request, errors map[string]string) {
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
 		http.Error(w, "Failed to get session", http.StatusInternalServerError)
@@ -64,7 +139,7 @@ func (a *app) setFlashErrors(w http.ResponseWriter, r *http.Request, errors map[
 }
 
 // getFlashErrors gets flash errors from the session
-func (a *app) getFlashErrors(w http.ResponseWriter, r *http.Request) map[string]string {
+func (a *App) getFlashErrors(w http.ResponseWriter, r *http.Request) map[string]string {
 
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
@@ -72,7 +147,7 @@ func (a *app) getFlashErrors(w http.ResponseWriter, r *http.Request) map[string]
 		return nil
 	}
 
-	defer sessions.Save(r, w)
+	defer session.Save(r, w)
 
 	flashes := session.Flashes("errors")
 	if len(flashes) == 0 {
@@ -89,7 +164,7 @@ func (a *app) getFlashErrors(w http.ResponseWriter, r *http.Request) map[string]
 	return errors
 }
 
-func (a *app) setFlashInputs(w http.ResponseWriter, r *http.Request, inputs map[string]string) {
+func (a *App) setFlashInputs(w http.ResponseWriter, r *http.Request, inputs map[string]string) {
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
 		http.Error(w, "Failed to get session", http.StatusInternalServerError)
@@ -111,7 +186,7 @@ func (a *app) setFlashInputs(w http.ResponseWriter, r *http.Request, inputs map[
 	session.Save(r, w)
 }
 
-func (a *app) getFlashInputs(w http.ResponseWriter, r *http.Request) map[string]string {
+func (a *App) getFlashInputs(w http.ResponseWriter, r *http.Request) map[string]string {
 	session, err := a.session.Get(r, flashSessionName)
 	if err != nil {
 		http.Error(w, "Failed to get session", http.StatusInternalServerError)
@@ -0,0 +1,123 @@
+package webapp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/griggsjared/getsit/internal/oidc"
+	"github.com/griggsjared/getsit/internal/user"
+)
+
+// ssoSessionName is the signed session that keeps the secrets of a single sign on login until the provider redirects back
+const ssoSessionName string = "sso-session"
+
+// ssoSessionMaxAge is how long the user has to log in at the identity provider, 10 minutes
+const ssoSessionMaxAge = 10 * 60
+
+// ssoRedirectURL will return the url the identity provider redirects back to
+func (a *App) ssoRedirectURL(r *http.Request) string {
+	return a.baseURL.For(r) + "/login/sso/callback"
+}
+
+// ssoFailed will send the browser back to the login page with the error
+func (a *App) ssoFailed(w http.ResponseWriter, r *http.Request, msg string) {
+	a.setFlashErrors(w, r, map[string]string{"error": msg})
+	http.Redirect(w, r, a.baseURL.Path("/login"), http.StatusFound)
+}
+
+// ssoLoginHandler will start a login at the identity provider.
+// The state, nonce and PKCE verifier are kept in a signed cookie, only the hash of the verifier leaves the browser.
+func (a *App) ssoLoginHandler(w http.ResponseWriter, r *http.Request) {
+
+	req, err := oidc.NewAuthRequest()
+	if err != nil {
+		http.Error(w, "Failed to start the login", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := a.oidc.AuthCodeURL(r.Context(), req, a.ssoRedirectURL(r))
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to reach the identity provider", slog.String("error", err.Error()))
+		a.ssoFailed(w, r, "The identity provider could not be reached, try again later")
+		return
+	}
+
+	session, _ := a.session.Get(r, ssoSessionName)
+	session.Options = a.cookieOptions(r, ssoSessionMaxAge)
+	session.Values["state"] = req.State
+	session.Values["nonce"] = req.Nonce
+	session.Values["verifier"] = req.Verifier
+	if err := session.Save(r, w); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// ssoCallbackHandler will finish the login the identity provider redirected back from and log the user in.
+// The login secrets are removed first so a callback can only be used once.
+func (a *App) ssoCallbackHandler(w http.ResponseWriter, r *http.Request) {
+
+	session, _ := a.session.Get(r, ssoSessionName)
+	state, _ := session.Values["state"].(string)
+	req := &oidc.AuthRequest{State: state}
+	req.Nonce, _ = session.Values["nonce"].(string)
+	req.Verifier, _ = session.Values["verifier"].(string)
+
+	session.Options = a.cookieOptions(r, -1)
+	session.Values = map[any]any{}
+	session.Save(r, w)
+
+	q := r.URL.Query()
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(q.Get("state"))) != 1 {
+		a.ssoFailed(w, r, "The login expired or was started in another browser, try again")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		a.logger.WarnContext(r.Context(), "the identity provider refused the login", slog.String("error", e), slog.String("description", q.Get("error_description")))
+		a.ssoFailed(w, r, "The identity provider did not log you in")
+		return
+	}
+
+	identity, err := a.oidc.Exchange(r.Context(), req, q.Get("code"), a.ssoRedirectURL(r))
+	if errors.Is(err, oidc.ErrNotAllowed) {
+		a.ssoFailed(w, r, "Your account is not allowed to log in here")
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to finish the single sign on login", slog.String("error", err.Error()))
+		a.ssoFailed(w, r, "The login could not be verified, try again")
+		return
+	}
+
+	u, err := a.userService.LoginWithIdentity(r.Context(), &user.LoginWithIdentityInput{
+		Issuer:        identity.Issuer,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Groups:        identity.Groups,
+	})
+	if errors.Is(err, user.ErrUnverifiedEmail) {
+		a.ssoFailed(w, r, "The identity provider did not share a verified email for your account")
+		return
+	}
+	if errors.Is(err, user.ErrPasswordAccount) {
+		a.ssoFailed(w, r, "An account with your email already exists, log in with its password instead")
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to log in with the identity", slog.String("error", err.Error()))
+		a.ssoFailed(w, r, "Failed to log in")
+		return
+	}
+
+	if err := a.startAuthSession(w, r, u); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, a.baseURL.Path("/"), http.StatusFound)
+}
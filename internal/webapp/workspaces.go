@@ -0,0 +1,294 @@
+package webapp
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/sessions"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/workspace"
+	"github.com/griggsjared/getsit/web/template"
+)
+
+// withActiveWorkspace will make the request act in the workspace the user switched to.
+// The role is checked on every request so a removed member is back in their personal links straight away.
+func (a *App) withActiveWorkspace(ctx context.Context, session *sessions.Session) context.Context {
+
+	ctx = context.WithValue(ctx, template.WorkspaceCtxKey, "")
+
+	id, ok := session.Values["workspace_id"].(int64)
+	if !ok {
+		return ctx
+	}
+
+	memberships, err := a.workspaceService.ListWorkspaces(ctx)
+	if err != nil {
+		a.logger.ErrorContext(ctx, "failed to load the workspaces of the user", slog.String("error", err.Error()))
+		return ctx
+	}
+	for _, m := range memberships {
+		if m.Workspace.ID == id {
+			p := authz.FromContext(ctx)
+			p.WorkspaceID = id
+			p.Role = m.Role
+			ctx = authz.WithPrincipal(ctx, p)
+			return context.WithValue(ctx, template.WorkspaceCtxKey, m.Workspace.Name.String())
+		}
+	}
+	return ctx
+}
+
+// workspaceName will return the name of the workspace the request acts in, or an empty string for the personal links
+func workspaceName(ctx context.Context) string {
+	name, _ := ctx.Value(template.WorkspaceCtxKey).(string)
+	return name
+}
+
+// switchWorkspace will make the following requests act in the workspace, 0 switches back to the personal links
+func (a *App) switchWorkspace(w http.ResponseWriter, r *http.Request, id int64) error {
+	session, _ := a.session.Get(r, authSessionName)
+	session.Options = a.cookieOptions(r, authSessionMaxAge)
+	if id == 0 {
+		delete(session.Values, "workspace_id")
+	} else {
+		session.Values["workspace_id"] = id
+	}
+	return session.Save(r, w)
+}
+
+// workspacesHandler will show the workspaces of the logged in user with the forms to switch between them and create one
+func (a *App) workspacesHandler(w http.ResponseWriter, r *http.Request) {
+
+	memberships, err := a.workspaceService.ListWorkspaces(r.Context())
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to list the workspaces", slog.String("error", err.Error()))
+		a.serverErrorHandler(w, r)
+		return
+	}
+
+	vm := template.WorkspacesViewModel{
+		Message: a.getFlashMessage(w, r),
+		Errors:  a.getFlashErrors(w, r),
+		Inputs:  a.getFlashInputs(w, r),
+		Active:  authz.FromContext(r.Context()).WorkspaceID,
+	}
+	for _, m := range memberships {
+		vm.Workspaces = append(vm.Workspaces, template.WorkspaceItem{
+			ID:   m.Workspace.ID,
+			Name: m.Workspace.Name.String(),
+			Role: string(m.Role),
+		})
+	}
+
+	if err := template.Workspaces(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the workspaces", http.StatusInternalServerError)
+		return
+	}
+}
+
+// createWorkspaceHandler will create a workspace owned by the logged in user and switch to it
+func (a *App) createWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+
+	input := &workspace.CreateWorkspaceInput{
+		Name: r.FormValue("name"),
+	}
+
+	ws, err := a.workspaceService.CreateWorkspace(r.Context(), input)
+	if err != nil {
+		a.setFlashInputs(w, r, map[string]string{"name": input.Name})
+		a.workspaceActionFailed(w, r, "/workspaces", input.ValidationErrors, err)
+		return
+	}
+
+	if err := a.switchWorkspace(w, r, ws.ID); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The workspace has been created.")
+	http.Redirect(w, r, a.baseURL.Path("/workspaces/"+strconv.FormatInt(ws.ID, 10)), http.StatusFound)
+}
+
+// switchWorkspaceHandler will make the dashboard and new links use the workspace in the workspace_id form value.
+// A workspace_id of 0 switches back to the personal links.
+func (a *App) switchWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.FormValue("workspace_id"), 10, 64)
+	if id != 0 {
+		if _, err := a.workspaceService.Role(r.Context(), id, currentUserID(r.Context())); err != nil {
+			a.notFoundHandler(w, r)
+			return
+		}
+	}
+
+	if err := a.switchWorkspace(w, r, id); err != nil {
+		http.Error(w, "Failed to save the session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, a.baseURL.Path("/links"), http.StatusFound)
+}
+
+// workspaceHandler will show the members of a workspace, its owners can also manage the members and api keys
+func (a *App) workspaceHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	details, err := a.workspaceService.GetWorkspace(r.Context(), id)
+	if errors.Is(err, workspace.ErrNotFound) {
+		a.notFoundHandler(w, r)
+		return
+	}
+	if err != nil {
+		a.logger.ErrorContext(r.Context(), "failed to get the workspace", slog.String("error", err.Error()))
+		a.serverErrorHandler(w, r)
+		return
+	}
+
+	vm := template.WorkspaceViewModel{
+		Message: a.getFlashMessage(w, r),
+		Errors:  a.getFlashErrors(w, r),
+		Inputs:  a.getFlashInputs(w, r),
+		NewKey:  a.getFlashAPIKey(w, r),
+		ID:      details.Workspace.ID,
+		Name:    details.Workspace.Name.String(),
+		Role:    string(details.Role),
+		Admin:   details.Role.CanAdmin(),
+		Active:  authz.FromContext(r.Context()).WorkspaceID == details.Workspace.ID,
+	}
+	for _, m := range details.Members {
+		vm.Members = append(vm.Members, template.WorkspaceMember{
+			UserID: m.UserID,
+			Email:  m.Email,
+			Role:   string(m.Role),
+			You:    m.UserID == currentUserID(r.Context()),
+		})
+	}
+	for _, k := range details.APIKeys {
+		vm.APIKeys = append(vm.APIKeys, template.WorkspaceAPIKey{
+			ID:         k.ID,
+			Name:       k.Name.String(),
+			Prefix:     k.Prefix,
+			Role:       string(k.Role),
+			CreatedAt:  k.CreatedAt,
+			LastUsedAt: k.LastUsedAt,
+		})
+	}
+
+	if err := template.Workspace(vm).Render(r.Context(), w); err != nil {
+		http.Error(w, "Failed to render the workspace", http.StatusInternalServerError)
+		return
+	}
+}
+
+// saveMemberHandler will add a member to the workspace by their email, or change the role of an existing member
+func (a *App) saveMemberHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	input := &workspace.AddMemberInput{
+		WorkspaceID: id,
+		Email:       r.FormValue("email"),
+		Role:        r.FormValue("role"),
+	}
+
+	back := "/workspaces/" + r.PathValue("id")
+	if err := a.workspaceService.AddMember(r.Context(), input); err != nil {
+		a.setFlashInputs(w, r, map[string]string{"email": input.Email})
+		a.workspaceActionFailed(w, r, back, input.ValidationErrors, err)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The member has been saved.")
+	http.Redirect(w, r, a.baseURL.Path(back), http.StatusFound)
+}
+
+// removeMemberHandler will remove a member from the workspace, members can also remove themselves to leave it
+func (a *App) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	userID, _ := strconv.ParseInt(r.PathValue("user"), 10, 64)
+	input := &workspace.RemoveMemberInput{
+		WorkspaceID: id,
+		UserID:      userID,
+	}
+
+	back := "/workspaces/" + r.PathValue("id")
+	if err := a.workspaceService.RemoveMember(r.Context(), input); err != nil {
+		a.workspaceActionFailed(w, r, back, input.ValidationErrors, err)
+		return
+	}
+
+	if userID == currentUserID(r.Context()) {
+		if authz.FromContext(r.Context()).WorkspaceID == id {
+			a.switchWorkspace(w, r, 0)
+		}
+		a.setFlashMessage(w, r, "You have left the workspace.")
+		http.Redirect(w, r, a.baseURL.Path("/workspaces"), http.StatusFound)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The member has been removed.")
+	http.Redirect(w, r, a.baseURL.Path(back), http.StatusFound)
+}
+
+// createAPIKeyHandler will create an api key for the workspace, the key is only shown on the next page
+func (a *App) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	input := &workspace.CreateAPIKeyInput{
+		WorkspaceID: id,
+		Name:        r.FormValue("key_name"),
+		Role:        r.FormValue("key_role"),
+	}
+
+	back := "/workspaces/" + r.PathValue("id")
+	if _, err := a.workspaceService.CreateAPIKey(r.Context(), input); err != nil {
+		if input.ValidationErrors["name"] != "" {
+			input.ValidationErrors["key_name"] = input.ValidationErrors["name"]
+			delete(input.ValidationErrors, "name")
+		}
+		a.setFlashInputs(w, r, map[string]string{"key_name": input.Name})
+		a.workspaceActionFailed(w, r, back, input.ValidationErrors, err)
+		return
+	}
+
+	a.setFlashAPIKey(w, r, input.Key)
+	http.Redirect(w, r, a.baseURL.Path(back), http.StatusFound)
+}
+
+// deleteAPIKeyHandler will delete an api key of the workspace so it can no longer be used
+func (a *App) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	keyID, _ := strconv.ParseInt(r.PathValue("key"), 10, 64)
+
+	back := "/workspaces/" + r.PathValue("id")
+	if err := a.workspaceService.DeleteAPIKey(r.Context(), &workspace.DeleteAPIKeyInput{WorkspaceID: id, ID: keyID}); err != nil {
+		a.workspaceActionFailed(w, r, back, nil, err)
+		return
+	}
+
+	a.setFlashMessage(w, r, "The api key has been deleted.")
+	http.Redirect(w, r, a.baseURL.Path(back), http.StatusFound)
+}
+
+// workspaceActionFailed will respond to a failed change of a workspace.
+// Validation errors and a role that does not allow the change are flashed on the page the change was made from.
+func (a *App) workspaceActionFailed(w http.ResponseWriter, r *http.Request, back string, validationErrors map[string]string, err error) {
+	switch {
+	case errors.Is(err, workspace.ErrNotFound):
+		a.notFoundHandler(w, r)
+		return
+	case errors.Is(err, workspace.ErrValidation):
+		a.setFlashErrors(w, r, validationErrors)
+	case errors.Is(err, workspace.ErrForbidden):
+		a.setFlashErrors(w, r, map[string]string{"error": "Only the owners of the workspace can do that"})
+	default:
+		a.logger.ErrorContext(r.Context(), "failed to change the workspace", slog.String("error", err.Error()))
+		a.setFlashErrors(w, r, map[string]string{"error": "Failed to change the workspace"})
+	}
+	http.Redirect(w, r, a.baseURL.Path(back), http.StatusFound)
+}
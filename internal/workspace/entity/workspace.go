@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/griggsjared/getsit/internal/authz"
+)
+
+const (
+	nameMaxLength = 64
+	apiKeyPrefix  = "gsk_" // Makes the keys easy to spot in code and logs
+	apiKeyBytes   = 32
+)
+
+// Name is the name of a workspace or api key
+type Name string
+
+// NormalizeName will trim the spaces around the name
+func NormalizeName(name string) Name {
+	return Name(strings.TrimSpace(name))
+}
+
+// Validate will check the name is not empty and not too long
+func (n Name) Validate() error {
+	if n == "" {
+		return fmt.Errorf("name is required")
+	}
+	if utf8.RuneCountInString(string(n)) > nameMaxLength {
+		return fmt.Errorf("name must be at most %d characters", nameMaxLength)
+	}
+	return nil
+}
+
+// String will return the string representation of the name
+func (n Name) String() string {
+	return string(n)
+}
+
+// Workspace is a team of users that share their links
+type Workspace struct {
+	ID        int64
+	Name      Name
+	CreatedAt time.Time
+}
+
+// Member is a user of a workspace and what they can do in it
+type Member struct {
+	WorkspaceID int64
+	UserID      int64
+	Email       string
+	Role        authz.Role
+	CreatedAt   time.Time
+}
+
+// Membership is a workspace a user belongs to and their role in it
+type Membership struct {
+	Workspace Workspace
+	Role      authz.Role
+}
+
+// APIKey lets a program use the api as the workspace with the role of the key.
+// Only the sha256 hash of the key is stored, the key itself is only shown when it is created.
+type APIKey struct {
+	ID          int64
+	WorkspaceID int64
+	Name        Name
+	Prefix      string // The start of the key so it can be recognized in the list
+	KeyHash     string
+	Role        authz.Role // Keys can be editors or viewers, never owners
+	CreatedBy   int64      // The user that created the key, 0 when the user has been deleted
+	CreatedAt   time.Time
+	LastUsedAt  time.Time // Zero when the key has not been used
+}
+
+// ValidateAPIKeyRole will check the role can be given to an api key
+func ValidateAPIKeyRole(role authz.Role) error {
+	if role != authz.RoleEditor && role != authz.RoleViewer {
+		return fmt.Errorf("role must be one of %s or %s", authz.RoleEditor, authz.RoleViewer)
+	}
+	return nil
+}
+
+// NewAPIKey will generate a new secret api key, its prefix and its hash, only the prefix and hash should be stored
+func NewAPIKey() (key string, prefix string, hash string, err error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, key[:len(apiKeyPrefix)+6], HashAPIKey(key), nil
+}
+
+// HashAPIKey will return the hash of the api key that is stored
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
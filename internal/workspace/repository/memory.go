@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/workspace"
+	"github.com/griggsjared/getsit/internal/workspace/entity"
+)
+
+// MemWorkspaceRepository is a in memory repository that will repository the workspaces, members and api keys
+type MemWorkspaceRepository struct {
+	workspaces map[int64]*entity.Workspace
+	members    map[[2]int64]*entity.Member //key is the workspace id and user id
+	keys       map[int64]*entity.APIKey
+	nextID     int64
+	nextKeyID  int64
+}
+
+// NewMemWorkspaceRepository will create a new in memory repository
+func NewMemWorkspaceRepository() *MemWorkspaceRepository {
+	return &MemWorkspaceRepository{
+		workspaces: make(map[int64]*entity.Workspace),
+		members:    make(map[[2]int64]*entity.Member),
+		keys:       make(map[int64]*entity.APIKey),
+	}
+}
+
+// CreateWorkspace will save a new workspace with the owner as its first member
+func (s *MemWorkspaceRepository) CreateWorkspace(ctx context.Context, name entity.Name, owner *entity.Member) (*entity.Workspace, error) {
+	s.nextID++
+	w := &entity.Workspace{
+		ID:        s.nextID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	s.workspaces[w.ID] = w
+
+	m := *owner
+	m.WorkspaceID = w.ID
+	m.CreatedAt = w.CreatedAt
+	s.members[[2]int64{w.ID, m.UserID}] = &m
+
+	c := *w
+	return &c, nil
+}
+
+// GetWorkspace will return the workspace for the given id
+func (s *MemWorkspaceRepository) GetWorkspace(ctx context.Context, id int64) (*entity.Workspace, error) {
+	if w, ok := s.workspaces[id]; ok {
+		c := *w
+		return &c, nil
+	}
+	return nil, workspace.ErrNotFound
+}
+
+// ListMemberships will return the workspaces of the user ordered by name
+func (s *MemWorkspaceRepository) ListMemberships(ctx context.Context, userID int64) ([]*entity.Membership, error) {
+	var memberships []*entity.Membership
+	for k, m := range s.members {
+		if k[1] != userID {
+			continue
+		}
+		memberships = append(memberships, &entity.Membership{
+			Workspace: *s.workspaces[k[0]],
+			Role:      m.Role,
+		})
+	}
+	slices.SortFunc(memberships, func(a, b *entity.Membership) int {
+		return cmp.Or(cmp.Compare(a.Workspace.Name, b.Workspace.Name), cmp.Compare(a.Workspace.ID, b.Workspace.ID))
+	})
+	return memberships, nil
+}
+
+// GetMember will return the member of the workspace
+func (s *MemWorkspaceRepository) GetMember(ctx context.Context, workspaceID int64, userID int64) (*entity.Member, error) {
+	if m, ok := s.members[[2]int64{workspaceID, userID}]; ok {
+		c := *m
+		return &c, nil
+	}
+	return nil, workspace.ErrNotFound
+}
+
+// ListMembers will return the members of the workspace ordered by email
+func (s *MemWorkspaceRepository) ListMembers(ctx context.Context, workspaceID int64) ([]*entity.Member, error) {
+	var members []*entity.Member
+	for k, m := range s.members {
+		if k[0] == workspaceID {
+			c := *m
+			members = append(members, &c)
+		}
+	}
+	slices.SortFunc(members, func(a, b *entity.Member) int {
+		return cmp.Compare(a.Email, b.Email)
+	})
+	return members, nil
+}
+
+// SaveMember will add the member to the workspace or change the role of the existing member
+func (s *MemWorkspaceRepository) SaveMember(ctx context.Context, member *entity.Member) error {
+	if _, ok := s.workspaces[member.WorkspaceID]; !ok {
+		return workspace.ErrNotFound
+	}
+	key := [2]int64{member.WorkspaceID, member.UserID}
+	if m, ok := s.members[key]; ok {
+		m.Role = member.Role
+		return nil
+	}
+	m := *member
+	m.CreatedAt = time.Now()
+	s.members[key] = &m
+	return nil
+}
+
+// DeleteMember will remove the member from the workspace
+func (s *MemWorkspaceRepository) DeleteMember(ctx context.Context, workspaceID int64, userID int64) error {
+	key := [2]int64{workspaceID, userID}
+	if _, ok := s.members[key]; !ok {
+		return workspace.ErrNotFound
+	}
+	delete(s.members, key)
+	return nil
+}
+
+// SaveAPIKey will save a new api key and set its id and created at
+func (s *MemWorkspaceRepository) SaveAPIKey(ctx context.Context, key *entity.APIKey) error {
+	if _, ok := s.workspaces[key.WorkspaceID]; !ok {
+		return workspace.ErrNotFound
+	}
+	s.nextKeyID++
+	key.ID = s.nextKeyID
+	key.CreatedAt = time.Now()
+	c := *key
+	s.keys[key.ID] = &c
+	return nil
+}
+
+// ListAPIKeys will return the api keys of the workspace newest first
+func (s *MemWorkspaceRepository) ListAPIKeys(ctx context.Context, workspaceID int64) ([]*entity.APIKey, error) {
+	var keys []*entity.APIKey
+	for _, k := range s.keys {
+		if k.WorkspaceID == workspaceID {
+			c := *k
+			keys = append(keys, &c)
+		}
+	}
+	slices.SortFunc(keys, func(a, b *entity.APIKey) int {
+		return cmp.Compare(b.ID, a.ID)
+	})
+	return keys, nil
+}
+
+// GetAPIKeyByHash will return the api key for the given hash
+func (s *MemWorkspaceRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	for _, k := range s.keys {
+		if k.KeyHash == keyHash {
+			c := *k
+			return &c, nil
+		}
+	}
+	return nil, workspace.ErrNotFound
+}
+
+// TouchAPIKey will record when the api key was last used
+func (s *MemWorkspaceRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+	k, ok := s.keys[id]
+	if !ok {
+		return workspace.ErrNotFound
+	}
+	k.LastUsedAt = at
+	return nil
+}
+
+// DeleteAPIKey will delete the api key of the workspace
+func (s *MemWorkspaceRepository) DeleteAPIKey(ctx context.Context, workspaceID int64, id int64) error {
+	k, ok := s.keys[id]
+	if !ok || k.WorkspaceID != workspaceID {
+		return workspace.ErrNotFound
+	}
+	delete(s.keys, id)
+	return nil
+}
@@ -0,0 +1,395 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/metrics"
+	"github.com/griggsjared/getsit/internal/tracing"
+	"github.com/griggsjared/getsit/internal/workspace"
+	"github.com/griggsjared/getsit/internal/workspace/entity"
+)
+
+// dbSystem is the span attribute that marks the repository spans as postgres queries
+var dbSystem = attribute.String("db.system", "postgresql")
+
+type PGXWorkspaceRepository struct {
+	db     *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewPGXWorkspaceRepository(db *pgxpool.Pool) *PGXWorkspaceRepository {
+	return &PGXWorkspaceRepository{
+		db:     db,
+		logger: slog.Default(),
+	}
+}
+
+// WithLogger will set the logger failed queries are logged to
+func (s *PGXWorkspaceRepository) WithLogger(logger *slog.Logger) *PGXWorkspaceRepository {
+	s.logger = logger
+	return s
+}
+
+// logError will log a failed query with the request context and return the error.
+// pgx.ErrNoRows is returned as workspace.ErrNotFound and is not logged.
+func (s *PGXWorkspaceRepository) logError(ctx context.Context, op string, err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return workspace.ErrNotFound
+	}
+	s.logger.ErrorContext(ctx, "query failed", slog.String("op", op), slog.String("error", err.Error()))
+	return err
+}
+
+func (s *PGXWorkspaceRepository) CreateWorkspace(ctx context.Context, name entity.Name, owner *entity.Member) (*entity.Workspace, error) {
+
+	defer metrics.ObserveRepositoryQuery("CreateWorkspace", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.CreateWorkspace", dbSystem)
+	defer span.End()
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, s.logError(ctx, "CreateWorkspace", err)
+	}
+	defer tx.Rollback(ctx)
+
+	w := &entity.Workspace{Name: name}
+	err = tx.QueryRow(ctx, `
+		INSERT INTO workspaces (name)
+		VALUES ($1)
+		RETURNING id, created_at
+	`, name.String()).Scan(&w.ID, &w.CreatedAt)
+	if err != nil {
+		return nil, s.logError(ctx, "CreateWorkspace", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+	`, w.ID, owner.UserID, string(owner.Role))
+	if err != nil {
+		return nil, s.logError(ctx, "CreateWorkspace", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, s.logError(ctx, "CreateWorkspace", err)
+	}
+
+	return w, nil
+}
+
+func (s *PGXWorkspaceRepository) GetWorkspace(ctx context.Context, id int64) (*entity.Workspace, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetWorkspace", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.GetWorkspace", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT id, name, created_at
+		FROM workspaces
+		WHERE id = $1
+	`
+
+	var w entity.Workspace
+	var name string
+	if err := s.db.QueryRow(ctx, query, id).Scan(&w.ID, &name, &w.CreatedAt); err != nil {
+		return nil, s.logError(ctx, "GetWorkspace", err)
+	}
+	w.Name = entity.Name(name)
+	return &w, nil
+}
+
+func (s *PGXWorkspaceRepository) ListMemberships(ctx context.Context, userID int64) ([]*entity.Membership, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListMemberships", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.ListMemberships", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT w.id, w.name, w.created_at, m.role
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.name, w.id
+	`
+
+	rows, err := s.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, s.logError(ctx, "ListMemberships", err)
+	}
+	defer rows.Close()
+
+	var memberships []*entity.Membership
+	for rows.Next() {
+		var m entity.Membership
+		var name, role string
+		if err := rows.Scan(&m.Workspace.ID, &name, &m.Workspace.CreatedAt, &role); err != nil {
+			return nil, s.logError(ctx, "ListMemberships", err)
+		}
+		m.Workspace.Name = entity.Name(name)
+		m.Role = authz.Role(role)
+		memberships = append(memberships, &m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "ListMemberships", err)
+	}
+	return memberships, nil
+}
+
+// memberColumns are the columns scanned by scanMember, the query has to join the users as u and the members as m
+const memberColumns = "m.workspace_id, m.user_id, u.email, m.role, m.created_at"
+
+func (s *PGXWorkspaceRepository) GetMember(ctx context.Context, workspaceID int64, userID int64) (*entity.Member, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetMember", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.GetMember", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1 AND m.user_id = $2
+	`
+
+	m, err := scanMember(s.db.QueryRow(ctx, query, workspaceID, userID))
+	if err != nil {
+		return nil, s.logError(ctx, "GetMember", err)
+	}
+	return m, nil
+}
+
+func (s *PGXWorkspaceRepository) ListMembers(ctx context.Context, workspaceID int64) ([]*entity.Member, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListMembers", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.ListMembers", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + memberColumns + `
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY u.email
+	`
+
+	rows, err := s.db.Query(ctx, query, workspaceID)
+	if err != nil {
+		return nil, s.logError(ctx, "ListMembers", err)
+	}
+	defer rows.Close()
+
+	var members []*entity.Member
+	for rows.Next() {
+		m, err := scanMember(rows)
+		if err != nil {
+			return nil, s.logError(ctx, "ListMembers", err)
+		}
+		members = append(members, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "ListMembers", err)
+	}
+	return members, nil
+}
+
+func (s *PGXWorkspaceRepository) SaveMember(ctx context.Context, member *entity.Member) error {
+
+	defer metrics.ObserveRepositoryQuery("SaveMember", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.SaveMember", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (workspace_id, user_id) DO UPDATE
+		SET role = EXCLUDED.role, updated_at = CURRENT_TIMESTAMP
+	`
+
+	_, err := s.db.Exec(ctx, query, member.WorkspaceID, member.UserID, string(member.Role))
+	if err != nil {
+		return s.logError(ctx, "SaveMember", err)
+	}
+	return nil
+}
+
+func (s *PGXWorkspaceRepository) DeleteMember(ctx context.Context, workspaceID int64, userID int64) error {
+
+	defer metrics.ObserveRepositoryQuery("DeleteMember", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.DeleteMember", dbSystem)
+	defer span.End()
+
+	query := `
+		DELETE FROM workspace_members
+		WHERE workspace_id = $1 AND user_id = $2
+	`
+
+	tag, err := s.db.Exec(ctx, query, workspaceID, userID)
+	if err != nil {
+		return s.logError(ctx, "DeleteMember", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return workspace.ErrNotFound
+	}
+	return nil
+}
+
+// apiKeyColumns are the columns scanned by scanAPIKey
+const apiKeyColumns = "id, workspace_id, name, prefix, key_hash, role, COALESCE(created_by, 0), created_at, last_used_at"
+
+func (s *PGXWorkspaceRepository) SaveAPIKey(ctx context.Context, key *entity.APIKey) error {
+
+	defer metrics.ObserveRepositoryQuery("SaveAPIKey", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.SaveAPIKey", dbSystem)
+	defer span.End()
+
+	query := `
+		INSERT INTO api_keys (workspace_id, name, prefix, key_hash, role, created_by)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6::bigint, 0))
+		RETURNING id, created_at
+	`
+
+	err := s.db.QueryRow(ctx, query, key.WorkspaceID, key.Name.String(), key.Prefix, key.KeyHash, string(key.Role), key.CreatedBy).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return s.logError(ctx, "SaveAPIKey", err)
+	}
+	return nil
+}
+
+func (s *PGXWorkspaceRepository) ListAPIKeys(ctx context.Context, workspaceID int64) ([]*entity.APIKey, error) {
+
+	defer metrics.ObserveRepositoryQuery("ListAPIKeys", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.ListAPIKeys", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE workspace_id = $1
+		ORDER BY id DESC
+	`
+
+	rows, err := s.db.Query(ctx, query, workspaceID)
+	if err != nil {
+		return nil, s.logError(ctx, "ListAPIKeys", err)
+	}
+	defer rows.Close()
+
+	var keys []*entity.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, s.logError(ctx, "ListAPIKeys", err)
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, s.logError(ctx, "ListAPIKeys", err)
+	}
+	return keys, nil
+}
+
+func (s *PGXWorkspaceRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+
+	defer metrics.ObserveRepositoryQuery("GetAPIKeyByHash", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.GetAPIKeyByHash", dbSystem)
+	defer span.End()
+
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1
+	`
+
+	k, err := scanAPIKey(s.db.QueryRow(ctx, query, keyHash))
+	if err != nil {
+		return nil, s.logError(ctx, "GetAPIKeyByHash", err)
+	}
+	return k, nil
+}
+
+func (s *PGXWorkspaceRepository) TouchAPIKey(ctx context.Context, id int64, at time.Time) error {
+
+	defer metrics.ObserveRepositoryQuery("TouchAPIKey", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.TouchAPIKey", dbSystem)
+	defer span.End()
+
+	query := `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	_, err := s.db.Exec(ctx, query, id, at.UTC())
+	if err != nil {
+		return s.logError(ctx, "TouchAPIKey", err)
+	}
+	return nil
+}
+
+func (s *PGXWorkspaceRepository) DeleteAPIKey(ctx context.Context, workspaceID int64, id int64) error {
+
+	defer metrics.ObserveRepositoryQuery("DeleteAPIKey", time.Now())
+
+	ctx, span := tracing.Start(ctx, "PGXWorkspaceRepository.DeleteAPIKey", dbSystem)
+	defer span.End()
+
+	query := `
+		DELETE FROM api_keys
+		WHERE workspace_id = $1 AND id = $2
+	`
+
+	tag, err := s.db.Exec(ctx, query, workspaceID, id)
+	if err != nil {
+		return s.logError(ctx, "DeleteAPIKey", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return workspace.ErrNotFound
+	}
+	return nil
+}
+
+// scanMember will scan a row of the memberColumns
+func scanMember(row pgx.Row) (*entity.Member, error) {
+	var m entity.Member
+	var role string
+	if err := row.Scan(&m.WorkspaceID, &m.UserID, &m.Email, &role, &m.CreatedAt); err != nil {
+		return nil, err
+	}
+	m.Role = authz.Role(role)
+	return &m, nil
+}
+
+// scanAPIKey will scan a row of the apiKeyColumns
+func scanAPIKey(row pgx.Row) (*entity.APIKey, error) {
+	var k entity.APIKey
+	var name, role string
+	var lastUsedAt *time.Time
+	if err := row.Scan(&k.ID, &k.WorkspaceID, &name, &k.Prefix, &k.KeyHash, &role, &k.CreatedBy, &k.CreatedAt, &lastUsedAt); err != nil {
+		return nil, err
+	}
+	k.Name = entity.Name(name)
+	k.Role = authz.Role(role)
+	if lastUsedAt != nil {
+		k.LastUsedAt = *lastUsedAt
+	}
+	return &k, nil
+}
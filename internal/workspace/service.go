@@ -0,0 +1,410 @@
+package workspace
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/tracing"
+	userentity "github.com/griggsjared/getsit/internal/user/entity"
+	"github.com/griggsjared/getsit/internal/workspace/entity"
+)
+
+var (
+	// ErrValidation is a generic validation error that can be returned when input validation fails
+	ErrValidation = errors.New("validation error")
+	// ErrNotFound is returned when the workspace, member or api key does not exist or the user is not a member
+	ErrNotFound = errors.New("not found")
+	// ErrForbidden is returned when the role of the user in the workspace does not allow the change
+	ErrForbidden = errors.New("not allowed by the role in the workspace")
+	// ErrInvalidAPIKey is returned when the api key is unknown or has been deleted
+	ErrInvalidAPIKey = errors.New("invalid api key")
+)
+
+// apiKeyTouchInterval is how often the last use of an api key is written, so busy keys do not write on every request
+const apiKeyTouchInterval = time.Minute
+
+// withValidationErrors is a struct that can be embedded into the various input structs to hold validation errors
+type withValidationErrors struct {
+	ValidationErrors map[string]string
+}
+
+// WorkspaceRepository is the interface that defines the method that the service will use to interact with the repository
+type WorkspaceRepository interface {
+	// CreateWorkspace will save a new workspace with the owner as its first member
+	CreateWorkspace(ctx context.Context, name entity.Name, owner *entity.Member) (*entity.Workspace, error)
+	// GetWorkspace will get the workspace from the id, ErrNotFound is returned when there is no workspace
+	GetWorkspace(ctx context.Context, id int64) (*entity.Workspace, error)
+	// ListMemberships will get the workspaces of the user, ordered by name
+	ListMemberships(ctx context.Context, userID int64) ([]*entity.Membership, error)
+	// GetMember will get the member of the workspace, ErrNotFound is returned when the user is not a member
+	GetMember(ctx context.Context, workspaceID int64, userID int64) (*entity.Member, error)
+	// ListMembers will get the members of the workspace, ordered by email
+	ListMembers(ctx context.Context, workspaceID int64) ([]*entity.Member, error)
+	// SaveMember will add the member to the workspace or change the role of the existing member
+	SaveMember(ctx context.Context, member *entity.Member) error
+	// DeleteMember will remove the member from the workspace, ErrNotFound is returned when the user is not a member
+	DeleteMember(ctx context.Context, workspaceID int64, userID int64) error
+	// SaveAPIKey will save a new api key and set its id and created at
+	SaveAPIKey(ctx context.Context, key *entity.APIKey) error
+	// ListAPIKeys will get the api keys of the workspace, newest first
+	ListAPIKeys(ctx context.Context, workspaceID int64) ([]*entity.APIKey, error)
+	// GetAPIKeyByHash will get the api key from the hash of the key, ErrNotFound is returned when there is no such key
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
+	// TouchAPIKey will record when the api key was last used
+	TouchAPIKey(ctx context.Context, id int64, at time.Time) error
+	// DeleteAPIKey will delete the api key of the workspace, ErrNotFound is returned when the workspace has no such key
+	DeleteAPIKey(ctx context.Context, workspaceID int64, id int64) error
+}
+
+// UserFinder finds the accounts that are added to workspaces
+type UserFinder interface {
+	GetByID(ctx context.Context, id int64) (*userentity.User, error)
+	GetByEmail(ctx context.Context, email string) (*userentity.User, error)
+}
+
+// Service manages the workspaces, their members and api keys.
+// The user making a change is the user of the authz.Principal in the context.
+type Service struct {
+	repo  WorkspaceRepository
+	users UserFinder
+	now   func() time.Time
+}
+
+// NewService will create a new service
+func NewService(repo WorkspaceRepository, users UserFinder) *Service {
+	return &Service{
+		repo:  repo,
+		users: users,
+		now:   time.Now,
+	}
+}
+
+// CreateWorkspaceInput is the input struct for the CreateWorkspace method
+type CreateWorkspaceInput struct {
+	withValidationErrors
+	Name string
+}
+
+// CreateWorkspace will create a new workspace owned by the logged in user
+func (s *Service) CreateWorkspace(ctx context.Context, input *CreateWorkspaceInput) (*entity.Workspace, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.CreateWorkspace")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	name := entity.NormalizeName(input.Name)
+	if err := name.Validate(); err != nil {
+		input.ValidationErrors["name"] = err.Error()
+		return nil, ErrValidation
+	}
+
+	u, err := s.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateWorkspace(ctx, name, &entity.Member{
+		UserID: u.ID,
+		Email:  u.Email.String(),
+		Role:   authz.RoleOwner,
+	})
+}
+
+// ListWorkspaces will get the workspaces of the logged in user and their role in each
+func (s *Service) ListWorkspaces(ctx context.Context) ([]*entity.Membership, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.ListWorkspaces")
+	defer span.End()
+
+	p := authz.FromContext(ctx)
+	if p.UserID == 0 {
+		return nil, nil
+	}
+	return s.repo.ListMemberships(ctx, p.UserID)
+}
+
+// Role will return the role of the user in the workspace, ErrNotFound is returned when the user is not a member.
+// It is used to decide what a request acting in the workspace is allowed to do.
+func (s *Service) Role(ctx context.Context, workspaceID int64, userID int64) (authz.Role, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.Role")
+	defer span.End()
+
+	m, err := s.repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return "", err
+	}
+	return m.Role, nil
+}
+
+// WorkspaceDetails is a workspace as seen by one of its members
+type WorkspaceDetails struct {
+	Workspace *entity.Workspace
+	Role      authz.Role // The role of the user looking at the workspace
+	Members   []*entity.Member
+	APIKeys   []*entity.APIKey // Only listed for the owners
+}
+
+// GetWorkspace will get the workspace with its members, the logged in user has to be a member
+func (s *Service) GetWorkspace(ctx context.Context, id int64) (*WorkspaceDetails, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.GetWorkspace")
+	defer span.End()
+
+	role, err := s.currentRole(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := s.repo.GetWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := s.repo.ListMembers(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	details := &WorkspaceDetails{Workspace: w, Role: role, Members: members}
+
+	if role.CanAdmin() {
+		details.APIKeys, err = s.repo.ListAPIKeys(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return details, nil
+}
+
+// AddMemberInput is the input struct for the AddMember method
+type AddMemberInput struct {
+	withValidationErrors
+	WorkspaceID int64
+	Email       string
+	Role        string
+}
+
+// AddMember will add a registered user to the workspace, only owners can add members.
+// A user that is already a member gets the new role.
+func (s *Service) AddMember(ctx context.Context, input *AddMemberInput) error {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.AddMember")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if err := s.requireAdmin(ctx, input.WorkspaceID); err != nil {
+		return err
+	}
+
+	role := authz.Role(input.Role)
+	if err := role.Validate(); err != nil {
+		input.ValidationErrors["role"] = err.Error()
+		return ErrValidation
+	}
+	u, err := s.users.GetByEmail(ctx, input.Email)
+	if err != nil {
+		input.ValidationErrors["email"] = "there is no account with this email, they have to register first"
+		return ErrValidation
+	}
+
+	if role != authz.RoleOwner {
+		if err := s.keepAnOwner(ctx, input.WorkspaceID, u.ID); err != nil {
+			input.ValidationErrors["role"] = err.Error()
+			return ErrValidation
+		}
+	}
+
+	return s.repo.SaveMember(ctx, &entity.Member{
+		WorkspaceID: input.WorkspaceID,
+		UserID:      u.ID,
+		Email:       u.Email.String(),
+		Role:        role,
+	})
+}
+
+// RemoveMemberInput is the input struct for the RemoveMember method
+type RemoveMemberInput struct {
+	withValidationErrors
+	WorkspaceID int64
+	UserID      int64
+}
+
+// RemoveMember will remove the user from the workspace, owners can remove anyone and everyone can leave.
+// The last owner can not be removed so the workspace can always be managed.
+func (s *Service) RemoveMember(ctx context.Context, input *RemoveMemberInput) error {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.RemoveMember")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if input.UserID != authz.FromContext(ctx).UserID {
+		if err := s.requireAdmin(ctx, input.WorkspaceID); err != nil {
+			return err
+		}
+	} else if _, err := s.currentRole(ctx, input.WorkspaceID); err != nil {
+		return err
+	}
+
+	if err := s.keepAnOwner(ctx, input.WorkspaceID, input.UserID); err != nil {
+		input.ValidationErrors["member"] = err.Error()
+		return ErrValidation
+	}
+
+	return s.repo.DeleteMember(ctx, input.WorkspaceID, input.UserID)
+}
+
+// CreateAPIKeyInput is the input struct for the CreateAPIKey method
+type CreateAPIKeyInput struct {
+	withValidationErrors
+	WorkspaceID int64
+	Name        string
+	Role        string
+
+	// Key is set by CreateAPIKey to the new secret key.
+	// Only its hash is stored so this is the only time it is available.
+	Key string
+}
+
+// CreateAPIKey will create a new api key for the workspace, only owners can create keys
+func (s *Service) CreateAPIKey(ctx context.Context, input *CreateAPIKeyInput) (*entity.APIKey, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.CreateAPIKey")
+	defer span.End()
+
+	input.ValidationErrors = make(map[string]string)
+
+	if err := s.requireAdmin(ctx, input.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	name := entity.NormalizeName(input.Name)
+	if err := name.Validate(); err != nil {
+		input.ValidationErrors["name"] = err.Error()
+	}
+	role := authz.Role(input.Role)
+	if err := entity.ValidateAPIKeyRole(role); err != nil {
+		input.ValidationErrors["role"] = err.Error()
+	}
+	if len(input.ValidationErrors) > 0 {
+		return nil, ErrValidation
+	}
+
+	key, prefix, hash, err := entity.NewAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := &entity.APIKey{
+		WorkspaceID: input.WorkspaceID,
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hash,
+		Role:        role,
+		CreatedBy:   authz.FromContext(ctx).UserID,
+	}
+	if err := s.repo.SaveAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	input.Key = key
+
+	return apiKey, nil
+}
+
+// DeleteAPIKeyInput is the input struct for the DeleteAPIKey method
+type DeleteAPIKeyInput struct {
+	WorkspaceID int64
+	ID          int64
+}
+
+// DeleteAPIKey will delete the api key so it can no longer be used, only owners can delete keys
+func (s *Service) DeleteAPIKey(ctx context.Context, input *DeleteAPIKeyInput) error {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.DeleteAPIKey")
+	defer span.End()
+
+	if err := s.requireAdmin(ctx, input.WorkspaceID); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteAPIKey(ctx, input.WorkspaceID, input.ID)
+}
+
+// AuthenticateAPIKey will return the api key, ErrInvalidAPIKey is returned when the key is unknown or deleted
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (*entity.APIKey, error) {
+
+	ctx, span := tracing.Start(ctx, "workspace.Service.AuthenticateAPIKey")
+	defer span.End()
+
+	apiKey, err := s.repo.GetAPIKeyByHash(ctx, entity.HashAPIKey(key))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if now := s.now(); now.Sub(apiKey.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, apiKey.ID, now); err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = now
+	}
+
+	return apiKey, nil
+}
+
+// currentUser will return the logged in user
+func (s *Service) currentUser(ctx context.Context) (*userentity.User, error) {
+	p := authz.FromContext(ctx)
+	if p.UserID == 0 {
+		return nil, ErrForbidden
+	}
+	return s.users.GetByID(ctx, p.UserID)
+}
+
+// currentRole will return the role of the logged in user in the workspace, ErrNotFound when they are not a member
+func (s *Service) currentRole(ctx context.Context, workspaceID int64) (authz.Role, error) {
+	p := authz.FromContext(ctx)
+	if p.UserID == 0 {
+		return "", ErrNotFound
+	}
+	return s.Role(ctx, workspaceID, p.UserID)
+}
+
+// requireAdmin will check the logged in user is an owner of the workspace
+func (s *Service) requireAdmin(ctx context.Context, workspaceID int64) error {
+	role, err := s.currentRole(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	if !role.CanAdmin() {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// keepAnOwner will return an error when the user is the only owner of the workspace,
+// it is checked before the user stops being an owner
+func (s *Service) keepAnOwner(ctx context.Context, workspaceID int64, userID int64) error {
+	members, err := s.repo.ListMembers(ctx, workspaceID)
+	if err != nil {
+		return err
+	}
+	owners := 0
+	isOwner := false
+	for _, m := range members {
+		if m.Role == authz.RoleOwner {
+			owners++
+			isOwner = isOwner || m.UserID == userID
+		}
+	}
+	if isOwner && owners == 1 {
+		return errors.New("the workspace needs at least one other owner first")
+	}
+	return nil
+}
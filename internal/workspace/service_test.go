@@ -0,0 +1,191 @@
+package workspace_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/griggsjared/getsit/internal/authz"
+	"github.com/griggsjared/getsit/internal/user"
+	userentity "github.com/griggsjared/getsit/internal/user/entity"
+	userrepository "github.com/griggsjared/getsit/internal/user/repository"
+	"github.com/griggsjared/getsit/internal/workspace"
+	"github.com/griggsjared/getsit/internal/workspace/repository"
+)
+
+// newService will create a service with the users owner@, editor@, viewer@ and outsider@example.com, ids 1 to 4
+func newService(t *testing.T) *workspace.Service {
+	users := userrepository.NewMemUserRepository()
+	for _, email := range []string{"owner@example.com", "editor@example.com", "viewer@example.com", "outsider@example.com"} {
+		if _, err := users.CreateUser(context.Background(), userentity.Email(email), nil); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+	return workspace.NewService(repository.NewMemWorkspaceRepository(), user.NewTestService(users, nil))
+}
+
+// as will return the context of a request by the user
+func as(userID int64) context.Context {
+	return authz.WithPrincipal(context.Background(), authz.Principal{UserID: userID})
+}
+
+func TestService_CreateWorkspace(t *testing.T) {
+
+	s := newService(t)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		input   string
+		wantErr error
+	}{
+		{name: "valid", ctx: as(1), input: "  Marketing  "},
+		{name: "empty name", ctx: as(1), input: " ", wantErr: workspace.ErrValidation},
+		{name: "anonymous", ctx: context.Background(), input: "Nobody", wantErr: workspace.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := s.CreateWorkspace(tt.ctx, &workspace.CreateWorkspaceInput{Name: tt.input})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateWorkspace() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if w.Name != "Marketing" {
+				t.Errorf("CreateWorkspace() name = %q, want %q", w.Name, "Marketing")
+			}
+			if role, err := s.Role(tt.ctx, w.ID, 1); err != nil || role != authz.RoleOwner {
+				t.Errorf("Role() = %v, %v, want the creator to be an owner", role, err)
+			}
+		})
+	}
+}
+
+func TestService_Members(t *testing.T) {
+
+	s := newService(t)
+	w, err := s.CreateWorkspace(as(1), &workspace.CreateWorkspaceInput{Name: "Team"})
+	if err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+
+	addTests := []struct {
+		name    string
+		ctx     context.Context
+		email   string
+		role    string
+		wantErr error
+	}{
+		{name: "owner adds an editor", ctx: as(1), email: " Editor@Example.com", role: "editor"},
+		{name: "owner adds a viewer", ctx: as(1), email: "viewer@example.com", role: "viewer"},
+		{name: "editor can not add", ctx: as(2), email: "outsider@example.com", role: "viewer", wantErr: workspace.ErrForbidden},
+		{name: "outsider can not add", ctx: as(4), email: "outsider@example.com", role: "viewer", wantErr: workspace.ErrNotFound},
+		{name: "unknown email", ctx: as(1), email: "nobody@example.com", role: "viewer", wantErr: workspace.ErrValidation},
+		{name: "unknown role", ctx: as(1), email: "outsider@example.com", role: "admin", wantErr: workspace.ErrValidation},
+		{name: "last owner can not be demoted", ctx: as(1), email: "owner@example.com", role: "editor", wantErr: workspace.ErrValidation},
+	}
+	for _, tt := range addTests {
+		t.Run("add "+tt.name, func(t *testing.T) {
+			err := s.AddMember(tt.ctx, &workspace.AddMemberInput{WorkspaceID: w.ID, Email: tt.email, Role: tt.role})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AddMember() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	details, err := s.GetWorkspace(as(3), w.ID)
+	if err != nil {
+		t.Fatalf("GetWorkspace() error = %v", err)
+	}
+	if len(details.Members) != 3 || details.Role != authz.RoleViewer || details.APIKeys != nil {
+		t.Errorf("GetWorkspace() members = %d, role = %v, keys = %v, want 3 members for a viewer without keys", len(details.Members), details.Role, details.APIKeys)
+	}
+	if _, err := s.GetWorkspace(as(4), w.ID); !errors.Is(err, workspace.ErrNotFound) {
+		t.Errorf("GetWorkspace() outsider error = %v, want %v", err, workspace.ErrNotFound)
+	}
+
+	removeTests := []struct {
+		name    string
+		ctx     context.Context
+		userID  int64
+		wantErr error
+	}{
+		{name: "editor can not remove others", ctx: as(2), userID: 3, wantErr: workspace.ErrForbidden},
+		{name: "last owner can not leave", ctx: as(1), userID: 1, wantErr: workspace.ErrValidation},
+		{name: "viewer can leave", ctx: as(3), userID: 3},
+		{name: "owner removes an editor", ctx: as(1), userID: 2},
+		{name: "not a member", ctx: as(1), userID: 4, wantErr: workspace.ErrNotFound},
+	}
+	for _, tt := range removeTests {
+		t.Run("remove "+tt.name, func(t *testing.T) {
+			err := s.RemoveMember(tt.ctx, &workspace.RemoveMemberInput{WorkspaceID: w.ID, UserID: tt.userID})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RemoveMember() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	memberships, err := s.ListWorkspaces(as(2))
+	if err != nil || len(memberships) != 0 {
+		t.Errorf("ListWorkspaces() = %v, %v, want no workspaces for a removed member", memberships, err)
+	}
+}
+
+func TestService_APIKeys(t *testing.T) {
+
+	s := newService(t)
+	w, _ := s.CreateWorkspace(as(1), &workspace.CreateWorkspaceInput{Name: "Team"})
+	s.AddMember(as(1), &workspace.AddMemberInput{WorkspaceID: w.ID, Email: "editor@example.com", Role: "editor"})
+
+	createTests := []struct {
+		name    string
+		ctx     context.Context
+		keyName string
+		role    string
+		wantErr error
+	}{
+		{name: "editor can not create", ctx: as(2), keyName: "CI", role: "editor", wantErr: workspace.ErrForbidden},
+		{name: "owner role", ctx: as(1), keyName: "CI", role: "owner", wantErr: workspace.ErrValidation},
+		{name: "no name", ctx: as(1), keyName: "", role: "viewer", wantErr: workspace.ErrValidation},
+		{name: "viewer", ctx: as(1), keyName: "Reports", role: "viewer"},
+	}
+	for _, tt := range createTests {
+		t.Run("create "+tt.name, func(t *testing.T) {
+			_, err := s.CreateAPIKey(tt.ctx, &workspace.CreateAPIKeyInput{WorkspaceID: w.ID, Name: tt.keyName, Role: tt.role})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateAPIKey() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	input := &workspace.CreateAPIKeyInput{WorkspaceID: w.ID, Name: "CI", Role: "editor"}
+	created, err := s.CreateAPIKey(as(1), input)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if input.Key == "" || created.KeyHash == input.Key {
+		t.Fatalf("CreateAPIKey() key = %q, hash = %q, want a key that is only stored hashed", input.Key, created.KeyHash)
+	}
+
+	key, err := s.AuthenticateAPIKey(context.Background(), input.Key)
+	if err != nil {
+		t.Fatalf("AuthenticateAPIKey() error = %v", err)
+	}
+	if key.ID != created.ID || key.WorkspaceID != w.ID || key.Role != authz.RoleEditor || key.LastUsedAt.IsZero() {
+		t.Errorf("AuthenticateAPIKey() = %+v, want the created editor key marked as used", key)
+	}
+	if _, err := s.AuthenticateAPIKey(context.Background(), input.Key+"x"); !errors.Is(err, workspace.ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey() wrong key error = %v, want %v", err, workspace.ErrInvalidAPIKey)
+	}
+
+	if err := s.DeleteAPIKey(as(2), &workspace.DeleteAPIKeyInput{WorkspaceID: w.ID, ID: created.ID}); !errors.Is(err, workspace.ErrForbidden) {
+		t.Errorf("DeleteAPIKey() editor error = %v, want %v", err, workspace.ErrForbidden)
+	}
+	if err := s.DeleteAPIKey(as(1), &workspace.DeleteAPIKeyInput{WorkspaceID: w.ID, ID: created.ID}); err != nil {
+		t.Fatalf("DeleteAPIKey() error = %v", err)
+	}
+	if _, err := s.AuthenticateAPIKey(context.Background(), input.Key); !errors.Is(err, workspace.ErrInvalidAPIKey) {
+		t.Errorf("AuthenticateAPIKey() deleted key error = %v, want %v", err, workspace.ErrInvalidAPIKey)
+	}
+}